@@ -0,0 +1,178 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"reflect"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/just-install/just-install/pkg/justinstall"
+)
+
+func handleApplyAction(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return errors.New("expected the path of a plan, as printed by plan --json")
+	}
+
+	plan, err := readPlan(c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	var pkgs []string
+	for _, p := range plan.Packages {
+		pkgs = append(pkgs, p.Name)
+	}
+
+	summary := newBatchSummary(pkgs)
+
+	return finishRun(c, summary, applyPlan(c, plan, summary))
+}
+
+// applyPlan installs the packages in the given plan, with the very same installers, recording the
+// outcome for each of them in the given summary.
+func applyPlan(c *cli.Context, plan *installPlan, summary *batchSummary) error {
+	defer applyDeadline(c)()
+
+	endLoad := timings.Start("registry load")
+	registry, err := loadRegistry(c, c.Bool("force"))
+	endLoad()
+	if err != nil {
+		return err
+	}
+
+	options, err := installOptions(c)
+	if err != nil {
+		return err
+	}
+
+	// Pin the versions requested when the plan was made, or they would all look like drift.
+	versions := make(map[string]string)
+	for _, p := range plan.Packages {
+		if p.Version != "" {
+			versions[p.Name] = p.Version
+		}
+	}
+
+	if err := pinVersions(registry, versions); err != nil {
+		return fmt.Errorf("cannot apply the plan: %w", err)
+	}
+
+	var pkgs, drifted []string
+	archs := make(map[string]string)
+
+	for _, p := range plan.Packages {
+		entry, ok := registry.Packages[p.Name]
+		if !ok {
+			return fmt.Errorf("cannot apply the plan: %w: %v", justinstall.ErrUnknownPackage, p.Name)
+		}
+
+		// Plan on a copy, since planning resolves the entry.
+		current := entry
+		if drift := planDrift(c.Context, &current, p.Arch, options, p.Installer); len(drift) > 0 {
+			drifted = append(drifted, fmt.Sprintf("%v: %v", p.Name, strings.Join(drift, ", ")))
+		}
+
+		entry.Pin(p.Installer)
+		registry.Packages[p.Name] = entry
+
+		pkgs = append(pkgs, p.Name)
+		archs[p.Name] = p.Arch
+	}
+
+	if len(drifted) > 0 && !c.Bool("allow-drift") {
+		return fmt.Errorf("the registry has changed since the plan was made (see --allow-drift):\n    %v", strings.Join(drifted, "\n    "))
+	}
+
+	for _, d := range drifted {
+		log.Println("WARNING: the registry has changed since the plan was made, installing as planned anyway:", d)
+	}
+
+	return installRegistryPackages(c, registry, pkgs, archs, summary)
+}
+
+// planDrift returns how the installer that the given entry would use now differs from the planned
+// one, if at all.
+func planDrift(ctx context.Context, entry *justinstall.RegistryEntry, arch string, options *justinstall.Options, planned *justinstall.InstallerPlan) []string {
+	current, err := entry.Plan(ctx, arch, options)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	var ret []string
+	if current.Version != planned.Version {
+		ret = append(ret, fmt.Sprintf("version %v is now %v", planned.Version, current.Version))
+	}
+	if current.Arch != planned.Arch || current.Kind != planned.Kind {
+		ret = append(ret, fmt.Sprintf("%v %v installer is now %v %v", planned.Arch, planned.Kind, current.Arch, current.Kind))
+	}
+	if current.URL != planned.URL {
+		ret = append(ret, fmt.Sprintf("URL %v is now %v", planned.URL, current.URL))
+	}
+	// Empty lists and maps are left out of plans, they are read back as nil.
+	if (len(current.Hashes) > 0 || len(planned.Hashes) > 0) && !reflect.DeepEqual(current.Hashes, planned.Hashes) {
+		ret = append(ret, "checksums changed")
+	}
+	if (len(current.Mirrors) > 0 || len(planned.Mirrors) > 0) && !reflect.DeepEqual(current.Mirrors, planned.Mirrors) {
+		ret = append(ret, "mirrors changed")
+	}
+	if (len(current.Dependencies) > 0 || len(planned.Dependencies) > 0) && !reflect.DeepEqual(current.Dependencies, planned.Dependencies) {
+		ret = append(ret, "dependencies changed")
+	}
+
+	return ret
+}
+
+// readPlan reads the plan at the given path, as printed by plan --json. Plans listing packages that
+// couldn't be installed when they were made, or whose installer was unknown, are refused.
+func readPlan(path string) (*installPlan, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read plan: %w", err)
+	}
+
+	var plan installPlan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, fmt.Errorf("could not parse plan %v: %w", path, err)
+	}
+
+	if plan.Version != planVersion {
+		return nil, fmt.Errorf("plan %v has version %v, but this version of just-install only supports version %v", path, plan.Version, planVersion)
+	}
+
+	var broken []string
+	for _, p := range plan.Packages {
+		if p.Installer == nil || p.Installer.Unknown {
+			broken = append(broken, p.Name)
+		} else if err := checkArch(p.Arch); err != nil {
+			return nil, fmt.Errorf("invalid plan %v: %v: %w", path, p.Name, err)
+		}
+	}
+
+	if len(broken) > 0 {
+		return nil, fmt.Errorf("plan %v lists packages that could not be installed, or whose installer was unknown, when it was made: %v", path, strings.Join(broken, ", "))
+	}
+
+	return &plan, nil
+}
@@ -16,43 +16,55 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"log"
+	"net/url"
 	"os"
+	"path/filepath"
 	"runtime"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/ungerik/go-dry"
 	"github.com/urfave/cli/v2"
 
+	"github.com/just-install/just-install/pkg/checksum"
 	"github.com/just-install/just-install/pkg/fetch"
+	"github.com/just-install/just-install/pkg/justinstall"
+	"github.com/just-install/just-install/pkg/paths"
 )
 
-func handleAuditAction(c *cli.Context) error {
-	expectedContentTypes := []string{
-		"application/exe",
-		"application/octet-stream",
-		"application/unknown", // Bintray
-		"application/x-dosexec",
-		"application/x-msdos-program",
-		"application/x-msdownload",
-		"application/x-msi",
-		"application/x-sdlc", // Oracle
-		"application/x-zip-compressed",
-		"application/zip",
-		"binary/octet-stream",
-		"Composite Document File V2 Document, corrupt: Can't read SAT; charset=binary", // Google Code
-		"text/x-python", // PIP
-		"Zip Files",
-		"application/x-ms-dos-executable", // OpenVPN
-		"exe",                             // VPN Unlimited
-		"",                                // PIA
-		"text/plain; charset=ISO-8859-1",  // LibreOffice
-		"text/html; charset=utf-8",        // SourceForge
-		"application/x-ole-storage",       // EpicGames
-		"application/x-troff-man",         // MSIs on OSDN
-		"application/x-executable",        // Notepad++
-	}
+// expectedContentTypes are the values of the Content-Type header that we accept when checking
+// installer URLs.
+var expectedContentTypes = []string{
+	"application/exe",
+	"application/octet-stream",
+	"application/unknown", // Bintray
+	"application/x-dosexec",
+	"application/x-msdos-program",
+	"application/x-msdownload",
+	"application/x-msi",
+	"application/x-sdlc", // Oracle
+	"application/x-zip-compressed",
+	"application/zip",
+	"binary/octet-stream",
+	"Composite Document File V2 Document, corrupt: Can't read SAT; charset=binary", // Google Code
+	"text/x-python", // PIP
+	"Zip Files",
+	"application/x-ms-dos-executable", // OpenVPN
+	"exe",                             // VPN Unlimited
+	"",                                // PIA
+	"text/plain; charset=ISO-8859-1",  // LibreOffice
+	"text/html; charset=utf-8",        // SourceForge
+	"application/x-ole-storage",       // EpicGames
+	"application/x-troff-man",         // MSIs on OSDN
+	"application/x-executable",        // Notepad++
+}
 
+func handleAuditAction(c *cli.Context) error {
 	// retry executes f, retrying a call with exponential back-off if it returns an error and true
 	// as its first return value. Ends up returning the eventual error value after a maximum of
 	// three retries.
@@ -76,11 +88,20 @@ func handleAuditAction(c *cli.Context) error {
 		return ret
 	}
 
+	jobs, err := auditJobs(c)
+	if err != nil {
+		return err
+	}
+
 	registry, err := loadRegistry(c, c.Bool("force"))
 	if err != nil {
 		return err
 	}
 
+	if c.Bool("update-checksums") {
+		return updateChecksums(c, registry, jobs)
+	}
+
 	checkLink := func(rawurl string) error {
 		return retry(func() (bool, error) {
 			// Policy: retry on server or transport error, fail immediately otherwise.
@@ -93,56 +114,310 @@ func handleAuditAction(c *cli.Context) error {
 		})
 	}
 
-	// Workers
+	minSeverity, ok := severityRanks[c.String("min-severity")]
+	if !ok {
+		return fmt.Errorf("unknown severity: %v", c.String("min-severity"))
+	}
+
+	// Collect all links first, so that findings can be reported in registry order regardless of
+	// which worker finishes first.
 	type workItem struct {
-		description string
-		rawurl      string
+		name string
+		url  justinstall.InstallerURL
+	}
+
+	var items []workItem
+	for _, name := range registry.SortedPackageNames() {
+		entry := registry.Packages[name]
+		if entry.SkipAudit {
+			log.Println("skipping audit of", name)
+			continue
+		}
+
+		for _, u := range entry.InstallerURLs() {
+			items = append(items, workItem{name, u})
+		}
 	}
 
-	workerPoolSize := runtime.NumCPU()
-	workerQueue := make(chan workItem, workerPoolSize)
+	// Workers
+	hosts := newHostLimiter()
+	workerQueue := make(chan int, jobs)
 	var workerWg sync.WaitGroup
 
-	var collectedErrors []error
-	for i := 0; i < workerPoolSize; i++ {
+	results := make([]error, len(items))
+	for i := 0; i < jobs; i++ {
 		workerWg.Add(1)
 
 		go func() {
 			for {
-				item, more := <-workerQueue
+				i, more := <-workerQueue
 				if !more {
 					workerWg.Done()
 					return
 				}
 
-				log.Println("checking", item.description)
+				log.Println("checking", items[i].name, "("+items[i].url.Description+")")
 
-				if err := checkLink(item.rawurl); err != nil {
-					collectedErrors = append(collectedErrors, err)
-				}
+				release := hosts.acquire(items[i].url.URL)
+				endCheck := timings.Start("check")
+				results[i] = checkLink(items[i].url.URL)
+				endCheck()
+				release()
 			}
 		}()
 	}
 
 	// Push jobs to workers
+	for i := range items {
+		workerQueue <- i
+	}
+
+	close(workerQueue)
+	workerWg.Wait()
+
+	findings := []auditFinding{}
+	errorCount := 0
+
+	for i, item := range items {
+		for _, f := range auditInstallerURL(item.name, item.url, results[i]) {
+			if f.Severity == severityError {
+				errorCount++
+			}
+
+			if severityRanks[f.Severity] >= minSeverity {
+				findings = append(findings, f)
+			}
+		}
+	}
+
+	err = printOutput(c, findings, func() error {
+		for _, f := range findings {
+			fmt.Printf("%-7v  %v (%v): %v\n", f.Severity, f.Package, f.Installer, f.Message)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if errorCount > 0 {
+		return fmt.Errorf("audit found %v error(s)", errorCount)
+	}
+
+	return nil
+}
+
+// auditJobs returns how many installers audit should check or download at the same time, as given
+// by --jobs or, by default, the number of CPUs.
+func auditJobs(c *cli.Context) (int, error) {
+	if !c.IsSet("jobs") {
+		return runtime.NumCPU(), nil
+	}
+
+	jobs := c.Int("jobs")
+	if jobs < 1 {
+		return 0, fmt.Errorf("invalid number of jobs: %v", jobs)
+	}
+
+	return jobs, nil
+}
+
+// hostLimiter bounds the number of concurrent requests to each host, so that auditing many
+// installers served by the same host doesn't hammer it. The bound matches the idle connections the
+// shared transport keeps per host, so that every request can reuse a kept-alive connection.
+type hostLimiter struct {
+	mutex sync.Mutex
+	hosts map[string]chan struct{}
+}
+
+// newHostLimiter creates a new hostLimiter with no requests in flight.
+func newHostLimiter() *hostLimiter {
+	return &hostLimiter{hosts: make(map[string]chan struct{})}
+}
+
+// acquire waits until a request to the host of the given URL can be made, and returns a function to
+// call once it is done.
+func (h *hostLimiter) acquire(rawurl string) func() {
+	var host string
+	if u, err := url.Parse(rawurl); err == nil {
+		host = strings.ToLower(u.Host)
+	}
+
+	h.mutex.Lock()
+	semaphore, ok := h.hosts[host]
+	if !ok {
+		semaphore = make(chan struct{}, fetch.Transport.MaxIdleConnsPerHost)
+		h.hosts[host] = semaphore
+	}
+	h.mutex.Unlock()
+
+	semaphore <- struct{}{}
+	return func() { <-semaphore }
+}
+
+// Severities of audit findings, from the least to the most severe.
+const (
+	severityInfo    = "info"
+	severityWarning = "warning"
+	severityError   = "error"
+)
+
+// severityRanks orders severities, for --min-severity.
+var severityRanks = map[string]int{severityInfo: 0, severityWarning: 1, severityError: 2}
+
+// auditFinding is an issue with an installer URL of the registry, found by audit.
+type auditFinding struct {
+	Package   string `json:"package"`
+	Installer string `json:"installer"` // i.e. "x86_64"
+	URL       string `json:"url"`
+	Severity  string `json:"severity"`
+	Message   string `json:"message"`
+}
+
+// auditInstallerURL returns the findings about the given installer URL of a package, given the
+// error returned by checking whether it is reachable. Unreachable installers are errors, those
+// that cannot be verified warnings, and those only verified with a weak algorithm informational.
+func auditInstallerURL(name string, u justinstall.InstallerURL, linkErr error) []auditFinding {
+	var ret []auditFinding
+
+	add := func(severity string, format string, a ...interface{}) {
+		ret = append(ret, auditFinding{name, u.Description, u.URL, severity, fmt.Sprintf(format, a...)})
+	}
+
+	if linkErr != nil {
+		add(severityError, "%v", linkErr)
+	}
+
+	if algorithm := checksum.Strongest(u.Hashes); algorithm == "" {
+		add(severityWarning, "no checksum")
+	} else if checksum.Weak(algorithm) {
+		add(severityInfo, "only verified with the weak %v algorithm", algorithm)
+	}
+
+	return ret
+}
+
+// updateChecksums downloads all installers in the registry, computes their checksums and writes
+// the missing and stale ones back to the local registry file, downloading up to the given number
+// of installers at the same time.
+func updateChecksums(c *cli.Context, registry *justinstall.Registry, jobs int) error {
+	registryPath := c.String("registry")
+	if !c.IsSet("registry") || !dry.FileExists(registryPath) {
+		return errors.New("updating checksums requires a local registry file (see --registry)")
+	}
+
+	registryFile, err := justinstall.ReadRegistryFile(registryPath)
+	if err != nil {
+		return err
+	}
+
+	tempDir, err := paths.TempDirCreate()
+	if err != nil {
+		return fmt.Errorf("could not create temporary directory: %w", err)
+	}
+
+	type workItem struct {
+		name string
+		url  justinstall.InstallerURL
+	}
+
+	type workResult struct {
+		checksum string
+		err      error
+	}
+
+	var items []workItem
 	for _, name := range registry.SortedPackageNames() {
 		entry := registry.Packages[name]
 		if entry.SkipAudit {
-			log.Println("skipping audit of", name)
+			log.Println("skipping checksums of", name)
 			continue
 		}
 
-		if entry.Installer.X86 != "" {
-			workerQueue <- workItem{name + " (x86)", entry.ExpandString(entry.Installer.X86)}
+		for _, u := range entry.InstallerURLs() {
+			items = append(items, workItem{name, u})
 		}
+	}
+
+	// Download and hash installers in parallel, each one in its own directory since different
+	// installers may have the same file name.
+	results := make([]workResult, len(items))
+	semaphore := make(chan struct{}, jobs)
+	hosts := newHostLimiter()
+	var wg sync.WaitGroup
+
+	for i, item := range items {
+		wg.Add(1)
+
+		go func(i int, item workItem) {
+			defer wg.Done()
+
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			release := hosts.acquire(item.url.URL)
+			defer release()
+
+			log.Println("hashing", item.name, "("+item.url.Description+")")
+
+			downloadDir := filepath.Join(tempDir, "checksums", strconv.Itoa(i))
+			defer os.RemoveAll(downloadDir)
 
-		if entry.Installer.X86_64 != "" {
-			workerQueue <- workItem{name + " (x86_64)", entry.ExpandString(entry.Installer.X86_64)}
+			if err := os.MkdirAll(downloadDir, 0700); err != nil {
+				results[i] = workResult{"", err}
+				return
+			}
+
+			downloadedFile, err := fetch.Fetch(item.url.URL, &fetch.Options{Context: c.Context, Destination: downloadDir})
+			if err != nil {
+				results[i] = workResult{"", err}
+				return
+			}
+
+			sum, err := checksum.File(downloadedFile)
+			results[i] = workResult{sum, err}
+		}(i, item)
+	}
+
+	wg.Wait()
+
+	// Apply changes and summarize them
+	changed := 0
+	var collectedErrors []error
+
+	for i, item := range items {
+		result := results[i]
+		description := item.name + " (" + item.url.Description + ")"
+
+		if result.err != nil {
+			collectedErrors = append(collectedErrors, fmt.Errorf("%v: %w", description, result.err))
+			continue
+		}
+
+		if strings.EqualFold(result.checksum, item.url.Checksum) {
+			continue
+		}
+
+		if err := registryFile.Set(item.name, item.url.ChecksumPath, result.checksum); err != nil {
+			return err
+		}
+		changed++
+
+		if item.url.Checksum == "" {
+			fmt.Printf("%v: added %v\n", description, result.checksum)
+		} else {
+			fmt.Printf("%v: updated %v -> %v\n", description, item.url.Checksum, result.checksum)
 		}
 	}
 
-	close(workerQueue)
-	workerWg.Wait()
+	fmt.Printf("%v checksum(s) changed, %v unchanged, %v error(s)\n", changed, len(items)-changed-len(collectedErrors), len(collectedErrors))
+
+	if changed > 0 {
+		if err := registryFile.Write(registryPath); err != nil {
+			return fmt.Errorf("could not write %v: %w", registryPath, err)
+		}
+	}
 
 	if collectedErrors != nil {
 		log.Println("found errors:")
@@ -0,0 +1,208 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/just-install/just-install/pkg/fetch"
+	"github.com/just-install/just-install/pkg/justinstall"
+)
+
+// benchmarkHosts is how many hosts are benchmarked when no package is given: those serving the
+// most packages in the registry.
+const benchmarkHosts = 5
+
+// benchmarkTimeout is how long a single benchmark download can take.
+const benchmarkTimeout = time.Minute
+
+// benchmarkResult is the outcome of benchmarking a host, as printed by the benchmark command.
+type benchmarkResult struct {
+	Host       string `json:"host"`
+	URL        string `json:"url"`
+	Packages   int    `json:"packages"`   // Packages served by the host, among those benchmarked
+	Latency    int64  `json:"latencyMs"`  // Time to the response headers
+	Downloaded int64  `json:"downloaded"` // Bytes
+	Throughput int64  `json:"throughput"` // Bytes per second
+	Slow       bool   `json:"slow"`       // Much slower than the other hosts
+	Error      string `json:"error,omitempty"`
+}
+
+func handleBenchmarkAction(c *cli.Context) error {
+	registry, err := loadRegistry(c, c.Bool("force"))
+	if err != nil {
+		return err
+	}
+
+	names := c.Args().Slice()
+	for _, name := range names {
+		if _, ok := registry.Packages[name]; !ok {
+			return fmt.Errorf("%w: %v", justinstall.ErrUnknownPackage, name)
+		}
+	}
+	if len(names) == 0 {
+		names = registry.SortedPackageNames()
+	}
+
+	results := benchmarkTargets(registry, names)
+	if len(results) == 0 {
+		return errors.New("no installer to download over HTTP or HTTPS")
+	}
+
+	if len(c.Args().Slice()) == 0 && len(results) > benchmarkHosts {
+		results = results[:benchmarkHosts]
+	}
+
+	limit := c.Int64("size") * 1024 * 1024
+	for i := range results {
+		r := &results[i]
+		log.Println("benchmarking", r.Host, "with", r.URL)
+
+		latency, downloaded, elapsed, err := benchmarkDownload(c.Context, r.URL, limit)
+		r.Latency = latency.Milliseconds()
+		r.Downloaded = downloaded
+		if elapsed > 0 {
+			r.Throughput = int64(float64(downloaded) / elapsed.Seconds())
+		}
+		if err != nil {
+			r.Error = err.Error()
+		}
+	}
+
+	markSlowHosts(results)
+
+	return printOutput(c, results, func() error {
+		for _, r := range results {
+			if r.Error != "" {
+				fmt.Printf("%v: %v\n", r.Host, r.Error)
+				continue
+			}
+
+			slow := ""
+			if r.Slow {
+				slow = " (slow)"
+			}
+
+			fmt.Printf("%v: %v ms latency, %v/s over %v%v\n", r.Host, r.Latency, formatBytes(r.Throughput), formatBytes(r.Downloaded), slow)
+		}
+
+		return nil
+	})
+}
+
+// benchmarkTargets returns the hosts serving the installers of the given packages, including
+// mirrors, each one with the first URL found for it. Hosts serving more packages come first.
+func benchmarkTargets(registry *justinstall.Registry, names []string) []benchmarkResult {
+	var ret []benchmarkResult
+	index := make(map[string]int) // Host -> index in ret
+
+	for _, name := range names {
+		entry := registry.Packages[name]
+		seen := make(map[string]bool) // Count each package once per host
+
+		for _, u := range entry.InstallerURLs() {
+			for _, rawurl := range append([]string{u.URL}, u.Mirrors...) {
+				parsed, err := url.Parse(rawurl)
+				if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || seen[parsed.Host] {
+					continue
+				}
+				seen[parsed.Host] = true
+
+				i, ok := index[parsed.Host]
+				if !ok {
+					i = len(ret)
+					index[parsed.Host] = i
+					ret = append(ret, benchmarkResult{Host: parsed.Host, URL: rawurl})
+				}
+
+				ret[i].Packages++
+			}
+		}
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool { return ret[i].Packages > ret[j].Packages })
+
+	return ret
+}
+
+// benchmarkDownload downloads up to limit bytes from the given URL, returning how long the server
+// took to send the response headers, how much was downloaded and how long that took.
+func benchmarkDownload(ctx context.Context, rawurl string, limit int64) (time.Duration, int64, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, benchmarkTimeout)
+	defer cancel()
+
+	req, err := http.NewRequest("GET", rawurl, nil)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	start := time.Now()
+
+	resp, err := fetch.NewClient().Do(req.WithContext(ctx))
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	defer resp.Body.Close()
+
+	latency := time.Since(start)
+
+	if resp.StatusCode != http.StatusOK {
+		return latency, 0, 0, &fetch.HTTPStatusError{Expected: http.StatusOK, Received: resp.StatusCode, Resource: rawurl}
+	}
+
+	start = time.Now()
+	downloaded, err := io.Copy(ioutil.Discard, io.LimitReader(resp.Body, limit))
+	elapsed := time.Since(start)
+
+	// Running out of time still gives a useful measure of a slow host.
+	if ctx.Err() == context.DeadlineExceeded {
+		err = nil
+	}
+
+	return latency, downloaded, elapsed, err
+}
+
+// markSlowHosts marks the hosts whose throughput is less than a quarter of the median one.
+func markSlowHosts(results []benchmarkResult) {
+	var throughputs []int64
+	for _, r := range results {
+		if r.Error == "" {
+			throughputs = append(throughputs, r.Throughput)
+		}
+	}
+
+	if len(throughputs) < 2 {
+		return
+	}
+
+	sort.Slice(throughputs, func(i, j int) bool { return throughputs[i] < throughputs[j] })
+	median := throughputs[len(throughputs)/2]
+
+	for i := range results {
+		results[i].Slow = results[i].Error == "" && results[i].Throughput < median/4
+	}
+}
@@ -16,25 +16,172 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 
 	"github.com/urfave/cli/v2"
 
+	"github.com/just-install/just-install/pkg/justinstall"
+	"github.com/just-install/just-install/pkg/lock"
 	"github.com/just-install/just-install/pkg/paths"
 )
 
 func handleCleanAction(c *cli.Context) error {
-	// Yup, this is weird, but we don't want a public API that allows us to use the temporary
-	// directory before creating it elsewhere in the program.
-	tempDir, err := paths.TempDirCreate()
+	all := c.Bool("all")
+	cleanCache, cleanState, cleanTemp := all || c.Bool("cache"), all || c.Bool("state"), all || c.Bool("temp")
+	prefix := c.String("prefix")
+	if !cleanCache && !cleanState && !cleanTemp && prefix == "" {
+		cleanCache, cleanTemp = true, true
+	}
+
+	var dirs []string
+
+	// Nothing is created here, so that --dry-run leaves the filesystem alone; missing directories
+	// have nothing to clean anyway.
+	if cleanTemp {
+		dirs = append(dirs, paths.TempDir())
+	}
+
+	if cleanCache {
+		dirs = append(dirs, paths.CacheDir())
+	}
+
+	if cleanState {
+		dirs = append(dirs, paths.StateDir())
+	}
+
+	if prefix != "" {
+		// Refuse directories that aren't prefixes, so that a mistyped path doesn't wipe unrelated files
+		if err := justinstall.CheckPrefix(prefix); err != nil {
+			return err
+		}
+		dirs = append(dirs, prefix)
+	}
+
+	if c.Bool("dry-run") {
+		return previewClean(dirs...)
+	}
+
+	if cleanState && !c.Bool("yes") {
+		fmt.Println("cleaning the state forgets which packages just-install installed: they stay installed, but")
+		fmt.Println("cannot be upgraded or uninstalled with just-install anymore")
+
+		confirmed, err := askYesNo("proceed?")
+		if err != nil {
+			return err
+		}
+
+		if !confirmed {
+			return errors.New("cleaning the state not confirmed")
+		}
+	}
+
+	release, err := acquireLock(c)
 	if err != nil {
-		return fmt.Errorf("could not create temporary directory: %w", err)
+		return err
 	}
+	defer release()
+
+	for _, dir := range dirs {
+		targets, err := cleanablePaths(dir)
+		if err != nil {
+			return fmt.Errorf("could not clean %v: %w", dir, err)
+		}
+
+		for _, path := range targets {
+			if err := os.RemoveAll(path); err != nil {
+				return fmt.Errorf("could not clean %v: %w", dir, err)
+			}
+		}
+	}
+
+	return nil
+}
 
-	if err := os.RemoveAll(tempDir); err != nil {
-		return fmt.Errorf("could not clean temporary directory: %w", err)
+// previewClean lists the contents of the given directories, along with their size, without
+// removing anything.
+func previewClean(dirs ...string) error {
+	var total int64
+
+	for _, dir := range dirs {
+		targets, err := cleanablePaths(dir)
+		if err != nil {
+			return err
+		}
+
+		for _, path := range targets {
+			size, err := diskUsage(path)
+			if err != nil {
+				return err
+			}
+			total += size
+
+			fmt.Printf("would remove %v (%v)\n", path, formatBytes(size))
+		}
 	}
 
+	fmt.Printf("would reclaim %v\n", formatBytes(total))
+
 	return nil
 }
+
+// cleanablePaths returns the files and directories that cleaning the given directory removes: all
+// of its contents but the global lock, which is held while cleaning and cannot be removed while open
+// on Windows. A missing directory has nothing to remove.
+func cleanablePaths(dir string) ([]string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var ret []string
+	for _, entry := range entries {
+		if entry.Name() == lock.FileName {
+			continue
+		}
+
+		ret = append(ret, filepath.Join(dir, entry.Name()))
+	}
+
+	return ret, nil
+}
+
+// diskUsage returns the size of the given file, or of all the files below the given directory.
+func diskUsage(path string) (int64, error) {
+	var ret int64
+
+	err := filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() {
+			ret += info.Size()
+		}
+
+		return nil
+	})
+
+	return ret, err
+}
+
+// formatBytes formats the given size with a binary unit, i.e. "1.5 MiB".
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%v B", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
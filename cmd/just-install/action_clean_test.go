@@ -0,0 +1,49 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"testing"
+
+	"github.com/urfave/cli/v2"
+)
+
+func TestCleanDryRunCreatesNothing(t *testing.T) {
+	dir, cleanup := testEnvironment(t)
+	defer cleanup()
+
+	set := flag.NewFlagSet("clean", flag.ContinueOnError)
+	set.Bool("cache", false, "")
+	set.Bool("state", false, "")
+	set.Bool("dry-run", false, "")
+	set.Set("cache", "true")
+	set.Set("state", "true")
+	set.Set("dry-run", "true")
+
+	captureStdout(t, func() error {
+		return handleCleanAction(cli.NewContext(cli.NewApp(), set, nil))
+	})
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		t.Errorf("dry run created %v", f.Name())
+	}
+}
@@ -0,0 +1,93 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/just-install/just-install/pkg/checksum"
+	"github.com/just-install/just-install/pkg/paths"
+)
+
+// fileHash is the digest of a file, as printed by the hash command.
+type fileHash struct {
+	Path      string `json:"path"`
+	Algorithm string `json:"algorithm"`
+	Digest    string `json:"digest"`
+}
+
+func handleHashAction(c *cli.Context) error {
+	algorithm := strings.ToLower(c.String("hash-algo"))
+	if algorithm == "" {
+		algorithm = checksum.SHA256
+	} else if !checksum.Supported(algorithm) {
+		return fmt.Errorf("unknown hash algorithm: %v", algorithm)
+	}
+
+	files := c.Args().Slice()
+	if len(files) == 0 {
+		var err error
+		if files, err = cachedFiles(); err != nil {
+			return err
+		}
+	}
+
+	var hashes []fileHash
+	for _, path := range files {
+		digest, err := checksum.FileWith(path, algorithm)
+		if err != nil {
+			return err
+		}
+
+		hashes = append(hashes, fileHash{path, algorithm, digest})
+	}
+
+	return printOutput(c, hashes, func() error {
+		// Same format as sha256sum and friends, so that the output can be checked with them.
+		for _, h := range hashes {
+			fmt.Printf("%v  %v\n", h.Digest, h.Path)
+		}
+
+		return nil
+	})
+}
+
+// cachedFiles returns the paths of the files in the cache directory, i.e. downloaded installers.
+func cachedFiles() ([]string, error) {
+	cacheDir, err := paths.CacheDirCreate()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := ioutil.ReadDir(cacheDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []string
+	for _, entry := range entries {
+		if entry.Mode().IsRegular() {
+			ret = append(ret, filepath.Join(cacheDir, entry.Name()))
+		}
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,60 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/just-install/just-install/pkg/justinstall"
+)
+
+func handleInfoAction(c *cli.Context) error {
+	fmt.Println("version:", version)
+	fmt.Printf("supported registry format: %v (schema version %v)\n", justinstall.RegistryVersion, justinstall.RegistrySchemaVersion)
+
+	channel, err := registryChannel(c)
+	if err != nil {
+		return err
+	}
+
+	if c.IsSet("registry") {
+		channel = "custom"
+	}
+	fmt.Println("channel:", channel)
+
+	src, err := registrySource(c)
+	if err != nil {
+		return err
+	}
+	fmt.Println("registry:", src)
+
+	cachePath, err := registryCachePath(c)
+	if err != nil {
+		return err
+	}
+	fmt.Println("registry cache:", cachePath)
+
+	if age, err := registryAge(c); err == nil {
+		fmt.Println("registry age:", age.Round(time.Second))
+	} else {
+		fmt.Println("registry age: not downloaded yet")
+	}
+
+	return nil
+}
@@ -16,48 +16,151 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/ungerik/go-dry"
 	"github.com/urfave/cli/v2"
 
+	"github.com/just-install/just-install/pkg/checksum"
+	"github.com/just-install/just-install/pkg/justinstall"
 	"github.com/just-install/just-install/pkg/platform"
+	"github.com/just-install/just-install/pkg/state"
 )
 
 func handleInstall(c *cli.Context) error {
+	if c.String("github") != "" {
+		return handleGitHubInstall(c)
+	}
+
+	pkgs, archs, versions, err := requestedPackages(c)
+	if err != nil {
+		return err
+	}
+
+	summary := newBatchSummary(pkgs)
+
+	return finishRun(c, summary, installPackages(c, pkgs, archs, versions, summary))
+}
+
+// handleGitHubInstall installs the latest release of the repository given with --github, as an
+// ad-hoc package that bypasses the registry.
+func handleGitHubInstall(c *cli.Context) error {
+	if c.Args().Present() || c.String("from-list") != "" {
+		return errors.New("--github cannot be combined with other packages")
+	}
+
+	defer applyDeadline(c)()
+
+	name, entry, err := justinstall.GitHubEntry(c.Context, c.String("github"), c.String("asset-pattern"), c.String("asset-kind"))
+	if err != nil {
+		return err
+	}
+
+	registry := &justinstall.Registry{Packages: map[string]justinstall.RegistryEntry{name: entry}}
+	summary := newBatchSummary([]string{name})
+
+	return finishRun(c, summary, installRegistryPackages(c, registry, []string{name}, nil, summary))
+}
+
+// finishRun reports the end of an installation run, with the given summary and outcome, writes it to
+// the --summary-file and runs the script given with --post-run-script. Returns the outcome of the
+// run, or how writing the summary or running the script failed.
+func finishRun(c *cli.Context, summary *batchSummary, err error) error {
+	events.runFinished(summary, err)
+
+	if path := c.String("summary-file"); path != "" {
+		if summaryErr := writeSummaryFile(path, summary, err); summaryErr != nil && err == nil {
+			return summaryErr
+		} else if summaryErr != nil {
+			log.Println("WARNING:", summaryErr)
+		}
+	}
+
+	if script := c.String("post-run-script"); script != "" && (err == nil || !c.Bool("no-post-on-failure")) {
+		if scriptErr := runPostRunScript(script, summary, err); scriptErr != nil && err == nil {
+			return scriptErr
+		} else if scriptErr != nil {
+			log.Println("WARNING:", scriptErr)
+		}
+	}
+
+	return err
+}
+
+// installPackages installs the given packages, recording the outcome for each of them in the given
+// summary. Packages in archs are installed for the given architecture, instead of the selected one,
+// and packages in versions in the given version, instead of the current one.
+func installPackages(c *cli.Context, pkgs []string, archs map[string]string, versions map[string]string, summary *batchSummary) error {
+	defer applyDeadline(c)()
+
+	endLoad := timings.Start("registry load")
+	registry, err := loadRegistry(c, c.Bool("force"))
+	endLoad()
+	if err != nil {
+		return err
+	}
+
+	if err := pinVersions(registry, versions); err != nil {
+		return err
+	}
+
+	return installRegistryPackages(c, registry, pkgs, archs, summary)
+}
+
+// applyDeadline makes the context of the run expire after --deadline, if given, as if the user
+// pressed Ctrl-C then. The returned function releases the resources of the new context.
+func applyDeadline(c *cli.Context) context.CancelFunc {
+	deadline := c.Duration("deadline")
+	if deadline <= 0 {
+		return func() {}
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context, deadline)
+	c.Context = ctx
+
+	return cancel
+}
+
+// installRegistryPackages is like installPackages, but installs the packages from the given
+// registry.
+func installRegistryPackages(c *cli.Context, registry *justinstall.Registry, pkgs []string, archs map[string]string, summary *batchSummary) error {
 	force := c.Bool("force")
 	onlyDownload := c.Bool("download-only")
 	onlyShims := c.Bool("shim")
+	outputDir := c.String("output-dir")
 
-	registry, err := loadRegistry(c, force)
+	arch, archReason, err := selectArch(c)
 	if err != nil {
 		return err
 	}
 
-	// Architecture selection
-	arch := c.String("arch")
-	switch arch {
-	case "":
-		if platform.Is64Bit() {
-			arch = "x86_64"
-		} else {
-			arch = "x86"
-		}
-	case "x86":
-		// Nothing to do
-	case "x86_64":
-		if !platform.Is64Bit() {
-			return errors.New("this machine cannot run 64-bit software")
-		}
-	default:
-		return fmt.Errorf("unknown architecture: %v", arch)
+	options, err := installOptions(c)
+	if err != nil {
+		return err
 	}
 
+	if c.Bool("explain") {
+		return explain(registry, pkgs, arch, archReason, archs, options)
+	}
+
+	release, err := acquireLock(c)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	// Check which packages might require an interactive installation
 	var interactive []string
 
-	for _, pkg := range c.Args().Slice() {
+	for _, pkg := range pkgs {
 		entry, ok := registry.Packages[pkg]
 		if !ok {
 			continue
@@ -68,6 +171,23 @@ func handleInstall(c *cli.Context) error {
 		}
 	}
 
+	installing := !onlyShims && !onlyDownload
+	skipped := map[string]bool{}
+
+	if installing && len(interactive) > 0 && c.Bool("skip-interactive") {
+		for _, pkg := range interactive {
+			log.Println("skipping", pkg, "since it might require user interaction")
+			skipped[pkg] = true
+			summary.Skipped = append(summary.Skipped, pkg)
+		}
+
+		interactive = nil
+	}
+
+	if installing && len(interactive) > 0 && c.Bool("no-interactive") {
+		return fmt.Errorf("these packages might require user interaction to complete their installation: %v", strings.Join(interactive, ", "))
+	}
+
 	if len(interactive) > 0 {
 		log.Println("these packages might require user interaction to complete their installation")
 
@@ -78,26 +198,278 @@ func handleInstall(c *cli.Context) error {
 		log.Println("")
 	}
 
-	// Install packages
+	st, err := state.Load()
+	if err != nil {
+		return err
+	}
+
+	// Refuse to install packages conflicting with each other or with installed ones
 	hasErrors := false
 
-	for _, pkg := range c.Args().Slice() {
-		entry, ok := registry.Packages[pkg]
+	if installing || onlyShims {
+		pkgConflicts := shimClashes(registry, pkgs, archs, arch)
+		if installing {
+			for pkg, err := range conflicts(registry, pkgs, st, c.Bool("uninstall-replaced")) {
+				pkgConflicts[pkg] = err
+			}
+		}
+
+		for _, pkg := range pkgs {
+			err, ok := pkgConflicts[pkg]
+			if !ok || skipped[pkg] {
+				continue
+			}
+
+			if force {
+				log.Printf("WARNING: %v %v, installing anyway", pkg, err)
+				continue
+			}
+
+			log.Printf("not installing %v: %v (see --force)", pkg, err)
+			skipped[pkg] = true
+			summary.Failed = append(summary.Failed, pkg)
+			hasErrors = true
+		}
+	}
+
+	if installing && c.Bool("confirm") && !c.Bool("yes") {
+		var toConfirm []string
+		for _, pkg := range pkgs {
+			if !skipped[pkg] {
+				toConfirm = append(toConfirm, pkg)
+			}
+		}
+
+		confirmed, err := confirmInstall(registry, toConfirm, st)
+		if err != nil {
+			return err
+		}
+
+		if !confirmed {
+			return errors.New("installation not confirmed")
+		}
+	}
+
+	// Install packages. Parallel-safe packages are handed to a pool of workers, if asked to, while
+	// all others are installed one at a time as usual.
+	var mutex sync.Mutex // Guards hasErrors, st and done, which workers update concurrently
 
-		if ok {
-			if onlyShims {
-				entry.CreateShims(arch)
-			} else if onlyDownload {
-				entry.DownloadInstaller(arch, force)
+	done := map[string]bool{}
+
+	// failed reports the failure of the given action on a package, returning whether the package is
+	// done with. Failures due to the run being interrupted are not reported, the package is skipped.
+	failed := func(pkg string, action string, err error) bool {
+		if ctxErr := c.Context.Err(); ctxErr != nil && errors.Is(err, ctxErr) {
+			return false
+		}
+
+		log.Printf("error %v %v: %v", action, pkg, err)
+		events.emit(event{Event: "error", Package: pkg, Error: fmt.Sprintf("error %v %v: %v", action, pkg, err)})
+
+		mutex.Lock()
+		hasErrors = true
+		summary.Failed = append(summary.Failed, pkg)
+		mutex.Unlock()
+
+		return true
+	}
+
+	lockedUninstallReplaced := func(pkg string) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		return uninstallReplaced(c.Context, registry, pkg, st, options, c.Bool("uninstall-replaced"))
+	}
+
+	// reinstall uninstalls the given package, with --reinstall, if it was installed for the given
+	// architecture by just-install and it knows how to uninstall it. It runs once the new installer is
+	// downloaded and verified, so that a failed download doesn't leave the package uninstalled.
+	reinstall := func(pkg string, entry *justinstall.RegistryEntry, arch string) error {
+		mutex.Lock()
+		installed, ok := st.Packages[pkg]
+		mutex.Unlock()
+
+		if !c.Bool("reinstall") || !ok || !installed.Has(arch) {
+			return nil
+		}
+
+		if !entry.CanUninstall(arch) {
+			log.Printf("WARNING: cannot uninstall %v, reinstalling it over the existing installation", pkg)
+			return nil
+		}
+
+		log.Println("uninstalling", pkg, "before installing it again")
+		if err := entry.Uninstall(c.Context, arch, uninstallOptions(options, installed)); err != nil {
+			return err
+		}
+
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		summary.Uninstalled = append(summary.Uninstalled, pkg)
+		st.ForgetArch(pkg, arch)
+		if err := st.Save(); err != nil {
+			log.Println("WARNING: could not record the removal of", pkg+":", err)
+		}
+
+		return nil
+	}
+
+	fetched, cached := 0, 0
+
+	install := func(pkg string, entry justinstall.RegistryEntry) {
+		arch := arch
+		if pkgArch, ok := archs[pkg]; ok {
+			arch = pkgArch
+		}
+
+		finished := true
+		started := time.Now()
+		defer func() {
+			mutex.Lock()
+			done[pkg] = finished
+			summary.Runs[pkg] = packageRun{arch, entry.Version, time.Since(started)}
+			mutex.Unlock()
+		}()
+
+		events.emit(event{Event: "package-started", Package: pkg, Arch: arch})
+		options := events.withDownloadProgress(pkg, arch, options)
+
+		if onlyShims {
+			entry.CreateShims(arch)
+			summary.Succeeded = append(summary.Succeeded, pkg)
+			events.emit(event{Event: "install-finished", Package: pkg, Arch: arch, Status: "shims"})
+		} else if onlyDownload {
+			if downloaded, err := downloadOnly(c.Context, &entry, arch, options, outputDir); err != nil {
+				finished = failed(pkg, "downloading", err)
+			} else if downloaded {
+				fetched++
+				summary.Succeeded = append(summary.Succeeded, pkg)
+				events.emit(event{Event: "install-finished", Package: pkg, Arch: arch, Status: "downloaded"})
 			} else {
-				if err := entry.JustInstall(arch, force); err != nil {
-					log.Printf("error installing %v: %v", pkg, err)
-					hasErrors = true
-				}
+				cached++
+				summary.Succeeded = append(summary.Succeeded, pkg)
+				events.emit(event{Event: "install-finished", Package: pkg, Arch: arch, Status: "cached"})
 			}
+		} else if err := lockedUninstallReplaced(pkg); err != nil {
+			finished = failed(pkg, "installing", err)
 		} else {
+			pkgOptions, closeLog := withInstallerLog(c, pkg, options)
+			pkgOptions = recordVerification(pkgOptions, summary, pkg, &mutex)
+
+			var identity string
+			pkgOptions = withIdentity(pkgOptions, &identity)
+
+			var uninstallErr error
+			pkgOptions.Reinstall = c.Bool("reinstall")
+			pkgOptions.BeforeInstall = func() error {
+				uninstallErr = reinstall(pkg, &entry, arch)
+				return uninstallErr
+			}
+
+			err := entry.JustInstall(c.Context, arch, pkgOptions)
+			closeLog()
+
+			if uninstallErr != nil {
+				finished = failed(pkg, "uninstalling", err)
+			} else if errors.Is(err, justinstall.ErrSkipped) {
+				log.Printf("not installing %v: %v", pkg, err)
+
+				mutex.Lock()
+				summary.Skipped = append(summary.Skipped, pkg)
+				mutex.Unlock()
+				events.emit(event{Event: "install-finished", Package: pkg, Arch: arch, Status: "skipped"})
+			} else if err != nil {
+				finished = failed(pkg, "installing", err)
+			} else {
+				events.emit(event{Event: "install-finished", Package: pkg, Arch: arch, Status: "installed"})
+
+				mutex.Lock()
+				defer mutex.Unlock()
+
+				summary.Succeeded = append(summary.Succeeded, pkg)
+				if options.Prefix != "" {
+					// The prefix manifest records it instead, the package isn't installed system-wide
+					if err := entry.RecordInPrefix(options.Prefix, pkg, arch); err != nil {
+						log.Println("WARNING: could not record the installation of", pkg+":", err)
+					}
+				} else {
+					st.Record(pkg, entry.Version, arch)
+					if identity != "" {
+						st.SetIdentity(pkg, identity)
+					}
+					if err := st.Save(); err != nil {
+						log.Println("WARNING: could not record the installation of", pkg+":", err)
+					}
+				}
+			}
+		}
+	}
+
+	parallel := make(chan string, len(pkgs))
+	var workers sync.WaitGroup
+
+	for i := 0; installing && i < c.Int("parallel-installs"); i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+
+			for pkg := range parallel {
+				if c.Context.Err() == nil {
+					install(pkg, registry.Packages[pkg])
+				}
+			}
+		}()
+	}
+
+	for _, pkg := range pkgs {
+		if c.Context.Err() != nil {
+			break
+		}
+
+		if skipped[pkg] {
+			continue
+		}
+
+		entry, ok := registry.Packages[pkg]
+		if !ok {
 			log.Println("WARNING: unknown package", pkg)
+			skipped[pkg] = true
+			summary.Skipped = append(summary.Skipped, pkg)
+			continue
+		}
+
+		// Replaced packages are uninstalled with their own (possibly global) uninstaller.
+		if installing && c.Int("parallel-installs") > 0 && entry.ParallelSafe() && len(entry.Replaces) == 0 {
+			parallel <- pkg
+			continue
+		}
+
+		install(pkg, entry)
+	}
+
+	close(parallel)
+	workers.Wait()
+
+	if onlyDownload {
+		log.Printf("downloaded %v installer(s), skipped %v already cached", fetched, cached)
+	}
+
+	if c.Context.Err() != nil {
+		var remaining []string
+		for _, pkg := range pkgs {
+			if !done[pkg] && !skipped[pkg] {
+				remaining = append(remaining, pkg)
+			}
 		}
+
+		summary.Skipped = append(summary.Skipped, remaining...)
+
+		if errors.Is(c.Context.Err(), context.DeadlineExceeded) {
+			return cli.Exit(fmt.Sprintf("deadline exceeded, these packages were skipped: %v", strings.Join(remaining, ", ")), exitDeadline)
+		}
+
+		return cli.Exit(fmt.Sprintf("interrupted, these packages were skipped: %v", strings.Join(remaining, ", ")), exitInterrupted)
 	}
 
 	if hasErrors {
@@ -106,3 +478,198 @@ func handleInstall(c *cli.Context) error {
 
 	return nil
 }
+
+// installOptions returns the options to install packages with, as given on the command line.
+func installOptions(c *cli.Context) (*justinstall.Options, error) {
+	options := &justinstall.Options{
+		CleanupAfter:       c.Bool("cleanup-after"),
+		DownloadTimeout:    c.Duration("download-timeout"),
+		Force:              c.Bool("force"),
+		ForceAssociations:  c.Bool("force-associations"),
+		HashAlgorithm:      c.String("hash-algo"),
+		MaxRedirects:       c.Int("max-redirects"),
+		MirrorStrategy:     c.String("mirror-strategy"),
+		NoContentCheck:     c.Bool("no-content-check"),
+		NoFallbackArch:     c.Bool("no-fallback-arch"),
+		NoShims:            c.Bool("no-shim"),
+		Prefix:             c.String("prefix"),
+		Profile:            c.String("profile"),
+		RequireStrongHash:  c.Bool("require-strong-hash"),
+		Segments:           c.Int("connections"),
+		Timings:            timings,
+		VerifyAfterInstall: c.Bool("verify-after-install"),
+	}
+
+	if options.HashAlgorithm != "" && !checksum.Supported(options.HashAlgorithm) {
+		return nil, fmt.Errorf("unknown hash algorithm: %v", options.HashAlgorithm)
+	}
+
+	if options.NoShims && c.Bool("shim") {
+		return nil, errors.New("--shim and --no-shim cannot be used together")
+	}
+
+	if c.Bool("reinstall") && (c.Bool("download-only") || c.Bool("shim")) {
+		return nil, errors.New("--reinstall cannot be used with --download-only or --shim")
+	}
+
+	if options.CleanupAfter && c.Bool("download-only") {
+		return nil, errors.New("--cleanup-after and --download-only cannot be used together")
+	}
+
+	if options.Prefix != "" {
+		if c.Bool("reinstall") || c.Bool("shim") || c.Bool("uninstall-replaced") {
+			return nil, errors.New("--prefix cannot be used with --reinstall, --shim or --uninstall-replaced")
+		}
+
+		prefix, err := filepath.Abs(options.Prefix)
+		if err != nil {
+			return nil, fmt.Errorf("invalid prefix: %w", err)
+		}
+		options.Prefix = prefix
+	}
+
+	if !justinstall.SupportedMirrorStrategy(options.MirrorStrategy) {
+		return nil, fmt.Errorf("unknown mirror strategy: %v", options.MirrorStrategy)
+	}
+
+	switch c.String("on-interrupt") {
+	case "", "wait":
+		// Nothing to do
+	case "kill":
+		options.KillOnCancel = true
+	default:
+		return nil, fmt.Errorf("unknown interrupt policy: %v", c.String("on-interrupt"))
+	}
+
+	return options, nil
+}
+
+// withIdentity returns options that store the identity of the MSIX package being installed, if
+// any, in the given string.
+func withIdentity(options *justinstall.Options, identity *string) *justinstall.Options {
+	ret := *options
+	ret.Identified = func(id string) { *identity = id }
+
+	return &ret
+}
+
+// selectArch returns the architecture to install packages for, along with the reason why it was
+// chosen.
+func selectArch(c *cli.Context) (string, string, error) {
+	arch := c.String("arch")
+	switch arch {
+	case "":
+		native, how, err := platform.NativeArch()
+		switch {
+		case err != nil:
+			return "", "", fmt.Errorf("%w, select the architecture to install packages for with --arch", err)
+		case native == "aarch64":
+			// The registry has no ARM64 installers. x86_64 ones run under emulation on Windows 11,
+			// earlier versions need --arch x86.
+			return "x86_64", "detected an ARM64 version of Windows: " + how, nil
+		case native == "x86_64":
+			return native, "detected a 64-bit version of Windows: " + how, nil
+		default:
+			return native, "detected a 32-bit version of Windows: " + how, nil
+		}
+	default:
+		if err := checkArch(arch); err != nil {
+			return "", "", err
+		}
+
+		return arch, "requested with --arch", nil
+	}
+}
+
+// architectures are the values accepted by --arch. The registry only has x86 and x86_64 installers,
+// so "aarch64" would only fail later, once installing, and nothing installs packages for all
+// architectures at once, which "all" would select.
+var architectures = []string{"x86", "x86_64"}
+
+// knownArch returns an error if the given architecture isn't one of the known ones.
+func knownArch(arch string) error {
+	for _, a := range architectures {
+		if a == arch {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown architecture %q, valid values are: %v", arch, strings.Join(architectures, ", "))
+}
+
+// checkArch returns an error if packages cannot be installed for the given architecture.
+func checkArch(arch string) error {
+	if err := knownArch(arch); err != nil {
+		return err
+	}
+
+	// Trust the user on unsupported architectures, they asked for this one explicitly.
+	if native, _, err := platform.NativeArch(); err == nil && arch == "x86_64" && native == "x86" {
+		return errors.New("this machine cannot run 64-bit software")
+	}
+
+	return nil
+}
+
+// explain prints the decisions that would be taken to install the given packages.
+func explain(registry *justinstall.Registry, pkgs []string, arch string, archReason string, archs map[string]string, options *justinstall.Options) error {
+	for _, pkg := range pkgs {
+		fmt.Println(pkg)
+
+		arch, archReason := arch, archReason
+		if pkgArch, ok := archs[pkg]; ok {
+			arch, archReason = pkgArch, "requested in the package list"
+		}
+
+		entry, ok := registry.Packages[pkg]
+		if !ok {
+			fmt.Println("    unknown package")
+			continue
+		}
+
+		fmt.Printf("    architecture is %v (%v)\n", arch, archReason)
+		fmt.Printf("    package version is %v\n", entry.Version)
+
+		for _, line := range entry.Explain(arch, options) {
+			fmt.Println("    " + line)
+		}
+	}
+
+	return nil
+}
+
+// downloadOnly downloads the installer for the given entry to the same cache used when installing
+// it, so that a later installation doesn't download it again. Installers that are already cached,
+// with a valid checksum, are not downloaded again unless `Force` is set. If outputDir is not empty,
+// the installer is also copied there. Returns whether the installer was downloaded.
+func downloadOnly(ctx context.Context, entry *justinstall.RegistryEntry, arch string, options *justinstall.Options, outputDir string) (bool, error) {
+	downloadedFile, cached, downloaded := "", false, false
+	if !options.Force {
+		downloadedFile, cached = entry.CachedInstaller(ctx, arch, options)
+	}
+
+	if cached {
+		log.Println("installer already cached at", downloadedFile)
+	} else {
+		var err error
+		if downloadedFile, err = entry.DownloadInstaller(ctx, arch, options); err != nil {
+			return false, err
+		}
+
+		log.Println("cached installer at", downloadedFile)
+		downloaded = true
+	}
+
+	if outputDir == "" {
+		return downloaded, nil
+	}
+
+	if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
+		return downloaded, fmt.Errorf("could not create output directory: %w", err)
+	}
+
+	destination := filepath.Join(outputDir, filepath.Base(downloadedFile))
+	log.Println("copying to", destination)
+
+	return downloaded, dry.FileCopy(downloadedFile, destination)
+}
@@ -16,9 +16,14 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/urfave/cli/v2"
+
+	"github.com/just-install/just-install/pkg/justinstall"
 )
 
 func handleListAction(c *cli.Context) error {
@@ -27,11 +32,122 @@ func handleListAction(c *cli.Context) error {
 		return err
 	}
 
-	packageNames := registry.SortedPackageNames()
+	if c.Bool("short") && c.Bool("health") {
+		return errors.New("--short and --health cannot be used together")
+	}
+
+	if c.Bool("categories") {
+		return listCategories(c, registry)
+	}
+
+	var packageNames []string
+	for _, name := range registry.SortedPackageNames() {
+		if category := c.String("category"); category == "" || strings.EqualFold(registry.Packages[name].Category, category) {
+			packageNames = append(packageNames, name)
+		}
+	}
+
+	if c.Bool("short") {
+		for _, name := range packageNames {
+			fmt.Println(name)
+		}
+
+		return nil
+	}
+
+	type packageJSON struct {
+		Name     string         `json:"name"`
+		Version  string         `json:"version"`
+		Category string         `json:"category,omitempty"`
+		Health   *packageHealth `json:"health,omitempty"`
+	}
 
+	packages := []packageJSON{}
 	for _, name := range packageNames {
-		fmt.Printf("%35v - %v\n", name, registry.Packages[name].Version)
+		entry := registry.Packages[name]
+		packages = append(packages, packageJSON{Name: name, Version: entry.Version, Category: entry.Category})
+	}
+
+	if !c.Bool("health") {
+		return printOutput(c, packages, func() error {
+			for _, p := range packages {
+				fmt.Printf("%35v - %v\n", p.Name, p.Version)
+			}
+
+			return nil
+		})
+	}
+
+	age, err := registryAge(c)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	health := computeHealth(registry, packageNames, age)
+	for i := range packages {
+		if h, ok := health[packages[i].Name]; ok {
+			packages[i].Health = &h
+		}
+	}
+
+	return printOutput(c, packages, func() error {
+		for _, p := range packages {
+			if p.Health == nil {
+				fmt.Printf("%35v - %-20v (health: n/a)\n", p.Name, p.Version)
+				continue
+			}
+
+			h := p.Health
+			fmt.Printf("%35v - %-20v (health: %3v, %v/%v URLs reachable, %v/%v checksums)\n", p.Name, p.Version, h.Score, h.Reachable, h.Total, h.Checksums, h.Total)
+		}
+
+		return nil
+	})
+}
+
+// listCategories prints the categories of the packages in the registry, with how many packages are in
+// each. Categories differing only in case are counted as one, named as in the first package found.
+func listCategories(c *cli.Context, registry *justinstall.Registry) error {
+	type categoryJSON struct {
+		Name     string `json:"name"`
+		Packages int    `json:"packages"`
+	}
+
+	byName := make(map[string]*categoryJSON)
+	for _, name := range registry.SortedPackageNames() {
+		category := registry.Packages[name].Category
+		if category == "" {
+			continue
+		}
+
+		key := strings.ToLower(category)
+		if _, ok := byName[key]; !ok {
+			byName[key] = &categoryJSON{Name: category}
+		}
+		byName[key].Packages++
+	}
+
+	categories := []categoryJSON{}
+	for _, category := range byName {
+		categories = append(categories, *category)
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		return strings.ToLower(categories[i].Name) < strings.ToLower(categories[j].Name)
+	})
+
+	if c.Bool("short") {
+		for _, category := range categories {
+			fmt.Println(category.Name)
+		}
+
+		return nil
+	}
+
+	return printOutput(c, categories, func() error {
+		for _, category := range categories {
+			fmt.Printf("%35v - %v package(s)\n", category.Name, category.Packages)
+		}
+
+		return nil
+	})
 }
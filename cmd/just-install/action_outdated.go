@@ -0,0 +1,87 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/just-install/just-install/pkg/state"
+)
+
+func handleOutdatedAction(c *cli.Context) error {
+	registry, err := loadRegistry(c, c.Bool("force"))
+	if err != nil {
+		return err
+	}
+
+	st, err := state.Load()
+	if err != nil {
+		return err
+	}
+
+	type outdatedJSON struct {
+		Name      string `json:"name"`
+		Installed string `json:"installed"`
+		Available string `json:"available"`
+	}
+
+	outdated := []outdatedJSON{}
+	for _, name := range sortedStatePackages(st) {
+		entry, ok := registry.Packages[name]
+		if !ok {
+			// No longer in the registry, nothing to upgrade to
+			continue
+		}
+
+		// A package whose upgrade failed for some of its architectures is still outdated.
+		installed := st.Packages[name]
+		version := installed.Version
+		for _, arch := range installed.Architectures() {
+			if v := installed.VersionOf(arch); v != entry.Version {
+				version = v
+			}
+		}
+
+		if version != entry.Version {
+			outdated = append(outdated, outdatedJSON{name, version, entry.Version})
+		}
+	}
+
+	err = printOutput(c, outdated, func() error {
+		if len(outdated) == 0 {
+			return nil
+		}
+
+		fmt.Printf("%35v   %-20v %v\n", "package", "installed", "available")
+
+		for _, o := range outdated {
+			fmt.Printf("%35v   %-20v %v\n", o.Name, o.Installed, o.Available)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(outdated) > 0 {
+		return cli.Exit("", exitOutdated)
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/just-install/just-install/pkg/checksum"
+	"github.com/just-install/just-install/pkg/justinstall"
+)
+
+// planVersion is the version of the format of plans, bumped each time it changes in a way that
+// older versions of apply cannot handle.
+const planVersion = 1
+
+// installPlan is what would be installed, as printed by the plan command and read by apply.
+type installPlan struct {
+	Version    int              `json:"version"`    // See planVersion
+	Arch       string           `json:"arch"`       // Selected architecture
+	ArchReason string           `json:"archReason"` // Why it was selected
+	Packages   []plannedPackage `json:"packages"`
+}
+
+// plannedPackage is a package of an installPlan.
+type plannedPackage struct {
+	Name      string                     `json:"name"`
+	Arch      string                     `json:"arch"`              // Requested architecture
+	Version   string                     `json:"version,omitempty"` // Requested version, as in PACKAGE@VERSION
+	Installer *justinstall.InstallerPlan `json:"installer,omitempty"`
+	Error     string                     `json:"error,omitempty"` // Why the package cannot be installed
+}
+
+func handlePlanAction(c *cli.Context) error {
+	pkgs, archs, versions, err := requestedPackages(c)
+	if err != nil {
+		return err
+	}
+
+	if len(pkgs) == 0 {
+		return errors.New("no package given")
+	}
+
+	registry, err := loadRegistry(c, c.Bool("force"))
+	if err != nil {
+		return err
+	}
+
+	if err := pinVersions(registry, versions); err != nil {
+		return err
+	}
+
+	arch, archReason, err := selectArch(c)
+	if err != nil {
+		return err
+	}
+
+	options, err := installOptions(c)
+	if err != nil {
+		return err
+	}
+	options.CacheOnly = c.Bool("registry-only")
+
+	plan := installPlan{Version: planVersion, Arch: arch, ArchReason: archReason, Packages: []plannedPackage{}}
+	failures := 0
+	var missing []string // With --registry-only, packages whose installer isn't cached
+
+	for _, pkg := range pkgs {
+		planned := plannedPackage{Name: pkg, Arch: arch, Version: versions[pkg]}
+		if pkgArch, ok := archs[pkg]; ok {
+			planned.Arch = pkgArch
+		}
+
+		if entry, ok := registry.Packages[pkg]; !ok {
+			err = fmt.Errorf("%w: %v", justinstall.ErrUnknownPackage, pkg)
+		} else {
+			planned.Installer, err = entry.Plan(c.Context, planned.Arch, options)
+		}
+
+		if err != nil {
+			planned.Error = err.Error()
+			failures++
+		} else if options.CacheOnly && !planned.Installer.Cached {
+			missing = append(missing, pkg)
+		}
+
+		plan.Packages = append(plan.Packages, planned)
+	}
+
+	err = printOutput(c, plan, func() error {
+		fmt.Printf("architecture is %v (%v)\n", plan.Arch, plan.ArchReason)
+
+		for _, p := range plan.Packages {
+			if p.Installer == nil {
+				fmt.Printf("%v: %v\n", p.Name, p.Error)
+				continue
+			}
+
+			cached := ""
+			if p.Installer.Unknown {
+				cached = ", found by a resolver"
+			} else if options.CacheOnly && p.Installer.Cached {
+				cached = ", cached"
+			} else if options.CacheOnly {
+				cached = ", not cached"
+			}
+
+			fmt.Printf("%v %v (%v, %v%v): %v\n", p.Name, p.Installer.Version, p.Installer.Arch, p.Installer.Kind, cached, p.Installer.URL)
+			if algorithm := checksum.Strongest(p.Installer.Hashes); algorithm != "" {
+				fmt.Printf("    %v %v\n", algorithm, p.Installer.Hashes[algorithm])
+			}
+			for _, dependency := range p.Installer.Dependencies {
+				fmt.Printf("    with %v\n", dependency)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if failures > 0 {
+		return fmt.Errorf("%v package(s) cannot be installed", failures)
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("installers missing from the cache: %v", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,193 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"time"
+
+	"github.com/ungerik/go-dry"
+	"github.com/urfave/cli/v2"
+
+	"github.com/just-install/just-install/pkg/justinstall"
+)
+
+func handleRegistryDumpAction(c *cli.Context) error {
+	names := c.Args().Slice()
+	if len(names) == 0 {
+		return errors.New("no package given")
+	}
+	if c.Bool("entry") && len(names) > 1 {
+		return errors.New("--entry only works with a single package")
+	}
+
+	path, err := registryPath(c, c.Bool("force"), false)
+	if err != nil {
+		return err
+	}
+
+	registryFile, err := justinstall.ReadRegistryFile(path)
+	if err != nil {
+		return err
+	}
+
+	for _, overlay := range c.StringSlice("set") {
+		if err := registryFile.ApplyOverlay(overlay); err != nil {
+			return err
+		}
+	}
+
+	var data []byte
+	if c.Bool("entry") {
+		data, err = registryFile.EntryBytes(names[0])
+	} else if registryFile, err = registryFile.Extract(names...); err == nil {
+		data, err = registryFile.Bytes()
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = os.Stdout.Write(data)
+	return err
+}
+
+func handleRegistryFormatAction(c *cli.Context) error {
+	path := c.Args().First()
+	if path == "" && c.IsSet("registry") {
+		path = c.String("registry")
+	}
+	if path == "" || !dry.FileExists(path) {
+		return errors.New("formatting requires a local registry file (see --registry)")
+	}
+
+	registryFile, err := justinstall.ReadRegistryFile(path)
+	if err != nil {
+		return err
+	}
+
+	data, err := registryFile.Bytes()
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("stdout") {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+
+	if current, err := ioutil.ReadFile(path); err == nil && bytes.Equal(current, data) {
+		log.Println(path, "is already formatted")
+		return nil
+	}
+
+	if err := registryFile.Write(path); err != nil {
+		return err
+	}
+
+	log.Println("formatted", path)
+
+	return nil
+}
+
+func handleRegistryDiffAction(c *cli.Context) error {
+	current, err := loadRegistry(c, c.Bool("force"))
+	if err != nil {
+		return err
+	}
+
+	cachePath, err := registryCachePath(c)
+	if err != nil {
+		return err
+	}
+
+	snapshots, err := registrySnapshots(cachePath)
+	if err != nil {
+		return err
+	}
+
+	if len(snapshots) == 0 {
+		return errors.New("no earlier version of the registry to compare with, the history starts with the next update")
+	}
+
+	var changes []justinstall.Change
+	var since registrySnapshot
+
+	if c.IsSet("since") {
+		// Compare with the registry in use at that time: the latest snapshot taken before then.
+		target := time.Now().Add(-c.Duration("since"))
+
+		since = snapshots[0]
+		for _, s := range snapshots {
+			if !s.Time.After(target) {
+				since = s
+			}
+		}
+
+		if since.Time.After(target) {
+			log.Printf("WARNING: the registry history only goes back to %v", since.Time.Local().Format(time.RFC1123))
+		}
+
+		if changes, err = diffRegistrySnapshot(since, current); err != nil {
+			return err
+		}
+	} else {
+		// Compare with the latest snapshot that differs from the registry in use.
+		for i := len(snapshots) - 1; i >= 0 && len(changes) == 0; i-- {
+			since = snapshots[i]
+			if changes, err = diffRegistrySnapshot(since, current); err != nil {
+				return err
+			}
+		}
+	}
+
+	log.Println("comparing with the registry of", since.Time.Local().Format(time.RFC1123))
+
+	if changes == nil {
+		changes = []justinstall.Change{}
+	}
+
+	return printOutput(c, changes, func() error {
+		for _, change := range changes {
+			switch change.Kind {
+			case justinstall.ChangeAdded:
+				fmt.Printf("+ %v %v\n", change.Package, change.NewVersion)
+			case justinstall.ChangeRemoved:
+				fmt.Printf("- %v %v\n", change.Package, change.OldVersion)
+			case justinstall.ChangeVersion:
+				fmt.Printf("~ %v %v -> %v\n", change.Package, change.OldVersion, change.NewVersion)
+			default:
+				fmt.Printf("~ %v %v (entry changed)\n", change.Package, change.NewVersion)
+			}
+		}
+
+		return nil
+	})
+}
+
+// diffRegistrySnapshot returns the changes from the given snapshot to the given registry.
+func diffRegistrySnapshot(snapshot registrySnapshot, registry *justinstall.Registry) ([]justinstall.Change, error) {
+	old, err := justinstall.LoadRegistry(snapshot.Path)
+	if err != nil {
+		return nil, fmt.Errorf("could not load the registry of %v: %w", snapshot.Time.Local().Format(time.RFC1123), err)
+	}
+
+	return justinstall.DiffRegistries(old, registry), nil
+}
@@ -0,0 +1,176 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ungerik/go-dry"
+	"github.com/urfave/cli/v2"
+
+	"github.com/just-install/just-install/pkg/checksum"
+	"github.com/just-install/just-install/pkg/fetch"
+	"github.com/just-install/just-install/pkg/paths"
+	"github.com/just-install/just-install/pkg/platform"
+	"github.com/just-install/just-install/pkg/resolver"
+)
+
+// releaseRepository is the GitHub repository where just-install is released.
+const releaseRepository = "just-install/just-install"
+
+func handleSelfUpdateAction(c *cli.Context) error {
+	latest, err := resolver.GitHubLatestRelease(releaseRepository, &resolver.Options{Context: c.Context, Force: c.Bool("force")})
+	if err != nil {
+		return fmt.Errorf("could not check for updates: %w", err)
+	}
+
+	latestVersion := strings.TrimPrefix(latest.TagName, "v")
+	if !isNewerVersion(latestVersion, version) && !c.Bool("force") {
+		fmt.Println("just-install is up to date:", version)
+		return nil
+	}
+
+	arch, _, err := selectArch(c)
+	if err != nil {
+		return err
+	}
+
+	// Prefer an architecture-specific build, the 32-bit one runs everywhere.
+	assetName := "just-install-" + arch + ".exe"
+	assetURL, ok := latest.Asset(assetName)
+	if !ok {
+		assetName = "just-install.exe"
+		if assetURL, ok = latest.Asset(assetName); !ok {
+			return fmt.Errorf("release %v has no build of just-install for this machine", latest.TagName)
+		}
+	}
+
+	checksumURL, ok := latest.Asset(assetName + ".sha256")
+	if !ok {
+		return fmt.Errorf("release %v has no checksum for %v, refusing to update", latest.TagName, assetName)
+	}
+
+	if c.Bool("dry-run") {
+		fmt.Printf("just-install %v is available (running %v): %v\n", latestVersion, version, assetURL)
+		return nil
+	}
+
+	release, err := acquireLock(c)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	tempDir, err := paths.TempDirCreate()
+	if err != nil {
+		return fmt.Errorf("could not create temporary directory: %w", err)
+	}
+
+	downloaded, err := fetch.Fetch(assetURL, &fetch.Options{Context: c.Context, Destination: filepath.Join(tempDir, assetName), Overwrite: true, Progress: true})
+	if err != nil {
+		return fmt.Errorf("could not download %v: %w", assetName, err)
+	}
+	defer os.Remove(downloaded)
+
+	checksumFile, err := fetch.Fetch(checksumURL, &fetch.Options{Context: c.Context, Destination: filepath.Join(tempDir, assetName+".sha256"), Overwrite: true})
+	if err != nil {
+		return fmt.Errorf("could not download the checksum of %v: %w", assetName, err)
+	}
+	defer os.Remove(checksumFile)
+
+	// Checksum files are in the `sha256sum` format: "<digest>  <file name>"
+	checksumData, err := dry.FileGetString(checksumFile)
+	if err != nil {
+		return err
+	}
+
+	fields := strings.Fields(checksumData)
+	if len(fields) == 0 {
+		return fmt.Errorf("the checksum of %v is empty", assetName)
+	}
+
+	if err := checksum.Verify(downloaded, fields[0]); err != nil {
+		return err
+	}
+
+	if err := replaceExecutable(downloaded); err != nil {
+		return fmt.Errorf("could not replace just-install: %w", err)
+	}
+
+	log.Println("updated just-install from", version, "to", latestVersion)
+
+	return nil
+}
+
+// isNewerVersion returns whether the available version is newer than the current one. Development
+// builds, whose version isn't a number, are always considered outdated.
+func isNewerVersion(available string, current string) bool {
+	a, err := platform.ParseVersion(available)
+	if err != nil {
+		return false
+	}
+
+	c, err := platform.ParseVersion(strings.TrimPrefix(current, "v"))
+	if err != nil {
+		return true
+	}
+
+	return a.Compare(c) > 0
+}
+
+// replaceExecutable replaces the running executable with the given file. Windows doesn't allow
+// overwriting a running executable but allows renaming it, so the current one is moved out of the
+// way first and deleted on the next run (see removeOldExecutable).
+func replaceExecutable(replacement string) error {
+	current, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	old := current + ".old"
+	os.Remove(old)
+
+	if err := os.Rename(current, old); err != nil {
+		return err
+	}
+
+	if err := dry.FileCopy(replacement, current); err != nil {
+		// Put the previous executable back in place
+		if rollbackErr := os.Rename(old, current); rollbackErr != nil {
+			return fmt.Errorf("%v, and could not restore the previous executable from %v: %w", err, old, rollbackErr)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// removeOldExecutable removes the executable left behind by a previous self-update, if any.
+func removeOldExecutable() {
+	current, err := os.Executable()
+	if err != nil {
+		return
+	}
+
+	if old := current + ".old"; dry.FileExists(old) {
+		os.Remove(old)
+	}
+}
@@ -0,0 +1,114 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/urfave/cli/v2"
+)
+
+func handleShowAction(c *cli.Context) error {
+	if c.Args().Len() != 1 {
+		return errors.New("show requires exactly one package name")
+	}
+
+	registry, err := loadRegistry(c, c.Bool("force"))
+	if err != nil {
+		return err
+	}
+
+	name := c.Args().First()
+	entry, err := registry.Package(name)
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("list-archs") {
+		return printOutput(c, entry.Architectures(), func() error {
+			for _, arch := range entry.Architectures() {
+				fmt.Println(arch)
+			}
+
+			return nil
+		})
+	}
+
+	type installerJSON struct {
+		Description string            `json:"description"`
+		URL         string            `json:"url"`
+		Checksum    string            `json:"checksum,omitempty"`
+		Hashes      map[string]string `json:"hashes,omitempty"`
+	}
+
+	type showJSON struct {
+		Name          string          `json:"name"`
+		Version       string          `json:"version"`
+		Category      string          `json:"category,omitempty"`
+		Kind          string          `json:"kind"`
+		Interactive   bool            `json:"interactive"`
+		Architectures []string        `json:"architectures"`
+		Installers    []installerJSON `json:"installers"`
+	}
+
+	info := showJSON{
+		Name:          name,
+		Version:       entry.Version,
+		Category:      entry.Category,
+		Kind:          entry.Installer.Kind,
+		Interactive:   entry.Installer.Interactive,
+		Architectures: entry.Architectures(),
+	}
+
+	for _, u := range entry.InstallerURLs() {
+		info.Installers = append(info.Installers, installerJSON{u.Description, u.URL, u.Checksum, u.Hashes})
+	}
+
+	return printOutput(c, info, func() error {
+		fmt.Println("name:         ", info.Name)
+		fmt.Println("version:      ", info.Version)
+		if info.Category != "" {
+			fmt.Println("category:     ", info.Category)
+		}
+		fmt.Println("kind:         ", info.Kind)
+		fmt.Println("interactive:  ", info.Interactive)
+		fmt.Println("architectures:", info.Architectures)
+		fmt.Println("installers:")
+
+		for _, installer := range info.Installers {
+			fmt.Printf("    %v: %v\n", installer.Description, installer.URL)
+			for _, algorithm := range sortedKeys(installer.Hashes) {
+				fmt.Printf("        %v %v\n", algorithm, installer.Hashes[algorithm])
+			}
+		}
+
+		return nil
+	})
+}
+
+// sortedKeys returns the keys of the given map, sorted alphabetically.
+func sortedKeys(m map[string]string) []string {
+	var ret []string
+	for k := range m {
+		ret = append(ret, k)
+	}
+
+	sort.Strings(ret)
+
+	return ret
+}
@@ -0,0 +1,113 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"log"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/just-install/just-install/pkg/justinstall"
+	"github.com/just-install/just-install/pkg/state"
+)
+
+func handleUninstallAction(c *cli.Context) error {
+	if c.Args().Len() == 0 {
+		return errors.New("uninstall requires at least one package name")
+	}
+
+	registry, err := loadRegistry(c, c.Bool("force"))
+	if err != nil {
+		return err
+	}
+
+	release, err := acquireLock(c)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	st, err := state.Load()
+	if err != nil {
+		return err
+	}
+
+	options := &justinstall.Options{Timings: timings}
+	hasErrors := false
+
+	for _, pkg := range c.Args().Slice() {
+		if c.Context.Err() != nil {
+			break
+		}
+
+		entry, ok := registry.Packages[pkg]
+		if !ok {
+			log.Println("WARNING: unknown package", pkg)
+			continue
+		}
+
+		// Uninstall what we installed, falling back to what we would install for packages installed
+		// before just-install kept track of them.
+		archs := st.Packages[pkg].Architectures()
+		if len(archs) == 0 {
+			arch, _, err := selectArch(c)
+			if err != nil {
+				return err
+			}
+
+			archs = []string{arch}
+		}
+
+		uninstalled := true
+		for _, arch := range archs {
+			if err := entry.Uninstall(c.Context, arch, uninstallOptions(options, st.Packages[pkg])); err != nil {
+				log.Printf("error uninstalling %v (%v): %v", pkg, arch, err)
+				hasErrors = true
+				uninstalled = false
+				break
+			}
+		}
+
+		if !uninstalled {
+			continue
+		}
+
+		st.Forget(pkg)
+		if err := st.Save(); err != nil {
+			log.Println("WARNING: could not record the removal of", pkg+":", err)
+		}
+	}
+
+	if c.Context.Err() != nil {
+		return cli.Exit("interrupted, remaining packages were not uninstalled", exitInterrupted)
+	}
+
+	if hasErrors {
+		return errors.New("encountered errors uninstalling packages")
+	}
+
+	return nil
+}
+
+// uninstallOptions returns the given options, along with what the state records about the given
+// installed package that is needed to uninstall it.
+func uninstallOptions(options *justinstall.Options, installed state.Package) *justinstall.Options {
+	ret := *options
+	ret.MSIXIdentity = installed.Identity
+
+	return &ret
+}
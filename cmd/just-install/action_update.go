@@ -20,7 +20,12 @@ import (
 )
 
 func handleUpdateAction(c *cli.Context) error {
-	_, err := loadRegistry(c, true)
+	path, err := registryPath(c, true, true)
+	if err != nil {
+		return err
+	}
+
+	_, err = loadRegistryFile(c, path)
 
 	return err
 }
@@ -0,0 +1,138 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"log"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/just-install/just-install/pkg/justinstall"
+	"github.com/just-install/just-install/pkg/state"
+)
+
+func handleUpgradeAction(c *cli.Context) error {
+	registry, err := loadRegistry(c, c.Bool("force"))
+	if err != nil {
+		return err
+	}
+
+	options, err := installOptions(c)
+	if err != nil {
+		return err
+	}
+
+	release, err := acquireLock(c)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	st, err := state.Load()
+	if err != nil {
+		return err
+	}
+
+	pkgs := c.Args().Slice()
+	if len(pkgs) == 0 {
+		pkgs = sortedStatePackages(st)
+	}
+
+	hasErrors := false
+
+	for _, pkg := range pkgs {
+		if c.Context.Err() != nil {
+			break
+		}
+
+		installed, ok := st.Packages[pkg]
+		if !ok {
+			log.Println("WARNING: not upgrading", pkg+", since it is not installed")
+			continue
+		}
+
+		entry, ok := registry.Packages[pkg]
+		if !ok {
+			// No longer in the registry, nothing to upgrade to
+			continue
+		}
+
+		archs, err := upgradeArchs(installed, func() (string, error) {
+			arch, _, err := selectArch(c)
+			return arch, err
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, arch := range archs {
+			if installed.VersionOf(arch) == entry.Version && !options.Force {
+				continue
+			}
+
+			log.Printf("upgrading %v (%v) from %v to %v", pkg, arch, installed.VersionOf(arch), entry.Version)
+
+			var identity string
+			pkgOptions, closeLog := withInstallerLog(c, pkg, options)
+			err := entry.JustInstall(c.Context, arch, withIdentity(pkgOptions, &identity))
+			closeLog()
+
+			if errors.Is(err, justinstall.ErrSkipped) {
+				log.Printf("not upgrading %v: %v", pkg, err)
+				break
+			} else if err != nil {
+				log.Printf("error upgrading %v (%v): %v", pkg, arch, err)
+				hasErrors = true
+				break
+			}
+
+			st.Record(pkg, entry.Version, arch)
+			if identity != "" {
+				st.SetIdentity(pkg, identity)
+			}
+			if err := st.Save(); err != nil {
+				log.Println("WARNING: could not record the upgrade of", pkg+":", err)
+			}
+		}
+	}
+
+	if c.Context.Err() != nil {
+		return cli.Exit("interrupted, remaining packages were not upgraded", exitInterrupted)
+	}
+
+	if hasErrors {
+		return errors.New("encountered errors upgrading packages")
+	}
+
+	return nil
+}
+
+// upgradeArchs returns the architectures to upgrade the given package for: those it is installed
+// for, not the one we would install, so that mixed installations stay as they are. The given
+// function selects one for state files that don't record any.
+func upgradeArchs(installed state.Package, selectDefault func() (string, error)) ([]string, error) {
+	if archs := installed.Architectures(); len(archs) > 0 {
+		return archs, nil
+	}
+
+	arch, err := selectDefault()
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{arch}, nil
+}
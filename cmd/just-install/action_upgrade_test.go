@@ -0,0 +1,203 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ungerik/go-dry"
+	"github.com/urfave/cli/v2"
+
+	"github.com/just-install/just-install/pkg/justinstall"
+	"github.com/just-install/just-install/pkg/paths"
+	"github.com/just-install/just-install/pkg/state"
+)
+
+func TestUpgradeArchs(t *testing.T) {
+	tests := []struct {
+		name      string
+		installed state.Package
+		expected  []string
+	}{
+		{"both architectures", state.Package{Arch: "x86_64", Archs: []string{"x86", "x86_64"}}, []string{"x86", "x86_64"}},
+		{"x86 only on a 64-bit host", state.Package{Arch: "x86", Archs: []string{"x86"}}, []string{"x86"}},
+		{"x86_64 only", state.Package{Arch: "x86_64", Archs: []string{"x86_64"}}, []string{"x86_64"}},
+		{"state without archs", state.Package{Arch: "x86"}, []string{"x86"}},
+		{"state without any architecture", state.Package{}, []string{"x86_64"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			archs, err := upgradeArchs(test.installed, func() (string, error) { return "x86_64", nil })
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(archs, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, archs)
+			}
+		})
+	}
+}
+
+// testEnvironment points the cache and state directories to a new temporary directory. It returns
+// the directory and a function that removes it and restores the previous directories.
+func testEnvironment(t *testing.T) (string, func()) {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "just-install-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var restore []func()
+	for _, name := range []string{paths.CacheDirEnv, paths.StateDirEnv} {
+		name := name
+		previous, set := os.LookupEnv(name)
+		os.Setenv(name, filepath.Join(dir, name))
+
+		restore = append(restore, func() {
+			if set {
+				os.Setenv(name, previous)
+			} else {
+				os.Unsetenv(name)
+			}
+		})
+	}
+
+	return dir, func() {
+		for _, r := range restore {
+			r()
+		}
+		os.RemoveAll(dir)
+	}
+}
+
+// upgradeContext returns the context of an upgrade of the given packages, with the registry at the
+// given path.
+func upgradeContext(registry string, pkgs ...string) *cli.Context {
+	set := flag.NewFlagSet("upgrade", flag.ContinueOnError)
+	set.String("registry", registry, "")
+	set.Bool("no-lock", true, "")
+	set.Set("registry", registry)
+	set.Parse(pkgs)
+
+	return cli.NewContext(cli.NewApp(), set, nil)
+}
+
+func TestUpgradeRetriesFailedArch(t *testing.T) {
+	dir, cleanup := testEnvironment(t)
+	defer cleanup()
+
+	failing := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/x86_64.exe" && failing {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		fmt.Fprint(w, "installer for "+r.URL.Path)
+	}))
+	defer server.Close()
+
+	destinations := map[string]string{
+		"x86":    filepath.Join(dir, "x86", "example.exe"),
+		"x86_64": filepath.Join(dir, "x86_64", "example.exe"),
+	}
+
+	data, err := json.Marshal(map[string]interface{}{
+		"version": justinstall.RegistryVersion,
+		"packages": map[string]interface{}{
+			"example": map[string]interface{}{
+				"version": "2.0",
+				"installer": map[string]interface{}{
+					"kind":   "copy",
+					"x86":    server.URL + "/x86.exe",
+					"x86_64": server.URL + "/x86_64.exe",
+					"options": map[string]interface{}{
+						"x86":    map[string]interface{}{"destination": destinations["x86"]},
+						"x86_64": map[string]interface{}{"destination": destinations["x86_64"]},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registry := filepath.Join(dir, "registry.json")
+	if err := ioutil.WriteFile(registry, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	st, err := state.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	st.Record("example", "1.0", "x86")
+	st.Record("example", "1.0", "x86_64")
+	if err := st.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The x86_64 upgrade fails, the x86 one goes through.
+	if err := handleUpgradeAction(upgradeContext(registry, "example")); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	st, err = state.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if installed := st.Packages["example"]; installed.VersionOf("x86") != "2.0" || installed.VersionOf("x86_64") != "1.0" {
+		t.Fatalf("expected x86 at 2.0 and x86_64 at 1.0, got %v", installed.Versions)
+	}
+
+	// Only x86_64 is upgraded the next time.
+	if err := os.Remove(destinations["x86"]); err != nil {
+		t.Fatal(err)
+	}
+
+	failing = false
+	if err := handleUpgradeAction(upgradeContext(registry, "example")); err != nil {
+		t.Fatal(err)
+	}
+
+	if dry.FileExists(destinations["x86"]) {
+		t.Error("upgraded x86 again")
+	}
+	if !dry.FileExists(destinations["x86_64"]) {
+		t.Error("did not retry the x86_64 upgrade")
+	}
+
+	st, err = state.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if installed := st.Packages["example"]; installed.VersionOf("x86") != "2.0" || installed.VersionOf("x86_64") != "2.0" {
+		t.Errorf("expected both architectures at 2.0, got %v", installed.Versions)
+	}
+}
@@ -0,0 +1,84 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/just-install/just-install/pkg/justinstall"
+	"github.com/just-install/just-install/pkg/redact"
+)
+
+func handleWhichAction(c *cli.Context) error {
+	if c.Args().Len() != 1 {
+		return errors.New("which requires exactly one package name")
+	}
+
+	registry, err := loadRegistry(c, c.Bool("force"))
+	if err != nil {
+		return err
+	}
+
+	name, version := justinstall.SplitPackageVersion(c.Args().First())
+	entry, err := registry.Package(name)
+	if err != nil {
+		return err
+	}
+
+	if version != "" {
+		pinned, err := entry.AtVersion(version)
+		if err != nil {
+			return err
+		}
+		entry = &pinned
+	}
+
+	arch, _, err := selectArch(c)
+	if err != nil {
+		return err
+	}
+
+	options, err := installOptions(c)
+	if err != nil {
+		return err
+	}
+
+	location, err := entry.Which(c.Context, arch, options)
+	if err != nil {
+		return err
+	}
+
+	return printOutput(c, location, func() error {
+		fmt.Println("url:    ", redact.String(location.URL))
+		for _, mirror := range location.Mirrors {
+			fmt.Println("mirror: ", redact.String(mirror))
+		}
+
+		switch {
+		case !location.Cached:
+			fmt.Println("cache:  ", location.CachePath, "(not cached)")
+		case location.Valid:
+			fmt.Println("cache:  ", location.CachePath, "(cached, valid)")
+		default:
+			fmt.Printf("cache:   %v (cached, not valid: %v)\n", location.CachePath, location.Problem)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,80 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/just-install/just-install/pkg/justinstall"
+	"github.com/just-install/just-install/pkg/state"
+)
+
+// installAction describes what installing a package would do, given what is already installed.
+func installAction(st *state.State, name string, version string) string {
+	installed, ok := st.Packages[name]
+	switch {
+	case !ok:
+		return "install"
+	case installed.Version != version:
+		return "upgrade from " + installed.Version
+	default:
+		return "reinstall"
+	}
+}
+
+// confirmInstall lists the given packages along with what would be done with them, then asks the
+// user whether to proceed.
+func confirmInstall(registry *justinstall.Registry, pkgs []string, st *state.State) (bool, error) {
+	fmt.Println("the following packages will be installed:")
+
+	for _, pkg := range pkgs {
+		entry, ok := registry.Packages[pkg]
+		if !ok {
+			continue
+		}
+
+		line := fmt.Sprintf("    %v %v (%v)", pkg, entry.Version, installAction(st, pkg, entry.Version))
+		if entry.Installer.Interactive {
+			line += ", might require user interaction"
+		}
+
+		fmt.Println(line)
+	}
+
+	return askYesNo("proceed?")
+}
+
+// askYesNo asks the user the given question, returning whether the answer is "yes". Anything but an
+// explicit "yes" is taken as a "no".
+func askYesNo(question string) (bool, error) {
+	fmt.Print(question + " [y/N] ")
+
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
@@ -0,0 +1,115 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/just-install/just-install/pkg/justinstall"
+)
+
+// progressInterval is the minimum interval between download progress events for a package.
+const progressInterval = 250 * time.Millisecond
+
+// events receives the progress events of the run, if enabled with --events.
+var events *eventStream
+
+// event is a progress event, for programs that follow what just-install is doing.
+type event struct {
+	Event      string        `json:"event"` // "package-started", "download-progress", "install-finished", "error" or "run-finished"
+	Time       time.Time     `json:"time"`
+	Package    string        `json:"package,omitempty"`
+	Arch       string        `json:"arch,omitempty"`
+	Status     string        `json:"status,omitempty"`     // With "install-finished": "installed", "downloaded", "cached", "shims" or "skipped"
+	Downloaded int64         `json:"downloaded,omitempty"` // With "download-progress", in bytes
+	Total      int64         `json:"total,omitempty"`      // With "download-progress", in bytes, negative if unknown
+	Error      string        `json:"error,omitempty"`      // With "error"
+	Summary    *batchSummary `json:"summary,omitempty"`    // With "run-finished"
+}
+
+// eventStream writes events to its output as newline-delimited JSON. All methods are safe for
+// concurrent use and do nothing on a nil stream, so that callers don't have to check whether events
+// are enabled.
+type eventStream struct {
+	mutex   sync.Mutex
+	encoder *json.Encoder
+}
+
+func newEventStream(out io.Writer) *eventStream {
+	encoder := json.NewEncoder(out)
+	encoder.SetEscapeHTML(false)
+
+	return &eventStream{encoder: encoder}
+}
+
+// emit writes the given event, timestamped now.
+func (s *eventStream) emit(e event) {
+	if s == nil {
+		return
+	}
+
+	e.Time = time.Now()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// There is nobody to report a broken output to, other than the output itself.
+	_ = s.encoder.Encode(e)
+}
+
+// runFinished emits the summary of the run, which ended with the given error (if any).
+func (s *eventStream) runFinished(summary *batchSummary, err error) {
+	ret := *summary
+	ret.Success = err == nil
+	if err != nil {
+		ret.Error = err.Error()
+	}
+
+	s.emit(event{Event: "run-finished", Summary: &ret})
+}
+
+// withDownloadProgress returns options that report the download progress of the given package as
+// events, at most every progressInterval.
+func (s *eventStream) withDownloadProgress(pkg string, arch string, options *justinstall.Options) *justinstall.Options {
+	if s == nil {
+		return options
+	}
+
+	var mutex sync.Mutex // Segmented downloads report progress concurrently
+	var last time.Time
+
+	ret := *options
+	ret.DownloadProgress = func(written int64, total int64) {
+		mutex.Lock()
+		now := time.Now()
+		throttled := written != total && now.Sub(last) < progressInterval
+		if !throttled {
+			last = now
+		}
+		mutex.Unlock()
+
+		if throttled {
+			return
+		}
+
+		s.emit(event{Event: "download-progress", Package: pkg, Arch: arch, Downloaded: written, Total: total})
+	}
+
+	return &ret
+}
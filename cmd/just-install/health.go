@@ -0,0 +1,97 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/just-install/just-install/pkg/fetch"
+	"github.com/just-install/just-install/pkg/justinstall"
+)
+
+// packageHealth is a rough, informational, indicator of how well-maintained a package is. Score
+// goes from 0 (broken) to 100 (healthy).
+type packageHealth struct {
+	Score     int `json:"score"`
+	Checksums int `json:"checksums"` // Number of installer URLs with a checksum, of any algorithm
+	Reachable int `json:"reachable"` // Number of installer URLs that resolved
+	Total     int `json:"total"`     // Number of installer URLs
+}
+
+// computeHealth checks all installer URLs of the given packages and combines the results, the
+// availability of checksums and the age of the registry into a health score for each package.
+// Packages that opted out of audits are not checked and get no score.
+func computeHealth(registry *justinstall.Registry, names []string, registryAge time.Duration) map[string]packageHealth {
+	// Registry freshness accounts for a small part of the score, since a stale registry means that
+	// we might be checking outdated URLs.
+	freshness := 0
+	switch {
+	case registryAge < 24*time.Hour:
+		freshness = 20
+	case registryAge < 7*24*time.Hour:
+		freshness = 10
+	}
+
+	ret := make(map[string]packageHealth)
+	var retMutex sync.Mutex
+
+	semaphore := make(chan struct{}, runtime.NumCPU())
+	var wg sync.WaitGroup
+
+	for _, name := range names {
+		entry := registry.Packages[name]
+		if entry.SkipAudit {
+			continue
+		}
+
+		wg.Add(1)
+
+		go func(name string, entry justinstall.RegistryEntry) {
+			defer wg.Done()
+
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			var health packageHealth
+			for _, u := range entry.InstallerURLs() {
+				health.Total++
+				if len(u.Hashes) > 0 {
+					health.Checksums++
+				}
+
+				err := fetch.Check(u.URL, &fetch.CheckOptions{ExpectedContentTypes: expectedContentTypes})
+				if err == nil {
+					health.Reachable++
+				}
+			}
+
+			if health.Total > 0 {
+				health.Score = health.Reachable*60/health.Total + health.Checksums*20/health.Total
+			}
+			health.Score += freshness
+
+			retMutex.Lock()
+			ret[name] = health
+			retMutex.Unlock()
+		}(name, entry)
+	}
+
+	wg.Wait()
+
+	return ret
+}
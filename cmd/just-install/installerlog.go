@@ -0,0 +1,107 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/just-install/just-install/pkg/justinstall"
+	"github.com/just-install/just-install/pkg/paths"
+)
+
+// defaultMaxInstallerLogSize is the default value of --max-installer-log-size.
+const defaultMaxInstallerLogSize = 10 * 1024 * 1024
+
+// installerLog is a log file capturing the output of an installer, up to a maximum size.
+type installerLog struct {
+	file    *os.File
+	size    int64
+	maxSize int64
+}
+
+func (l *installerLog) Write(p []byte) (int, error) {
+	if l.size >= l.maxSize {
+		return len(p), nil // Silently drop the rest, the installer must not fail because of us
+	}
+
+	n := len(p)
+	if remaining := l.maxSize - l.size; int64(n) > remaining {
+		p = p[:remaining]
+	}
+
+	written, err := l.file.Write(p)
+	l.size += int64(written)
+	if err != nil {
+		return written, err
+	}
+
+	if l.size >= l.maxSize {
+		fmt.Fprintf(l.file, "\n[truncated: output exceeded %v bytes, see --max-installer-log-size]\n", l.maxSize)
+	}
+
+	return n, nil
+}
+
+// withInstallerLog returns a copy of the given options that captures the output of the installer
+// of the given package, if asked to on the command line, along with a function that closes the log
+// once the package is installed. Logs are stored in the installer-logs directory of the cache, as
+// <package>-<time>.log, and removed if empty.
+func withInstallerLog(c *cli.Context, pkg string, options *justinstall.Options) (*justinstall.Options, func()) {
+	if !c.Bool("capture-installer-output") {
+		return options, func() {}
+	}
+
+	cacheDir, err := paths.CacheDirCreate()
+	if err != nil {
+		log.Println("WARNING: not capturing installer output:", err)
+		return options, func() {}
+	}
+
+	dir := filepath.Join(cacheDir, "installer-logs")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Println("WARNING: not capturing installer output:", err)
+		return options, func() {}
+	}
+
+	path := filepath.Join(dir, pkg+"-"+time.Now().Format("20060102-150405")+".log")
+
+	file, err := os.Create(path)
+	if err != nil {
+		log.Println("WARNING: not capturing installer output:", err)
+		return options, func() {}
+	}
+
+	l := &installerLog{file: file, maxSize: c.Int64("max-installer-log-size")}
+
+	ret := *options
+	ret.InstallerOutput = l
+
+	return &ret, func() {
+		file.Close()
+
+		if l.size == 0 {
+			os.Remove(path)
+		} else {
+			log.Println("installer output of", pkg, "saved to", path)
+		}
+	}
+}
@@ -16,105 +16,699 @@
 package main
 
 import (
+	"context"
 	"debug/pe"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
 	"strings"
 
 	"github.com/urfave/cli/v2"
 
+	"github.com/just-install/just-install/pkg/fetch"
+	"github.com/just-install/just-install/pkg/justinstall"
+	"github.com/just-install/just-install/pkg/lock"
 	"github.com/just-install/just-install/pkg/platform"
+	"github.com/just-install/just-install/pkg/resolver"
+	"github.com/just-install/just-install/pkg/timing"
 )
 
 var version = "## filled by go build ##"
 
+// argsFileName is the name of the file next to the executable to read arguments from, when there are
+// none embedded into it.
+const argsFileName = "just-install.args"
+
+// timings records how long each phase takes, if requested on the command line.
+var timings *timing.Recorder
+
+// warnings keeps track of the warnings logged during a run.
+var warnings = &warningCounter{out: os.Stderr}
+
+// Exit codes, other than 1 for generic errors.
+const (
+	exitOutdated    = 2   // Returned by `outdated` when there is something to upgrade
+	exitNotWindows  = 3   // Returned when trying to change the system on anything but Windows
+	exitDeadline    = 4   // Returned when --deadline expires before all packages are installed
+	exitInterrupted = 130 // Same as what shells use for SIGINT
+)
+
 func main() {
 	app := cli.NewApp()
-	app.Action = handleInstall
+	app.Action = windowsOnly(handleInstall)
+	app.BashComplete = completeArguments
+	app.EnableBashCompletion = true
 	app.Name = "just-install"
 	app.Usage = "The simple package installer for Windows"
 	app.Version = version
 
 	app.Commands = []*cli.Command{{
+		Name:      "apply",
+		Usage:     "Install exactly the packages, versions and installers of a plan saved with plan --json",
+		ArgsUsage: "PLAN",
+		Action:    windowsOnly(handleApplyAction),
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "allow-drift",
+				Usage: "Install the planned installers even if the registry has changed since the plan was made",
+			},
+		},
+	}, {
 		Name:   "audit",
 		Usage:  "Audit the registry",
 		Action: handleAuditAction,
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "Print the findings as JSON",
+			},
+			&cli.IntFlag{
+				Name:  "jobs",
+				Usage: "Check up to `N` installers at the same time, at most 8 per host (default: number of CPUs)",
+			},
+			&cli.StringFlag{
+				Name:  "min-severity",
+				Usage: "Only print findings of at least `SEVERITY`: \"info\", \"warning\" or \"error\"",
+				Value: "info",
+			},
+			&cli.BoolFlag{
+				Name:  "update-checksums",
+				Usage: "Download all installers and write missing or stale checksums to the local registry file",
+			},
+		},
+	}, {
+		Name:      "benchmark",
+		Usage:     "Measure latency and throughput of the hosts serving the given packages, or the busiest hosts in the registry",
+		ArgsUsage: "[PACKAGE...]",
+		Action:    handleBenchmarkAction,
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "Print the results as JSON",
+			},
+			&cli.Int64Flag{
+				Name:  "size",
+				Usage: "Download at most `MIB` megabytes from each host",
+				Value: 8,
+			},
+		},
 	}, {
 		Name:   "clean",
 		Usage:  "Remove caches and temporary files",
 		Action: handleCleanAction,
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "all",
+				Usage: "Remove everything: the cache, temporary files and the install state",
+			},
+			&cli.BoolFlag{
+				Name:  "cache",
+				Usage: "Remove the cache (installers, the registry and resolved results)",
+			},
+			&cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "Only list what would be removed and how much space would be reclaimed",
+			},
+			&cli.StringFlag{
+				Name:  "prefix",
+				Usage: "Remove the portable prefix at `DIR`, with everything installed in it (see --prefix)",
+			},
+			&cli.BoolFlag{
+				Name:  "state",
+				Usage: "Forget which packages were installed by just-install, after asking for confirmation (see --yes)",
+			},
+			&cli.BoolFlag{
+				Name:  "temp",
+				Usage: "Remove temporary files",
+			},
+		},
+	}, {
+		Name:      "hash",
+		Usage:     "Print the checksum of the given files, or of all cached files, regardless of the registry",
+		ArgsUsage: "[FILE...]",
+		Action:    handleHashAction,
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "Print the checksums as JSON",
+			},
+		},
+	}, {
+		Name:   "info",
+		Usage:  "Show information about just-install and the registry in use",
+		Action: handleInfoAction,
 	}, {
 		Name:   "list",
 		Usage:  "List all known packages",
 		Action: handleListAction,
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "categories",
+				Usage: "List the categories of packages, with how many packages are in each, instead",
+			},
+			&cli.StringFlag{
+				Name:  "category",
+				Usage: "Only list the packages in the given category (i.e. \"dev\")",
+			},
+			&cli.BoolFlag{
+				Name:  "health",
+				Usage: "Show an informational health score for each package (checks all installer URLs)",
+			},
+			&cli.BoolFlag{
+				Aliases: []string{"q"},
+				Name:    "short",
+				Usage:   "Only print package names (or category names, with --categories), one per line, sorted, regardless of --format",
+			},
+		},
+	}, {
+		Name:   "outdated",
+		Usage:  "List installed packages that have a newer version in the registry",
+		Action: handleOutdatedAction,
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "Print outdated packages as JSON",
+			},
+		},
+	}, {
+		Name:      "plan",
+		Usage:     "Print what would be installed (versions, URLs and checksums) without installing anything",
+		ArgsUsage: "[PACKAGE...]",
+		Action:    handlePlanAction,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "from-list",
+				Usage: "Also plan the packages listed in the given file, as with the global --from-list",
+			},
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "Print the plan as JSON",
+			},
+			&cli.BoolFlag{
+				Name:  "registry-only",
+				Usage: "Never use the network: plan from the cached registry, checksum files and installers, failing if any installer isn't cached",
+			},
+		},
+	}, {
+		Name:  "registry",
+		Usage: "Inspect and maintain the registry",
+		Subcommands: []*cli.Command{{
+			Name:   "diff",
+			Usage:  "List changes to the registry since the previous update, or since a point in time",
+			Action: handleRegistryDiffAction,
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:  "json",
+					Usage: "Print changes as JSON",
+				},
+				&cli.DurationFlag{
+					Name:  "since",
+					Usage: "Compare with the registry in use `DURATION` ago, as far as the history of the last 10 updates goes",
+				},
+			},
+		}, {
+			Name:      "dump",
+			Usage:     "Print the entries of the given packages as a registry file with only them, to attach to bug reports",
+			ArgsUsage: "PACKAGE...",
+			Action:    handleRegistryDumpAction,
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:  "entry",
+					Usage: "Print the entry of a single package alone, instead of a registry file",
+				},
+			},
+		}, {
+			Name:      "format",
+			Usage:     "Rewrite a local registry file in its canonical format: keys sorted alphabetically and two-space indentation",
+			ArgsUsage: "[FILE]",
+			Action:    handleRegistryFormatAction,
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:  "stdout",
+					Usage: "Print the formatted registry instead of rewriting the file",
+				},
+			},
+		}},
+	}, {
+		Name:   "self-update",
+		Usage:  "Update just-install itself to the latest release",
+		Action: windowsOnly(handleSelfUpdateAction),
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "dry-run",
+				Usage: "Only report whether an update is available",
+			},
+		},
+	}, {
+		Name:      "show",
+		Usage:     "Show details about a package",
+		ArgsUsage: "NAME",
+		Action:    handleShowAction,
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "Print details as JSON",
+			},
+			&cli.BoolFlag{
+				Name:  "list-archs",
+				Usage: "Only print the architectures the package is available for",
+			},
+		},
+	}, {
+		Name:      "uninstall",
+		Usage:     "Uninstall packages installed by just-install",
+		ArgsUsage: "NAME...",
+		Action:    windowsOnly(handleUninstallAction),
 	}, {
 		Name:   "update",
 		Usage:  "Update the registry",
 		Action: handleUpdateAction,
+	}, {
+		Name:      "upgrade",
+		Usage:     "Upgrade installed packages that have a newer version in the registry",
+		ArgsUsage: "[NAME...]",
+		Action:    windowsOnly(handleUpgradeAction),
+	}, {
+		Name:      "which",
+		Usage:     "Show where the installer of a package is downloaded from and cached",
+		ArgsUsage: "NAME",
+		Action:    handleWhichAction,
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "json",
+				Usage: "Print the installer location as JSON",
+			},
+		},
 	}}
 
 	app.Flags = []cli.Flag{
 		&cli.StringFlag{
 			Aliases: []string{"a"},
 			Name:    "arch",
-			Usage:   "Force installation for a specific architecture (if supported by the host).",
+			Usage:   "Force installation for a specific architecture (if supported by the host): \"x86\" or \"x86_64\"",
+		}, &cli.StringFlag{
+			Name:  "args-file",
+			Usage: "Read arguments from the given file, instead of just-install.args next to the executable (ignored if arguments are embedded into it)",
+		}, &cli.StringFlag{
+			Name:  "asset-kind",
+			Usage: "Installer type of the asset installed with --github, when it cannot be told from its name (i.e. \"nsis\" or \"innosetup\")",
+		}, &cli.StringFlag{
+			Name:  "asset-pattern",
+			Usage: "Install the asset matching the given `PATTERN` (i.e. \"*.msi\") with --github",
+		}, &cli.BoolFlag{
+			Name:  "capture-installer-output",
+			Usage: "Save the output of installers to log files in the cache (use --capture-installer-output=false to disable)",
+			Value: true,
+		}, &cli.StringFlag{
+			Name:  "channel",
+			Usage: "Use the registry of the given release channel: \"stable\" (default) or \"testing\"",
+		}, &cli.BoolFlag{
+			Name:  "cleanup-after",
+			Usage: "Remove each installer from the installer cache once its package is installed, keeping those that failed for a retry",
+		}, &cli.BoolFlag{
+			Name:  "confirm",
+			Usage: "List the packages that would be installed and ask for confirmation first",
+		}, &cli.IntFlag{
+			Name:  "connections",
+			Usage: "Download large installers with this many parallel connections, if the server allows it",
+		}, &cli.DurationFlag{
+			Name:  "deadline",
+			Usage: "Stop installing packages after the given `DURATION`, cancelling downloads in progress (see --on-interrupt for installers)",
 		}, &cli.BoolFlag{
 			Aliases: []string{"d"},
 			Name:    "download-only",
-			Usage:   "Only download packages, do not install them",
+			Usage:   "Only download packages missing from the installer cache, do not install them",
+		}, &cli.DurationFlag{
+			Name:  "download-timeout",
+			Usage: "Give up downloading an installer after `DURATION`, trying the next mirror if any, without affecting installers",
+		}, &cli.StringFlag{
+			Name:  "events",
+			Usage: "Print progress events to standard output as the run proceeds, in the given `FORMAT` (only \"json\", newline-delimited), instead of the usual output",
+		}, &cli.BoolFlag{
+			Name:  "explain",
+			Usage: "Explain how packages would be installed, without installing them",
+		}, &cli.BoolFlag{
+			Name:  "fail-on-warning",
+			Usage: "Fail at the end of the run if any warning was logged",
 		}, &cli.BoolFlag{
 			Aliases: []string{"f"},
 			Name:    "force",
 			Usage:   "Force package re-download",
+		}, &cli.BoolFlag{
+			Name:  "force-associations",
+			Usage: "Register file associations even for extensions already associated with another program",
+		}, &cli.StringFlag{
+			Name:  "format",
+			Usage: "Output format of commands that print something: \"text\" (default), \"json\" or \"yaml\"",
+		}, &cli.StringFlag{
+			Name:  "from-list",
+			Usage: "Also install the packages listed in the given file, one per line (optionally followed by \"arch=x86\" or \"arch=x86_64\")",
+		}, &cli.StringFlag{
+			Name:  "github",
+			Usage: "Install the latest release of the given GitHub repository (`OWNER/NAME`), without a registry entry (see --asset-pattern)",
+		}, &cli.StringFlag{
+			Name:  "github-token",
+			Usage: "Authenticate requests to the GitHub API with the given `TOKEN`, for a higher rate limit (defaults to $GITHUB_TOKEN, never logged)",
+		}, &cli.StringFlag{
+			Name:  "hash-algo",
+			Usage: "Verify installers with the given algorithm (sha512, sha256, sha1 or md5) instead of the strongest available",
+		}, &cli.DurationFlag{
+			Name:  "max-age",
+			Usage: "Refresh the registry if older than the given duration (fail with --offline)",
+		}, &cli.Int64Flag{
+			Name:  "max-installer-log-size",
+			Usage: "Truncate the captured output of each installer after `BYTES`",
+			Value: defaultMaxInstallerLogSize,
+		}, &cli.IntFlag{
+			Name:  "max-redirects",
+			Usage: "Give up downloading an installer after following `N` redirects",
+			Value: fetch.DefaultMaxRedirects,
+		}, &cli.StringFlag{
+			Name:  "mirror-strategy",
+			Usage: "How to pick among the mirrors of an installer: \"first\" (default), \"fastest\" (probing them first) or \"random\"",
+		}, &cli.BoolFlag{
+			Name:  "offline",
+			Usage: "Never refresh the registry, use the local copy",
+		}, &cli.BoolFlag{
+			Name:  "no-content-check",
+			Usage: "Don't reject downloaded installers that don't look like installers of their package's kind (i.e. HTML error pages)",
+		}, &cli.BoolFlag{
+			Name:  "no-fallback-arch",
+			Usage: "Fail instead of installing the x86 version of packages without an x86_64 installer",
+		}, &cli.BoolFlag{
+			Name:  "no-interactive",
+			Usage: "Refuse to install packages that might require user interaction",
+		}, &cli.BoolFlag{
+			Name:  "no-lock",
+			Usage: "Don't acquire the global lock when changing the system (unsafe if other instances are running)",
+		}, &cli.BoolFlag{
+			Name:  "no-post-on-failure",
+			Usage: "Don't run the --post-run-script if installing packages failed",
+		}, &cli.BoolFlag{
+			Name:  "no-shim",
+			Usage: "Don't create shims, even for packages that declare some",
+		}, &cli.StringFlag{
+			Name:  "on-interrupt",
+			Usage: "What to do with a running installer on Ctrl-C: \"wait\" for it (default) or \"kill\" it",
+		}, &cli.StringFlag{
+			Aliases: []string{"o"},
+			Name:    "output-dir",
+			Usage:   "Also copy downloaded installers to the given directory (with --download-only)",
+		}, &cli.IntFlag{
+			Name:  "parallel-installs",
+			Usage: "Install up to `N` parallel-safe packages at the same time, alongside the others",
+		}, &cli.StringFlag{
+			Name:  "post-run-script",
+			Usage: "Run the script at `PATH` once all packages are processed, passing it a summary as JSON on standard input",
+		}, &cli.BoolFlag{
+			Name:  "prefer-ipv4",
+			Usage: "Only connect to servers over IPv4, for networks where IPv6 is broken",
+		}, &cli.BoolFlag{
+			Name:  "prefer-ipv6",
+			Usage: "Only connect to servers over IPv6",
+		}, &cli.StringFlag{
+			Name:  "prefix",
+			Usage: "Install copy and zip packages, with their shims, in the portable prefix at `DIR` instead of where the registry says",
+		}, &cli.StringFlag{
+			Name:  "profile",
+			Usage: "Run installers with the arguments of the installer profile `NAME`, which all packages must declare (i.e. \"minimal\")",
 		}, &cli.StringFlag{
 			Aliases: []string{"r"},
 			Name:    "registry",
 			Usage:   "Use the specified registry file",
+		}, &cli.StringFlag{
+			Name:  "registry-cache-file",
+			Usage: "Use the given file as the local copy of the registry, only downloaded again by update",
+		}, &cli.BoolFlag{
+			Name:  "registry-insecure-http",
+			Usage: "Allow downloading the registry over plain HTTP (or FTP), without TLS",
+		}, &cli.StringSliceFlag{
+			Name:  "registry-mirror",
+			Usage: "Download the registry from `URL` if its usual source fails (can be repeated, tried in order)",
+		}, &cli.BoolFlag{
+			Name:  "reinstall",
+			Usage: "Uninstall installed packages first, then install them again (to repair them), reusing cached installers",
+		}, &cli.BoolFlag{
+			Name:  "require-strong-hash",
+			Usage: "Refuse installers that can only be verified with a weak algorithm (sha1 or md5)",
+		}, &cli.StringSliceFlag{
+			Name:  "sensitive-template-vars",
+			Usage: "Like --template-vars, but the value is never logged (i.e. for tokens)",
+		}, &cli.StringSliceFlag{
+			Name:  "set",
+			Usage: "Set `pkg.field=value` in the loaded registry, without changing the file (can be repeated)",
 		}, &cli.BoolFlag{
 			Aliases: []string{"s"},
 			Name:    "shim",
 			Usage:   "Create shims only (if exeproxy is installed)",
+		}, &cli.BoolFlag{
+			Name:  "skip-interactive",
+			Usage: "Skip packages that might require user interaction, installing the others",
+		}, &cli.BoolFlag{
+			Name:  "strict-templates",
+			Usage: "Fail if installer URLs, headers or arguments reference an undefined template variable",
+		}, &cli.StringFlag{
+			Name:  "summary-file",
+			Usage: "Write a summary of the run as JSON to the file at `PATH` (packages, outcomes, versions and durations)",
+		}, &cli.StringSliceFlag{
+			Name:  "template-vars",
+			Usage: "Set `name=value` for registry templates, referenced as {{.name}} (can be repeated)",
+		}, &cli.BoolFlag{
+			Name:  "timings",
+			Usage: "Print how long each phase took at the end",
+		}, &cli.BoolFlag{
+			Name:  "trace-http",
+			Usage: "Log all HTTP requests and responses",
+		}, &cli.BoolFlag{
+			Name:  "verbose",
+			Usage: "Log how long each phase takes as it ends",
+		}, &cli.BoolFlag{
+			Name:  "verify-after-install",
+			Usage: "Run the post-install checks of packages once installed, failing those that don't pass (see the summary)",
+		}, &cli.BoolFlag{
+			Name:  "uninstall-replaced",
+			Usage: "Uninstall installed packages replaced by the ones being installed",
+		}, &cli.BoolFlag{
+			Aliases: []string{"y"},
+			Name:    "yes",
+			Usage:   "Assume \"yes\" as the answer to --confirm",
 		},
 	}
 
+	app.Before = func(c *cli.Context) error {
+		switch c.String("events") {
+		case "":
+			// Nothing to do
+		case "json":
+			// Events replace the human-readable output, which would get in the way of programs
+			// reading them.
+			events = newEventStream(os.Stdout)
+			warnings.out = ioutil.Discard
+			fetch.DisableProgress()
+		default:
+			return fmt.Errorf("unknown events format: %v", c.String("events"))
+		}
+
+		if arch := c.String("arch"); arch != "" {
+			if err := knownArch(arch); err != nil {
+				return err
+			}
+		}
+
+		if (c.String("asset-pattern") != "" || c.String("asset-kind") != "") && c.String("github") == "" {
+			return errors.New("--asset-pattern and --asset-kind require --github")
+		}
+
+		if token := c.String("github-token"); token != "" {
+			resolver.SetGitHubToken(token)
+		} else if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+			resolver.SetGitHubToken(token)
+		}
+
+		if c.Bool("prefer-ipv4") && c.Bool("prefer-ipv6") {
+			return errors.New("--prefer-ipv4 and --prefer-ipv6 cannot be used together")
+		} else if c.Bool("prefer-ipv4") {
+			fetch.PreferIPv4()
+		} else if c.Bool("prefer-ipv6") {
+			fetch.PreferIPv6()
+		}
+
+		for _, assignment := range c.StringSlice("template-vars") {
+			if err := justinstall.SetTemplateVar(assignment, false); err != nil {
+				return err
+			}
+		}
+
+		for _, assignment := range c.StringSlice("sensitive-template-vars") {
+			if err := justinstall.SetTemplateVar(assignment, true); err != nil {
+				return err
+			}
+		}
+
+		if c.Bool("strict-templates") {
+			justinstall.EnableStrictTemplates()
+		}
+
+		if c.Bool("trace-http") {
+			fetch.EnableTracing()
+		}
+
+		if c.Bool("timings") || c.Bool("verbose") {
+			timings = timing.New(c.Bool("verbose"))
+		}
+
+		return nil
+	}
+
+	app.After = func(c *cli.Context) error {
+		if c.Bool("timings") {
+			log.Println("timings:")
+			for _, line := range timings.Summary() {
+				log.Println("    " + line)
+			}
+		}
+
+		if w := warnings.Warnings(); len(w) > 0 {
+			log.Printf("%v warning(s):", len(w))
+			for _, warning := range w {
+				log.Println("    " + warning)
+			}
+
+			if c.Bool("fail-on-warning") {
+				return fmt.Errorf("failing because of %v warning(s) (see --fail-on-warning)", len(w))
+			}
+		}
+
+		return nil
+	}
+
+	log.SetOutput(warnings)
+
+	// Clean up after a previous self-update
+	removeOldExecutable()
+
 	// Normalize "%ProgramFiles%" and "%ProgramFiles(x86)%"
 	platform.SetNormalisedProgramFilesEnv()
 
-	// Extract arguments embedded in the executable (if any)
-	pathname, err := os.Executable()
-	if err != nil {
-		if err := app.Run(os.Args); err != nil {
-			log.Fatalln(err)
+	// Extract arguments embedded in the executable or, failing that, from an arguments file next to
+	// it (if any)
+	args := os.Args
+	embedded := false
+
+	if pathname, err := os.Executable(); err == nil {
+		if rawOverlayData, err := getPeOverlayData(pathname); err == nil {
+			if trimmed := strings.Trim(string(rawOverlayData), "\r\n "); len(trimmed) > 0 {
+				log.Println("using embedded arguments: " + trimmed)
+				args = append([]string{os.Args[0]}, strings.Split(trimmed, " ")...)
+				embedded = true
+			}
 		}
+	}
 
-		return
+	if !embedded {
+		argsFile, explicit := argumentsFile()
+
+		if data, err := ioutil.ReadFile(argsFile); err == nil {
+			if trimmed := strings.Trim(string(data), "\r\n "); len(trimmed) > 0 {
+				log.Printf("using arguments from %v: %v", argsFile, trimmed)
+				args = append([]string{os.Args[0]}, strings.Split(trimmed, " ")...)
+			}
+		} else if explicit {
+			log.Fatalln("could not read arguments file:", err)
+		}
 	}
 
-	rawOverlayData, err := getPeOverlayData(pathname)
-	if err != nil {
-		if err := app.Run(os.Args); err != nil {
-			log.Fatalln(err)
+	// Cancel whatever we are doing on Ctrl-C. A second Ctrl-C terminates the program immediately.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+
+	go func() {
+		<-signals
+		signal.Stop(signals)
+
+		log.Println("interrupted, stopping (press Ctrl-C again to terminate immediately)")
+		cancel()
+	}()
+
+	if err := app.RunContext(ctx, args); err != nil {
+		events.emit(event{Event: "error", Error: err.Error()})
+		log.Fatalln(err)
+	}
+}
+
+// windowsOnly wraps the action of a command that changes the system, so that it refuses to run on
+// anything but Windows. All other commands work anywhere, i.e. for registry authors that don't use
+// Windows. They are still allowed with --explain, --download-only and --dry-run, which don't change
+// the system.
+func windowsOnly(action cli.ActionFunc) cli.ActionFunc {
+	return func(c *cli.Context) error {
+		if runtime.GOOS != "windows" && !c.Bool("explain") && !c.Bool("download-only") && !c.Bool("dry-run") {
+			return cli.Exit("just-install only runs on Windows", exitNotWindows)
 		}
 
-		return
+		return action(c)
 	}
+}
 
-	stringOverlayData := string(rawOverlayData)
-	trimmedStringOverlayData := strings.Trim(stringOverlayData, "\r\n ")
-	if len(trimmedStringOverlayData) == 0 {
-		if err := app.Run(os.Args); err != nil {
-			log.Fatalln(err)
+// completeArguments suggests the values of --arch when completing its argument, and the commands
+// and flags otherwise.
+func completeArguments(c *cli.Context) {
+	// The completion flag is always last and the value being completed isn't parsed yet, so look at
+	// the raw arguments.
+	if n := len(os.Args); n >= 3 && (os.Args[n-2] == "--arch" || os.Args[n-2] == "-a") {
+		for _, arch := range architectures {
+			fmt.Fprintln(c.App.Writer, arch)
 		}
 
 		return
 	}
 
-	log.Println("using embedded arguments: " + trimmedStringOverlayData)
-	if err := app.Run(append([]string{os.Args[0]}, strings.Split(trimmedStringOverlayData, " ")...)); err != nil {
-		log.Fatalln(err)
+	cli.DefaultAppComplete(c)
+}
+
+// acquireLock acquires the global lock, for commands that change the system, unless --no-lock is
+// given. Returns a function that releases it.
+func acquireLock(c *cli.Context) (func(), error) {
+	if c.Bool("no-lock") {
+		log.Println("WARNING: not acquiring the global lock, other instances of just-install may interfere")
+		return func() {}, nil
+	}
+
+	l, err := lock.Acquire()
+	if err != nil {
+		return nil, err
 	}
+
+	return func() { l.Release() }, nil
+}
+
+// argumentsFile returns the path of the file to read arguments from, as given with --args-file or
+// next to the executable otherwise, and whether it was given explicitly.
+func argumentsFile() (string, bool) {
+	for i, arg := range os.Args[1:] {
+		if arg == "--args-file" && i+2 < len(os.Args) {
+			return os.Args[i+2], true
+		}
+
+		if strings.HasPrefix(arg, "--args-file=") {
+			return strings.TrimPrefix(arg, "--args-file="), true
+		}
+	}
+
+	pathname, err := os.Executable()
+	if err != nil {
+		return "", false
+	}
+
+	return filepath.Join(filepath.Dir(pathname), argsFileName), false
 }
 
 func getPeOverlayData(pathname string) ([]byte, error) {
@@ -0,0 +1,193 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/ungerik/go-dry"
+	"github.com/urfave/cli/v2"
+)
+
+// Output formats supported by --format.
+const (
+	formatText = "text"
+	formatJSON = "json"
+	formatYAML = "yaml"
+)
+
+// outputFormat returns the output format selected on the command line. The per-command --json flag
+// is kept as a shorthand for --format json.
+func outputFormat(c *cli.Context) (string, error) {
+	if c.Bool("json") {
+		return formatJSON, nil
+	}
+
+	switch format := c.String("format"); format {
+	case "", formatText:
+		return formatText, nil
+	case formatJSON, formatYAML:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown output format: %v", format)
+	}
+}
+
+// printOutput prints v in the output format selected on the command line, using printText for the
+// text format. Machine-readable formats are derived from the JSON encoding of v, so JSON struct tags
+// apply to all of them.
+func printOutput(c *cli.Context, v interface{}, printText func() error) error {
+	format, err := outputFormat(c)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case formatJSON:
+		return printJSON(v)
+	case formatYAML:
+		return printYAML(v)
+	default:
+		return printText()
+	}
+}
+
+// printJSON prints the given value to standard output as indented JSON.
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(v)
+}
+
+// printYAML prints the given value to standard output as YAML, preserving the order of the fields
+// in its JSON encoding.
+func printYAML(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var buf bytes.Buffer
+	if err := writeYAML(&buf, decoder, 0, false); err != nil {
+		return err
+	}
+
+	// Values are written after their key, drop the separator meant to go after it.
+	_, err = os.Stdout.Write(bytes.TrimLeft(buf.Bytes(), " \n"))
+	return err
+}
+
+// writeYAML converts the next JSON value from the decoder to YAML.
+func writeYAML(w *bytes.Buffer, decoder *json.Decoder, indent int, inline bool) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+
+	prefix := strings.Repeat("  ", indent)
+
+	switch token {
+	case json.Delim('{'):
+		if !decoder.More() {
+			decoder.Token()
+			w.WriteString(" {}\n")
+			return nil
+		}
+
+		first := true
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return err
+			}
+
+			if first && inline {
+				// First key of a mapping in a list goes on the same line as the dash
+				fmt.Fprintf(w, " %v:", yamlString(key.(string)))
+			} else {
+				if first {
+					w.WriteString("\n")
+				}
+				fmt.Fprintf(w, "%v%v:", prefix, yamlString(key.(string)))
+			}
+			first = false
+
+			if err := writeYAML(w, decoder, indent+1, false); err != nil {
+				return err
+			}
+		}
+
+		_, err := decoder.Token()
+		return err
+	case json.Delim('['):
+		if !decoder.More() {
+			decoder.Token()
+			w.WriteString(" []\n")
+			return nil
+		}
+
+		w.WriteString("\n")
+		for decoder.More() {
+			fmt.Fprintf(w, "%v-", prefix)
+
+			if err := writeYAML(w, decoder, indent+1, true); err != nil {
+				return err
+			}
+		}
+
+		_, err := decoder.Token()
+		return err
+	}
+
+	switch t := token.(type) {
+	case nil:
+		w.WriteString(" null\n")
+	case string:
+		fmt.Fprintf(w, " %v\n", yamlString(t))
+	default:
+		fmt.Fprintf(w, " %v\n", t)
+	}
+
+	return nil
+}
+
+// yamlPlain matches strings that can be written in YAML without quotes, as long as they are not
+// one of yamlReserved.
+var yamlPlain = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_./-]*$`)
+
+// yamlReserved are plain YAML scalars that would not be read back as strings.
+var yamlReserved = []string{"false", "n", "no", "null", "off", "on", "true", "y", "yes"}
+
+// yamlString returns the given string as a YAML scalar, quoting it unless unambiguous. Quoted
+// strings use the JSON syntax, which is valid YAML.
+func yamlString(s string) string {
+	if yamlPlain.MatchString(s) && !dry.StringInSlice(strings.ToLower(s), yamlReserved) {
+		return s
+	}
+
+	ret, _ := json.Marshal(s)
+	return string(ret)
+}
@@ -0,0 +1,138 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/just-install/just-install/pkg/justinstall"
+)
+
+// captureStdout returns what the given function prints to standard output.
+func captureStdout(t *testing.T, f func() error) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	output := make(chan string)
+	go func() {
+		data, _ := ioutil.ReadAll(r)
+		output <- string(data)
+	}()
+
+	stdout := os.Stdout
+	os.Stdout = w
+	err = f()
+	os.Stdout = stdout
+	w.Close()
+
+	ret := <-output
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ret
+}
+
+func TestListAndShowOrder(t *testing.T) {
+	dir, cleanup := testEnvironment(t)
+	defer cleanup()
+
+	names := []string{"vlc", "7zip", "python3", "Python", "python", "git", "git-lfs", "zoom", "arduino", "notepad++"}
+	expected := []string{"7zip", "Python", "arduino", "git", "git-lfs", "notepad++", "python", "python3", "vlc", "zoom"}
+
+	packages := make(map[string]interface{})
+	for _, name := range names {
+		packages[name] = map[string]interface{}{
+			"version": "1.0",
+			"installer": map[string]interface{}{
+				"kind":   "as-is",
+				"x86":    "https://example.com/" + name + "-x86.exe",
+				"x86_64": "https://example.com/" + name + "-x86_64.exe",
+				"hashes": map[string]interface{}{
+					"x86":    map[string]string{"sha256": strings.Repeat("a", 64), "sha512": strings.Repeat("b", 128), "sha1": strings.Repeat("c", 40)},
+					"x86_64": map[string]string{"sha512": strings.Repeat("d", 128), "sha1": strings.Repeat("e", 40), "sha256": strings.Repeat("f", 64)},
+				},
+			},
+		}
+	}
+
+	data, err := json.Marshal(map[string]interface{}{"version": justinstall.RegistryVersion, "packages": packages})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registry := filepath.Join(dir, "registry.json")
+	if err := ioutil.WriteFile(registry, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	context := func(command string, jsonOutput bool, args ...string) *cli.Context {
+		set := flag.NewFlagSet(command, flag.ContinueOnError)
+		set.String("registry", "", "")
+		set.Bool("json", false, "")
+		set.Set("registry", registry)
+		if jsonOutput {
+			set.Set("json", "true")
+		}
+		set.Parse(args)
+
+		return cli.NewContext(cli.NewApp(), set, nil)
+	}
+
+	for _, jsonOutput := range []bool{false, true} {
+		list := captureStdout(t, func() error { return handleListAction(context("list", jsonOutput)) })
+		show := captureStdout(t, func() error { return handleShowAction(context("show", jsonOutput, "python3")) })
+
+		// Map iteration order changes between runs, the output must not.
+		for i := 0; i < 10; i++ {
+			if again := captureStdout(t, func() error { return handleListAction(context("list", jsonOutput)) }); again != list {
+				t.Fatalf("list output changed between runs:\n%v\nthen:\n%v", list, again)
+			}
+			if again := captureStdout(t, func() error { return handleShowAction(context("show", jsonOutput, "python3")) }); again != show {
+				t.Fatalf("show output changed between runs:\n%v\nthen:\n%v", show, again)
+			}
+		}
+
+		if !jsonOutput {
+			var listed []string
+			for _, line := range strings.Split(strings.TrimSpace(list), "\n") {
+				listed = append(listed, strings.TrimSpace(strings.Split(line, " - ")[0]))
+			}
+			if !reflect.DeepEqual(listed, expected) {
+				t.Errorf("expected packages listed as %v, got %v", expected, listed)
+			}
+
+			if !strings.Contains(show, "x86: https://example.com/python3-x86.exe\n        sha1 ") ||
+				strings.Index(show, "sha1 ") > strings.Index(show, "sha256 ") || strings.Index(show, "sha256 ") > strings.Index(show, "sha512 ") {
+				t.Errorf("expected the x86 installer first and checksums sorted by algorithm, got:\n%v", show)
+			}
+		}
+	}
+}
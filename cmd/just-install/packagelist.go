@@ -0,0 +1,132 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/just-install/just-install/pkg/justinstall"
+)
+
+// requestedPackages returns the packages to install given on the command line, followed by the ones
+// in the package list given with --from-list (if any), along with the architectures and versions
+// (i.e. `foo@1.2.3`) requested for specific packages.
+func requestedPackages(c *cli.Context) ([]string, map[string]string, map[string]string, error) {
+	specs := c.Args().Slice()
+	archs := make(map[string]string)
+
+	if path := c.String("from-list"); path != "" {
+		listPkgs, listArchs, err := readPackageList(path)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		specs = append(specs, listPkgs...)
+		for pkg, arch := range listArchs {
+			archs[pkg] = arch
+		}
+	}
+
+	var pkgs []string
+	versions := make(map[string]string)
+
+	for _, spec := range specs {
+		pkg, version := justinstall.SplitPackageVersion(spec)
+		if version != "" {
+			versions[pkg] = version
+		}
+
+		pkgs = append(pkgs, pkg)
+	}
+
+	return pkgs, archs, versions, nil
+}
+
+// pinVersions replaces the entries of the packages requested in a specific version with the entry
+// for that version. Unknown packages are left alone, to be reported as such when installing.
+func pinVersions(registry *justinstall.Registry, versions map[string]string) error {
+	for _, pkg := range sortedKeys(versions) {
+		entry, ok := registry.Packages[pkg]
+		if !ok {
+			continue
+		}
+
+		pinned, err := entry.AtVersion(versions[pkg])
+		if err != nil {
+			return fmt.Errorf("cannot install %v@%v: %w", pkg, versions[pkg], err)
+		}
+
+		registry.Packages[pkg] = pinned
+	}
+
+	return nil
+}
+
+// readPackageList reads the package list at the given path: one package per line (with an optional
+// `@version`), optionally followed by `arch=x86` or `arch=x86_64` to install it for a specific
+// architecture. Blank lines and everything after a "#" are ignored.
+func readPackageList(path string) ([]string, map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read package list: %w", err)
+	}
+	defer f.Close()
+
+	var pkgs []string
+	archs := make(map[string]string)
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if i := strings.Index(text, "#"); i >= 0 {
+			text = text[:i]
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+
+		pkg := fields[0]
+		name, _ := justinstall.SplitPackageVersion(pkg)
+
+		for _, field := range fields[1:] {
+			if !strings.HasPrefix(field, "arch=") {
+				return nil, nil, fmt.Errorf("%v:%v: unknown keyword %q", path, line, field)
+			}
+
+			arch := strings.TrimPrefix(field, "arch=")
+			if err := checkArch(arch); err != nil {
+				return nil, nil, fmt.Errorf("%v:%v: %w", path, line, err)
+			}
+
+			archs[name] = arch
+		}
+
+		pkgs = append(pkgs, pkg)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("could not read package list: %w", err)
+	}
+
+	return pkgs, archs, nil
+}
@@ -0,0 +1,128 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/just-install/just-install/pkg/justinstall"
+)
+
+// batchSummary is the outcome of installing a batch of packages, passed to the post-run script.
+type batchSummary struct {
+	Success   bool     `json:"success"`
+	Error     string   `json:"error,omitempty"`
+	Packages  []string `json:"packages"`  // Requested packages
+	Succeeded []string `json:"succeeded"` // Installed, or downloaded with --download-only
+	Failed    []string `json:"failed"`
+	Skipped   []string `json:"skipped"` // Skipped, unknown or not reached before an interruption
+
+	Uninstalled  []string                             `json:"uninstalled,omitempty"`  // Uninstalled before being installed again, with --reinstall
+	Verification map[string][]justinstall.CheckResult `json:"verification,omitempty"` // Results of post-install checks, with --verify-after-install
+
+	StartedAt time.Time             `json:"-"` // When the batch started
+	Runs      map[string]packageRun `json:"-"` // Packages that were processed, by name
+}
+
+// packageRun describes how a package of a batch was processed.
+type packageRun struct {
+	Arch     string
+	Version  string
+	Duration time.Duration
+}
+
+// newBatchSummary returns the summary of a batch of the given packages, starting now.
+func newBatchSummary(pkgs []string) *batchSummary {
+	return &batchSummary{
+		Packages:  pkgs,
+		Succeeded: []string{},
+		Failed:    []string{},
+		Skipped:   []string{},
+		StartedAt: time.Now(),
+		Runs:      make(map[string]packageRun),
+	}
+}
+
+// recordVerification returns options that record the results of the post-install checks of the
+// given package in the given summary, guarded by the given mutex.
+func recordVerification(options *justinstall.Options, summary *batchSummary, pkg string, mutex *sync.Mutex) *justinstall.Options {
+	ret := *options
+	ret.Verified = func(results []justinstall.CheckResult) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		if summary.Verification == nil {
+			summary.Verification = make(map[string][]justinstall.CheckResult)
+		}
+		summary.Verification[pkg] = results
+	}
+
+	return &ret
+}
+
+// runPostRunScript runs the script at the given path, once the batch is done. The summary is
+// written as JSON to its standard input, its outcome is also available in the JUST_INSTALL_STATUS
+// environment variable ("success" or "failure"). PowerShell scripts are run with PowerShell, all
+// others directly. With --events, the output of the script goes to standard error.
+func runPostRunScript(path string, summary *batchSummary, batchErr error) error {
+	summary.Success = batchErr == nil
+	if batchErr != nil {
+		summary.Error = batchErr.Error()
+	}
+
+	data, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+
+	args := []string{path}
+	if strings.EqualFold(filepath.Ext(path), ".ps1") {
+		args = []string{"powershell.exe", "-NoProfile", "-ExecutionPolicy", "Bypass", "-File", path}
+	}
+
+	status := "success"
+	if batchErr != nil {
+		status = "failure"
+	}
+
+	// The batch might have been interrupted, but the script runs anyway (a second Ctrl-C still
+	// terminates everything).
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Env = append(os.Environ(), "JUST_INSTALL_STATUS="+status)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if events != nil {
+		// Standard output carries the events, which the output of the script would corrupt.
+		cmd.Stdout = os.Stderr
+	}
+
+	log.Println("running post-run script", path)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("post-run script %v failed: %w", path, err)
+	}
+
+	return nil
+}
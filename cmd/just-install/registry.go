@@ -16,8 +16,14 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
+	"log"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/ungerik/go-dry"
@@ -28,41 +34,360 @@ import (
 	"github.com/just-install/just-install/pkg/paths"
 )
 
-const registryURL = "https://just-install.github.io/registry/just-install-v4.json"
+// registryURLs contains the registry URL of each release channel.
+var registryURLs = map[string]string{
+	"stable":  "https://just-install.github.io/registry/just-install-v4.json",
+	"testing": "https://raw.githubusercontent.com/just-install/registry/master/just-install-v4.json",
+}
 
 func loadRegistry(c *cli.Context, force bool) (*justinstall.Registry, error) {
-	src := registryURL
-	dst, dstErr := paths.TempFileCreate("registry.json")
-	if dstErr != nil {
-		return nil, fmt.Errorf("could not create temporary directory to hold registry file: %w", dstErr)
+	path, err := registryPath(c, force, false)
+	if err != nil {
+		return nil, err
 	}
 
-	if c.IsSet("registry") {
-		src = c.String("registry")
-		dst, dstErr = paths.TempFileCreate("registry-custom.json")
-		if dstErr != nil {
-			return nil, fmt.Errorf("could not create temporary directory to hold custom registry file: %w", dstErr)
+	return loadRegistryFile(c, path)
+}
+
+// registryPath returns the path of the registry selected on the command line, downloading it first
+// if the local copy is missing or older than --max-age (or always, if force is true). A local copy
+// given with --registry-cache-file may be a pinned snapshot: it is only ever downloaded again when
+// updating the registry explicitly, with the `update` command.
+func registryPath(c *cli.Context, force bool, update bool) (string, error) {
+	src, err := registrySource(c)
+	if err != nil {
+		return "", err
+	}
+
+	dst, err := registryCachePath(c)
+	if err != nil {
+		return "", err
+	}
+
+	maxAge := 24 * time.Hour
+	if c.IsSet("max-age") {
+		maxAge = c.Duration("max-age")
+	}
+
+	if c.Bool("offline") || c.Bool("registry-only") {
+		if dry.FileExists(src) {
+			return src, nil
+		}
+
+		if !dry.FileExists(dst) {
+			return "", fmt.Errorf("no local copy of the registry at %v, cannot continue offline", dst)
 		}
+
+		// Only enforce the age of the local copy if explicitly asked to, we can't refresh it anyway.
+		if age := time.Since(dry.FileTimeModified(dst)); c.IsSet("max-age") && age > maxAge {
+			return "", fmt.Errorf("local copy of the registry is %v old, more than the maximum allowed of %v", age.Round(time.Second), maxAge)
+		}
+
+		return dst, nil
 	}
 
-	if force && dry.FileExists(dst) {
-		if err := os.Remove(dst); err != nil {
-			return nil, fmt.Errorf("could not delete %v due to %w", dst, err)
+	if c.IsSet("registry-cache-file") && !update {
+		if dry.FileExists(src) {
+			return src, nil
+		}
+
+		if !dry.FileExists(dst) {
+			return "", fmt.Errorf("no local copy of the registry at %v, run `just-install update` to download it", dst)
+		}
+
+		return dst, nil
+	}
+
+	sources := append([]string{src}, c.StringSlice("registry-mirror")...)
+	for _, source := range sources {
+		if insecureRegistrySource(source) && !c.Bool("registry-insecure-http") {
+			return "", fmt.Errorf("refusing to download the registry from %v without TLS, use an https:// URL or acknowledge the risk with --registry-insecure-http", source)
 		}
 	}
 
-	download := !dry.FileExists(dst)
-	download = download || dry.FileTimeModified(dst).Before(time.Now().Add(-24*time.Hour))
+	download := force || !dry.FileExists(dst)
+	download = download || dry.FileTimeModified(dst).Before(time.Now().Add(-maxAge))
 	if !download {
-		ret := justinstall.LoadRegistry(dst)
-		return &ret, nil
+		return dst, nil
+	}
+
+	for _, source := range sources {
+		if insecureRegistrySource(source) {
+			log.Printf("WARNING: the registry at %v is downloaded without TLS, anyone on the network can tamper with it", source)
+		}
+	}
+
+	path, err := updateRegistryFromMirrors(sources, dst)
+	if err != nil {
+		return "", err
+	}
+
+	if err := saveRegistrySnapshot(path, dst); err != nil {
+		log.Println("WARNING: could not save the registry to its history:", err)
+	}
+
+	return path, nil
+}
+
+// insecureRegistrySource returns whether the registry at src would be downloaded without TLS, i.e.
+// over http:// instead of https://. Local files are never insecure.
+func insecureRegistrySource(src string) bool {
+	lower := strings.ToLower(src)
+
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "ftp://")
+}
+
+// loadRegistryFile loads the registry at the given path, applying the overlays given with --set.
+func loadRegistryFile(c *cli.Context, path string) (*justinstall.Registry, error) {
+	return justinstall.LoadRegistryWithOverlays(path, c.StringSlice("set"))
+}
+
+// updateRegistryFromMirrors updates the local copy of the registry at dst from the first of the
+// given sources that works.
+func updateRegistryFromMirrors(sources []string, dst string) (string, error) {
+	for i, src := range sources {
+		path, err := updateRegistry(src, dst)
+		if err == nil && i > 0 {
+			log.Println("registry served by mirror", src)
+		}
+		if err == nil || i == len(sources)-1 {
+			return path, err
+		}
+
+		log.Printf("WARNING: %v, trying %v", err, sources[i+1])
+	}
+
+	return "", nil // Not reached
+}
+
+// updateRegistry fetches the registry from src and, only if it can be loaded, replaces the local
+// copy at dst with it. On failure, the previous local copy is left untouched. Returns the path of
+// the updated registry.
+func updateRegistry(src string, dst string) (string, error) {
+	// Download next to dst, so that it can be renamed over it, under a name of our own: other
+	// instances, i.e. parallel read-only commands, may be updating the registry at the same time.
+	f, err := ioutil.TempFile(filepath.Dir(dst), filepath.Base(dst)+".*.new")
+	if err != nil {
+		return "", fmt.Errorf("could not create temporary file for the registry: %w", err)
+	}
+	f.Close()
+
+	tmp := f.Name()
+	defer removeDownload(tmp)
+
+	fetched, err := fetch.Fetch(src, &fetch.Options{Destination: tmp, Overwrite: true, Progress: true})
+	if err != nil {
+		return "", fmt.Errorf("error obtaining registry: %w", err)
+	}
+
+	if _, err := justinstall.LoadRegistry(fetched); err != nil {
+		if dry.FileExists(dst) {
+			return "", fmt.Errorf("%w (keeping the previous local copy)", err)
+		}
+
+		return "", err
+	}
+
+	// Local registries are used in place, there is nothing to replace.
+	if fetched != tmp {
+		return fetched, nil
+	}
+
+	if err := os.Rename(tmp, dst); err != nil {
+		return "", fmt.Errorf("could not replace %v: %w", dst, err)
+	}
+
+	return dst, nil
+}
+
+// removeDownload removes the given download, along with whatever an interrupted download left next
+// to it.
+func removeDownload(path string) {
+	os.Remove(path)
+
+	leftovers, _ := filepath.Glob(path + ".*")
+	for _, leftover := range leftovers {
+		os.Remove(leftover)
+	}
+}
+
+// registryChannel returns the release channel selected on the command line.
+func registryChannel(c *cli.Context) (string, error) {
+	channel := c.String("channel")
+	if channel == "" {
+		return "stable", nil
+	}
+
+	if _, ok := registryURLs[channel]; !ok {
+		return "", fmt.Errorf("unknown channel: %v", channel)
+	}
+
+	return channel, nil
+}
+
+// registrySource returns the path or URL of the registry selected on the command line.
+func registrySource(c *cli.Context) (string, error) {
+	if c.IsSet("registry") {
+		return c.String("registry"), nil
+	}
+
+	channel, err := registryChannel(c)
+	if err != nil {
+		return "", err
+	}
+
+	return registryURLs[channel], nil
+}
+
+// registryCachePath returns the path of the local copy of the registry selected on the command line,
+// which can be overridden with --registry-cache-file.
+func registryCachePath(c *cli.Context) (string, error) {
+	channel, err := registryChannel(c)
+	if err != nil {
+		return "", err
+	}
+
+	if c.IsSet("registry-cache-file") {
+		return c.String("registry-cache-file"), nil
+	}
+
+	if c.IsSet("registry") {
+		ret, err := paths.CacheFileCreate("registry-custom.json")
+		if err != nil {
+			return "", fmt.Errorf("could not create cache directory to hold custom registry file: %w", err)
+		}
+
+		return ret, nil
+	}
+
+	name := "registry.json"
+	if channel != "stable" {
+		name = "registry-" + channel + ".json"
+	}
+
+	ret, err := paths.CacheFileCreate(name)
+	if err != nil {
+		return "", fmt.Errorf("could not create cache directory to hold registry file: %w", err)
+	}
+
+	return ret, nil
+}
+
+// registryAge returns how long ago the local copy of the registry was downloaded. Local registries
+// are used in place, their age is that of the file itself.
+func registryAge(c *cli.Context) (time.Duration, error) {
+	src, err := registrySource(c)
+	if err != nil {
+		return 0, err
+	}
+
+	if dry.FileExists(src) {
+		return time.Since(dry.FileTimeModified(src)), nil
+	}
+
+	path, err := registryCachePath(c)
+	if err != nil {
+		return 0, err
+	}
+
+	if !dry.FileExists(path) {
+		return 0, fmt.Errorf("no local copy of the registry at %v", path)
+	}
+
+	return time.Since(dry.FileTimeModified(path)), nil
+}
+
+// registryHistorySize is how many versions of each registry are kept in its history.
+const registryHistorySize = 10
+
+// snapshotTimeFormat is the format of the time a snapshot was taken, in its file name.
+const snapshotTimeFormat = "20060102T150405.000000000Z"
+
+// registrySnapshot is a version of the registry, as it was fetched at some point in time.
+type registrySnapshot struct {
+	Path string
+	Time time.Time
+}
+
+// registryHistoryPrefix returns the path prefix of the snapshots of the registry whose local copy
+// is at the given path, creating the history directory if needed.
+func registryHistoryPrefix(cachePath string) (string, error) {
+	cacheDir, err := paths.CacheDirCreate()
+	if err != nil {
+		return "", fmt.Errorf("could not create cache directory: %w", err)
+	}
+
+	dir := filepath.Join(cacheDir, "registry-history")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("could not create registry history directory: %w", err)
 	}
 
-	dst, err := fetch.Fetch(src, &fetch.Options{Destination: dst, Progress: true})
+	name := strings.TrimSuffix(filepath.Base(cachePath), filepath.Ext(cachePath))
+
+	return filepath.Join(dir, name+"-"), nil
+}
+
+// registrySnapshots returns the snapshots of the registry whose local copy is at the given path,
+// oldest first.
+func registrySnapshots(cachePath string) ([]registrySnapshot, error) {
+	prefix, err := registryHistoryPrefix(cachePath)
 	if err != nil {
-		return nil, fmt.Errorf("error obtaining registry: %w", err)
+		return nil, err
+	}
+
+	matches, err := filepath.Glob(prefix + "*.json")
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []registrySnapshot
+	for _, match := range matches {
+		taken, err := time.Parse(snapshotTimeFormat, strings.TrimSuffix(strings.TrimPrefix(match, prefix), ".json"))
+		if err != nil {
+			continue // Another registry, whose name starts with the same prefix
+		}
+
+		ret = append(ret, registrySnapshot{match, taken})
+	}
+
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Time.Before(ret[j].Time) })
+
+	return ret, nil
+}
+
+// saveRegistrySnapshot adds the registry at the given path to the history of the registry whose
+// local copy is at cachePath, unless it is the same as the latest snapshot. Only the latest
+// registryHistorySize snapshots are kept.
+func saveRegistrySnapshot(path string, cachePath string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	snapshots, err := registrySnapshots(cachePath)
+	if err != nil {
+		return err
+	}
+
+	if len(snapshots) > 0 {
+		if latest, err := ioutil.ReadFile(snapshots[len(snapshots)-1].Path); err == nil && bytes.Equal(latest, data) {
+			return nil
+		}
+	}
+
+	prefix, err := registryHistoryPrefix(cachePath)
+	if err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(prefix+time.Now().UTC().Format(snapshotTimeFormat)+".json", data, 0644); err != nil {
+		return err
+	}
+
+	for len(snapshots) >= registryHistorySize {
+		os.Remove(snapshots[0].Path)
+		snapshots = snapshots[1:]
 	}
 
-	ret := justinstall.LoadRegistry(dst)
-	return &ret, nil
+	return nil
 }
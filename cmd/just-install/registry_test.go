@@ -0,0 +1,199 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/just-install/just-install/pkg/justinstall"
+)
+
+// testRegistry returns a valid registry with a single package at the given version.
+func testRegistry(version string) string {
+	return fmt.Sprintf(`{"version": %v, "packages": {"example": {"version": %q, "installer": {"kind": "as-is", "x86": "https://example.com/setup.exe"}}}}`, justinstall.RegistryVersion, version)
+}
+
+// serveRegistry serves the given body with the given status code. The registry is at the URL of the
+// returned server, followed by /registry.json.
+func serveRegistry(status int, body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+}
+
+// cachedRegistry writes a valid cached registry in a new temporary directory. It returns the path
+// of the registry and a function that removes the directory.
+func cachedRegistry(t *testing.T) (string, func()) {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "just-install-registry")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "just-install.json")
+	if err := ioutil.WriteFile(path, []byte(testRegistry("1.0")), 0644); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+
+	return path, func() { os.RemoveAll(dir) }
+}
+
+// assertOnlyRegistry fails the test if anything but the registry at the given path is left in its
+// directory.
+func assertOnlyRegistry(t *testing.T, path string) {
+	t.Helper()
+
+	files, err := ioutil.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, f := range files {
+		if f.Name() != filepath.Base(path) {
+			t.Errorf("left %v behind", f.Name())
+		}
+	}
+}
+
+func TestUpdateRegistryKeepsCachedCopyOnBadDownload(t *testing.T) {
+	valid := testRegistry("2.0")
+
+	tests := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{"truncated", http.StatusOK, valid[:len(valid)/2]},
+		{"empty", http.StatusOK, ""},
+		{"not JSON", http.StatusOK, "<html><body>Service unavailable</body></html>"},
+		{"unsupported version", http.StatusOK, `{"version": 1, "packages": {}}`},
+		{"server error", http.StatusInternalServerError, valid},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dst, cleanup := cachedRegistry(t)
+			defer cleanup()
+
+			server := serveRegistry(test.status, test.body)
+			defer server.Close()
+
+			if _, err := updateRegistry(server.URL+"/registry.json", dst); err == nil {
+				t.Fatal("expected an error")
+			}
+
+			data, err := ioutil.ReadFile(dst)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != testRegistry("1.0") {
+				t.Errorf("cached registry changed to %s", data)
+			}
+
+			assertOnlyRegistry(t, dst)
+		})
+	}
+}
+
+func TestUpdateRegistryReplacesCachedCopy(t *testing.T) {
+	dst, cleanup := cachedRegistry(t)
+	defer cleanup()
+
+	server := serveRegistry(http.StatusOK, testRegistry("2.0"))
+	defer server.Close()
+
+	path, err := updateRegistry(server.URL+"/registry.json", dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != dst {
+		t.Errorf("expected the registry at %v, got %v", dst, path)
+	}
+
+	registry, err := justinstall.LoadRegistry(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version := registry.Packages["example"].Version; version != "2.0" {
+		t.Errorf("expected version 2.0, got %v", version)
+	}
+
+	assertOnlyRegistry(t, dst)
+}
+
+func TestUpdateRegistryConcurrently(t *testing.T) {
+	dst, cleanup := cachedRegistry(t)
+	defer cleanup()
+
+	server := serveRegistry(http.StatusOK, testRegistry("2.0"))
+	defer server.Close()
+
+	errs := make(chan error)
+	for i := 0; i < 8; i++ {
+		go func() {
+			_, err := updateRegistry(server.URL+"/registry.json", dst)
+			errs <- err
+		}()
+	}
+
+	for i := 0; i < 8; i++ {
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	}
+
+	if _, err := justinstall.LoadRegistry(dst); err != nil {
+		t.Error(err)
+	}
+
+	assertOnlyRegistry(t, dst)
+}
+
+func TestRegistryAgeOfLocalRegistry(t *testing.T) {
+	path, cleanup := cachedRegistry(t)
+	defer cleanup()
+
+	modified := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(path, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+
+	set := flag.NewFlagSet("list", flag.ContinueOnError)
+	set.String("registry", "", "")
+	set.Set("registry", path)
+
+	age, err := registryAge(cli.NewContext(cli.NewApp(), set, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if age < 2*time.Hour || age > 3*time.Hour {
+		t.Errorf("expected %v to be 2h old, got %v", path, age)
+	}
+}
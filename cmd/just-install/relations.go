@@ -0,0 +1,163 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/ungerik/go-dry"
+
+	"github.com/just-install/just-install/pkg/justinstall"
+	"github.com/just-install/just-install/pkg/state"
+)
+
+// conflicts returns, for each of the given packages, an error describing what it conflicts with,
+// either among the installed packages or the other given ones. With uninstallReplaced, installed
+// packages replaced by a package don't conflict with it, since they are uninstalled first.
+func conflicts(registry *justinstall.Registry, pkgs []string, st *state.State, uninstallReplaced bool) map[string]error {
+	ret := make(map[string]error)
+
+	for _, pkg := range pkgs {
+		var with []string
+
+		for _, installed := range sortedStatePackages(st) {
+			if uninstallReplaced && dry.StringInSlice(installed, registry.Packages[pkg].Replaces) {
+				continue
+			}
+
+			if registry.Conflict(pkg, installed) {
+				with = append(with, installed+" (installed)")
+			}
+		}
+
+		for _, other := range pkgs {
+			if registry.Conflict(pkg, other) {
+				with = append(with, other)
+			}
+		}
+
+		if len(with) > 0 {
+			ret[pkg] = fmt.Errorf("conflicts with %v", strings.Join(with, ", "))
+		}
+	}
+
+	return ret
+}
+
+// shimClashes returns, for each of the given packages, an error describing which of the other
+// given packages create shims with the same names, that would replace each other. Packages in
+// archs are checked for the given architecture, instead of arch.
+func shimClashes(registry *justinstall.Registry, pkgs []string, archs map[string]string, arch string) map[string]error {
+	owners := make(map[string][]string) // Shim name, lower case -> packages creating it
+
+	for _, pkg := range pkgs {
+		entry, ok := registry.Packages[pkg]
+		if !ok {
+			continue
+		}
+
+		pkgArch := arch
+		if a, ok := archs[pkg]; ok {
+			pkgArch = a
+		}
+
+		for _, name := range entry.ShimNames(pkgArch) {
+			owners[strings.ToLower(name)] = append(owners[strings.ToLower(name)], pkg)
+		}
+	}
+
+	clashes := make(map[string]map[string][]string) // Package -> other package -> shim names
+	for name, creators := range owners {
+		for _, pkg := range creators {
+			for _, other := range creators {
+				if other == pkg {
+					continue
+				}
+
+				if clashes[pkg] == nil {
+					clashes[pkg] = make(map[string][]string)
+				}
+				clashes[pkg][other] = append(clashes[pkg][other], name)
+			}
+		}
+	}
+
+	ret := make(map[string]error)
+	for pkg, others := range clashes {
+		var with []string
+		for _, other := range pkgs {
+			if names, ok := others[other]; ok {
+				sort.Strings(names)
+				with = append(with, fmt.Sprintf("%v (%v)", other, strings.Join(names, ", ")))
+			}
+		}
+
+		ret[pkg] = fmt.Errorf("creates the same shims as %v", strings.Join(with, ", "))
+	}
+
+	return ret
+}
+
+// uninstallReplaced uninstalls the installed packages replaced by the given one, if asked to, or
+// warns about them otherwise.
+func uninstallReplaced(ctx context.Context, registry *justinstall.Registry, pkg string, st *state.State, options *justinstall.Options, uninstall bool) error {
+	for _, replaced := range registry.Packages[pkg].Replaces {
+		installed, ok := st.Packages[replaced]
+		if !ok {
+			continue
+		}
+
+		if !uninstall {
+			log.Printf("WARNING: %v replaces %v, which is still installed (see --uninstall-replaced)", pkg, replaced)
+			continue
+		}
+
+		entry, err := registry.Package(replaced)
+		if err != nil {
+			return fmt.Errorf("cannot uninstall %v, replaced by %v: %w", replaced, pkg, err)
+		}
+
+		log.Println("uninstalling", replaced, "since it is replaced by", pkg)
+		for _, arch := range installed.Architectures() {
+			if err := entry.Uninstall(ctx, arch, uninstallOptions(options, installed)); err != nil {
+				return fmt.Errorf("cannot uninstall %v, replaced by %v: %w", replaced, pkg, err)
+			}
+		}
+
+		st.Forget(replaced)
+		if err := st.Save(); err != nil {
+			log.Println("WARNING: could not record the removal of", replaced+":", err)
+		}
+	}
+
+	return nil
+}
+
+// sortedStatePackages returns the names of the installed packages, sorted alphabetically.
+func sortedStatePackages(st *state.State) []string {
+	var ret []string
+	for name := range st.Packages {
+		ret = append(ret, name)
+	}
+
+	sort.Strings(ret)
+
+	return ret
+}
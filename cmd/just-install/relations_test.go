@@ -0,0 +1,45 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/just-install/just-install/pkg/justinstall"
+	"github.com/just-install/just-install/pkg/state"
+)
+
+func TestConflictsWithReplaced(t *testing.T) {
+	registry := &justinstall.Registry{Packages: map[string]justinstall.RegistryEntry{
+		"new": {Conflicts: []string{"old"}, Replaces: []string{"old"}},
+		"old": {},
+	}}
+
+	st := &state.State{Packages: map[string]state.Package{"old": {Version: "1.0", Arch: "x86_64"}}}
+
+	if _, ok := conflicts(registry, []string{"new"}, st, false)["new"]; !ok {
+		t.Error("expected new to conflict with the installed old")
+	}
+
+	if err, ok := conflicts(registry, []string{"new"}, st, true)["new"]; ok {
+		t.Errorf("expected no conflict with old, which is uninstalled first, got %v", err)
+	}
+
+	// Installing both together still conflicts
+	if _, ok := conflicts(registry, []string{"new", "old"}, st, true)["new"]; !ok {
+		t.Error("expected new to conflict with old")
+	}
+}
@@ -0,0 +1,105 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/just-install/just-install/pkg/justinstall"
+)
+
+// summaryFile is what --summary-file writes at the end of a run, for auditing.
+type summaryFile struct {
+	JustInstallVersion string           `json:"justInstallVersion"`
+	StartedAt          time.Time        `json:"startedAt"`
+	FinishedAt         time.Time        `json:"finishedAt"`
+	Duration           float64          `json:"duration"` // Seconds
+	Success            bool             `json:"success"`
+	Error              string           `json:"error,omitempty"`
+	Packages           []summaryPackage `json:"packages"`
+}
+
+// summaryPackage is the outcome of a single package in a summaryFile.
+type summaryPackage struct {
+	Name        string  `json:"name"`
+	Result      string  `json:"result"` // "succeeded", "failed" or "skipped"
+	Arch        string  `json:"arch,omitempty"`
+	Version     string  `json:"version,omitempty"`
+	Duration    float64 `json:"duration,omitempty"` // Seconds
+	Uninstalled bool    `json:"uninstalled,omitempty"`
+
+	Verification []justinstall.CheckResult `json:"verification,omitempty"` // With --verify-after-install
+}
+
+// writeSummaryFile writes the summary of a run that ended with the given outcome to the file at the
+// given path, replacing it.
+func writeSummaryFile(path string, summary *batchSummary, batchErr error) error {
+	now := time.Now()
+
+	ret := summaryFile{
+		JustInstallVersion: version,
+		StartedAt:          summary.StartedAt,
+		FinishedAt:         now,
+		Duration:           now.Sub(summary.StartedAt).Seconds(),
+		Success:            batchErr == nil,
+		Packages:           []summaryPackage{},
+	}
+	if batchErr != nil {
+		ret.Error = batchErr.Error()
+	}
+
+	results := make(map[string]string)
+	for _, outcome := range []struct {
+		result string
+		pkgs   []string
+	}{{"skipped", summary.Skipped}, {"failed", summary.Failed}, {"succeeded", summary.Succeeded}} {
+		for _, pkg := range outcome.pkgs {
+			results[pkg] = outcome.result
+		}
+	}
+
+	uninstalled := make(map[string]bool)
+	for _, pkg := range summary.Uninstalled {
+		uninstalled[pkg] = true
+	}
+
+	for _, pkg := range summary.Packages {
+		p := summaryPackage{Name: pkg, Result: results[pkg], Uninstalled: uninstalled[pkg], Verification: summary.Verification[pkg]}
+		if p.Result == "" {
+			p.Result = "skipped" // Not reached, i.e. because the registry could not be loaded
+		}
+
+		if run, ok := summary.Runs[pkg]; ok {
+			p.Arch, p.Version, p.Duration = run.Arch, run.Version, run.Duration.Seconds()
+		}
+
+		ret.Packages = append(ret.Packages, p)
+	}
+
+	data, err := json.MarshalIndent(&ret, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("could not write the summary file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/just-install/just-install/pkg/redact"
+)
+
+// warningPrefix is how warnings start, after the header of the logger.
+const warningPrefix = "WARNING: "
+
+// logHeader matches the header that the standard logger writes before each message, with its
+// default flags.
+var logHeader = regexp.MustCompile(`^\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2} `)
+
+// warningCounter is the output of the logger, which keeps track of the warnings written to it so
+// that they can be summarised at the end of a run. Sensitive values are redacted from everything
+// written to it.
+type warningCounter struct {
+	mutex    sync.Mutex
+	out      io.Writer
+	warnings []string
+}
+
+func (w *warningCounter) Write(p []byte) (int, error) {
+	n := len(p)
+	p = []byte(redact.String(string(p)))
+
+	// Only count messages logged as warnings, not those that merely mention one (i.e. the output of
+	// an installer).
+	message := p
+	if header := logHeader.Find(p); header != nil {
+		message = p[len(header):]
+	}
+
+	if bytes.HasPrefix(message, []byte(warningPrefix)) {
+		w.mutex.Lock()
+		w.warnings = append(w.warnings, strings.TrimSpace(string(message[len(warningPrefix):])))
+		w.mutex.Unlock()
+	}
+
+	if _, err := w.out.Write(p); err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// Warnings returns the warnings written so far.
+func (w *warningCounter) Warnings() []string {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	return append([]string(nil), w.warnings...)
+}
@@ -0,0 +1,39 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"reflect"
+	"testing"
+)
+
+func TestWarningCounter(t *testing.T) {
+	w := &warningCounter{out: ioutil.Discard}
+	logger := log.New(w, "", log.LstdFlags)
+
+	logger.Println("WARNING: no checksum available")
+	logger.Println("fetching https://example.com/WARNING: not a warning")
+	logger.Printf("WARNING: not associating %v: %v", ".txt", "chosen by the user")
+	w.Write([]byte("installer output with a WARNING: in it\n"))
+	w.Write([]byte("WARNING: written without a header\n"))
+
+	expected := []string{"no checksum available", "not associating .txt: chosen by the user", "written without a header"}
+	if warnings := w.Warnings(); !reflect.DeepEqual(warnings, expected) {
+		t.Errorf("expected %q, got %q", expected, warnings)
+	}
+}
@@ -8,4 +8,5 @@ require (
 	github.com/ungerik/go-dry v0.0.0-20180411133923-654ae31114c8
 	github.com/urfave/cli v1.22.2 // indirect
 	github.com/urfave/cli/v2 v2.1.1
+	golang.org/x/sys v0.0.0-20191128015809-6d18c012aee9
 )
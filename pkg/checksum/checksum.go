@@ -0,0 +1,191 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package checksum
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+)
+
+// Supported algorithms.
+const (
+	MD5    = "md5"
+	SHA1   = "sha1"
+	SHA256 = "sha256"
+	SHA512 = "sha512"
+)
+
+// algorithms lists the supported algorithms, from the strongest to the weakest.
+var algorithms = []struct {
+	name string
+	new  func() hash.Hash
+	weak bool
+}{
+	{SHA512, sha512.New, false},
+	{SHA256, sha256.New, false},
+	{SHA1, sha1.New, true},
+	{MD5, md5.New, true},
+}
+
+// ErrMismatch matches any MismatchError with errors.Is.
+var ErrMismatch = errors.New("checksum mismatch")
+
+// MismatchError describes a file whose digest doesn't match the expected one.
+type MismatchError struct {
+	Algorithm string
+	Expected  string
+	Received  string
+	Path      string
+}
+
+func (m *MismatchError) Error() string {
+	return fmt.Sprintf("expected %v checksum %v but computed %v instead (%v)", m.Algorithm, m.Expected, m.Received, m.Path)
+}
+
+func (m *MismatchError) Is(target error) bool {
+	return target == ErrMismatch
+}
+
+// Supported returns whether the given algorithm is supported.
+func Supported(algorithm string) bool {
+	for _, a := range algorithms {
+		if a.name == strings.ToLower(algorithm) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Weak returns whether the given algorithm is considered too weak to guarantee integrity.
+func Weak(algorithm string) bool {
+	for _, a := range algorithms {
+		if a.name == strings.ToLower(algorithm) {
+			return a.weak
+		}
+	}
+
+	return true
+}
+
+// Strongest returns the strongest supported algorithm among the keys of the given map of algorithm
+// to digest, or an empty string if there is none.
+func Strongest(digests map[string]string) string {
+	for _, a := range algorithms {
+		for k, v := range digests {
+			if strings.ToLower(k) == a.name && v != "" {
+				return a.name
+			}
+		}
+	}
+
+	return ""
+}
+
+// File returns the hex-encoded SHA-256 digest of the file at the given path.
+func File(path string) (string, error) {
+	return FileWith(path, SHA256)
+}
+
+// FileWith returns the hex-encoded digest of the file at the given path, computed with the given
+// algorithm.
+func FileWith(path string, algorithm string) (string, error) {
+	var h hash.Hash
+	for _, a := range algorithms {
+		if a.name == strings.ToLower(algorithm) {
+			h = a.new()
+			break
+		}
+	}
+
+	if h == nil {
+		return "", fmt.Errorf("unsupported checksum algorithm: %v", algorithm)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Verify returns a MismatchError if the SHA-256 digest of the file at the given path isn't the
+// expected one. The expected digest is case-insensitive.
+func Verify(path string, expected string) error {
+	return VerifyWith(path, SHA256, expected)
+}
+
+// VerifyWith is like Verify, but computes the digest with the given algorithm.
+func VerifyWith(path string, algorithm string, expected string) error {
+	received, err := FileWith(path, algorithm)
+	if err != nil {
+		return err
+	}
+
+	if !strings.EqualFold(received, expected) {
+		return &MismatchError{strings.ToLower(algorithm), expected, received, path}
+	}
+
+	return nil
+}
+
+// ParseSums parses a checksum file, as published by many projects next to their downloads, and
+// returns a map of file name to hex-encoded digest. Both the format of `sha256sum` and friends
+// ("<digest>  <name>", with an optional "*" before binary file names) and the BSD one
+// ("SHA256 (<name>) = <digest>") are understood. Other lines are ignored.
+func ParseSums(data string) map[string]string {
+	ret := make(map[string]string)
+
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+
+		if i := strings.Index(line, " ("); i > 0 && strings.Contains(line, ") = ") {
+			rest := line[i+2:]
+			j := strings.LastIndex(rest, ") = ")
+			ret[rest[:j]] = strings.ToLower(strings.TrimSpace(rest[j+4:]))
+			continue
+		}
+
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) != 2 {
+			continue
+		}
+
+		if _, err := hex.DecodeString(fields[0]); err != nil || fields[0] == "" {
+			continue
+		}
+
+		name := strings.TrimPrefix(strings.TrimSpace(fields[1]), "*")
+		ret[name] = strings.ToLower(fields[0])
+	}
+
+	return ret
+}
@@ -16,28 +16,84 @@
 package cmd
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"io"
 	"log"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"syscall"
+
+	"github.com/just-install/just-install/pkg/redact"
 )
 
+// exitCode is a non-zero exit code of an installer program that doesn't signal a failure.
+type exitCode struct {
+	program string // Lower-case name of the program, without extension
+	code    uint32
+	message string
+}
+
+var successExitCodes = []exitCode{
+	{"msiexec", 3010, "msiexec exited with code 3010, a reboot is required to complete installation"},
+	{"wusa", 3010, "wusa exited with code 3010, a reboot is required to complete installation"},
+	{"wusa", 0x240006, "wusa exited with code 0x240006, the update is already installed"},
+}
+
+// failureExitCodes contains messages for known failure exit codes that are otherwise cryptic.
+var failureExitCodes = []exitCode{
+	{"wusa", 0x80240017, "the update is not applicable to this system"},
+}
+
+// ExitError is returned when a command exits with a code that signals a failure.
+type ExitError struct {
+	Program string // Lower-case name of the program, without extension
+	Code    int
+	Err     error
+}
+
+func (e *ExitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
 // Run runs a command, printing the command line to standard output. Additional output is printed in
-// case we run msiexec and it returns with code 3010 (short for "reboot needed").
+// case we run msiexec or wusa and they return with code 3010 (short for "reboot needed") or other
+// known codes.
 func Run(args ...string) error {
+	return RunContext(context.Background(), args...)
+}
+
+// RunContext is like Run, but kills the command if the context is done before it exits.
+func RunContext(ctx context.Context, args ...string) error {
+	return RunOutput(ctx, nil, args...)
+}
+
+// RunOutput is like RunContext, but writes the standard output and error of the command to out,
+// unless it's nil.
+func RunOutput(ctx context.Context, out io.Writer, args ...string) error {
 	if len(args) < 1 {
 		return errors.New("empty command line")
 	}
 
 	var cmd *exec.Cmd
 	if len(args) == 1 {
-		cmd = exec.Command(args[0])
+		cmd = exec.CommandContext(ctx, args[0])
 	} else {
-		cmd = exec.Command(args[0], args[1:]...)
+		cmd = exec.CommandContext(ctx, args[0], args[1:]...)
+	}
+
+	if out != nil {
+		cmd.Stdout = out
+		cmd.Stderr = out
 	}
 
-	log.Println("running", strings.Join(args, " "))
+	log.Println("running", redact.String(strings.Join(args, " ")))
 
 	err := cmd.Start()
 	if err != nil {
@@ -55,13 +111,25 @@ func Run(args ...string) error {
 			return err
 		}
 
-		// msiexec returns 3010 if install needs reboot later
-		if strings.Contains(args[0], "msiexec") && status.ExitStatus() == 3010 {
-			log.Printf("msiexec exited with code 3010, a reboot is required to complete installation")
-			return nil
+		program := strings.ToLower(strings.TrimSuffix(filepath.Base(args[0]), filepath.Ext(args[0])))
+		code := uint32(status.ExitStatus())
+
+		for _, e := range successExitCodes {
+			if program == e.program && code == e.code {
+				log.Println(e.message)
+				return nil
+			}
 		}
 
-		return err
+		exitErr := &ExitError{program, int(status.ExitStatus()), err}
+
+		for _, e := range failureExitCodes {
+			if program == e.program && code == e.code {
+				return fmt.Errorf("%v: %w", e.message, exitErr)
+			}
+		}
+
+		return exitErr
 	}
 
 	return nil
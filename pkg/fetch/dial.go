@@ -0,0 +1,54 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetch
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// dialer establishes all connections to remote hosts.
+var dialer = &net.Dialer{
+	DualStack: true,
+	KeepAlive: 30 * time.Second,
+	Timeout:   ConnectionPhaseTimeout,
+}
+
+// network is the network connections are forced to, set by PreferIPv4 and PreferIPv6. Empty means
+// dual-stack, where the dialer races IPv4 and IPv6 connections ("Happy Eyeballs").
+var network = ""
+
+// PreferIPv4 makes all connections from now on use IPv4 only, for networks where IPv6 is broken
+// and connections hang until they time out.
+func PreferIPv4() {
+	network = "tcp4"
+}
+
+// PreferIPv6 makes all connections from now on use IPv6 only.
+func PreferIPv6() {
+	network = "tcp6"
+}
+
+// dialContext connects to the given address as net.Dialer.DialContext does, honoring PreferIPv4 and
+// PreferIPv6.
+func dialContext(ctx context.Context, defaultNetwork string, address string) (net.Conn, error) {
+	if network != "" && defaultNetwork == "tcp" {
+		defaultNetwork = network
+	}
+
+	return dialer.DialContext(ctx, defaultNetwork, address)
+}
@@ -16,6 +16,7 @@
 package fetch
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -54,12 +55,43 @@ func (c *ContentTypeError) Error() string {
 	return fmt.Sprintf("unexpected Content-Type %v (%v)", c.Received, c.Resource)
 }
 
+// SizeMismatchError describes a download whose size does not match the Content-Length announced by
+// the server, most likely because the transfer was truncated.
+type SizeMismatchError struct {
+	Expected int64
+	Received int64
+	Resource string
+}
+
+func (s *SizeMismatchError) Error() string {
+	return fmt.Sprintf("expected %v bytes but received %v instead (%v)", s.Expected, s.Received, s.Resource)
+}
+
+// DefaultMaxRedirects is how many redirects Fetch follows by default, as the standard library does.
+const DefaultMaxRedirects = 10
+
 // Options that influence Fetch.
 type Options struct {
-	Destination string      // Can either be a file path or a directory path. If it's a directory, it must already exist.
-	Overwrite   bool        // Overwrites existing file.
-	Progress    bool        // Whether to show the progress indicator.
-	HTTP        HTTPOptions // HTTP client options.
+	Context     context.Context // Cancels the download when done. Defaults to context.Background().
+	CopyLocal   bool            // Copies local files to Destination, instead of returning their path.
+	Destination string          // Can either be a file path or a directory path. If it's a directory, it must already exist.
+	Overwrite   bool            // Overwrites existing file.
+	Progress    bool            // Whether to show the progress indicator.
+	HTTP        HTTPOptions     // HTTP client options.
+
+	// OnProgress, if set, is called with the number of bytes downloaded so far and the size of the
+	// file (negative if unknown) as the download proceeds. It's called concurrently when
+	// downloading with multiple connections.
+	OnProgress func(written int64, total int64)
+
+	// MaxRedirects is how many redirects are followed before giving up, DefaultMaxRedirects if zero.
+	MaxRedirects int
+
+	// Segments is the number of parallel connections used to download a single file, for servers
+	// that support range requests. Files smaller than SegmentThreshold (DefaultSegmentThreshold if
+	// zero) are always downloaded with a single connection.
+	Segments         int
+	SegmentThreshold int64
 }
 
 // HTTPOptions contains cookies and headers to send when making an HTTP request.
@@ -67,6 +99,7 @@ type HTTPOptions struct {
 	CheckRedirect func(req *http.Request, via []*http.Request) error // Same as http.Client.CheckRedirect
 	Cookies       map[string][2]string                               // URL -> (Cookie name, Cookie Value)
 	Headers       map[string]string                                  // Header -> Value
+	Proxy         string                                             // Proxy URL to use instead of the one from the environment, or Direct
 }
 
 // CheckOptions are options that influence Check.
@@ -80,7 +113,11 @@ type CheckOptions struct {
 // entries.
 func Check(resource string, options *CheckOptions) error {
 	// Shortcut: resource is a local file and we can return immediately
-	if dry.FileExists(resource) {
+	if path, ok := localPath(resource); ok {
+		if !dry.FileExists(path) {
+			return fmt.Errorf("no such file: %v", path)
+		}
+
 		return nil
 	}
 
@@ -90,17 +127,17 @@ func Check(resource string, options *CheckOptions) error {
 		return err
 	}
 
-	if parsedURL.Scheme == "file" {
-		return errors.New("cannot check local files")
+	// Options
+	if options == nil {
+		options = &CheckOptions{}
 	}
 
-	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
-		return fmt.Errorf("unsupported URL scheme: %v", parsedURL.Scheme)
+	if parsedURL.Scheme == "ftp" || parsedURL.Scheme == "ftps" {
+		return checkFTP(parsedURL, &options.Options)
 	}
 
-	// Options
-	if options == nil {
-		options = &CheckOptions{}
+	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
+		return fmt.Errorf("unsupported URL scheme: %v", parsedURL.Scheme)
 	}
 
 	// Request
@@ -123,11 +160,25 @@ func Check(resource string, options *CheckOptions) error {
 }
 
 // Fetch obtains the given resource, either a local file or something that can be download via
-// HTTP/HTTPS, to a file on disk. Returns the path to the fetched file or an error.
+// HTTP/HTTPS or FTP/FTPS, to a file on disk. Returns the path to the fetched file or an error.
 func Fetch(resource string, options *Options) (string, error) {
-	// Shortcut: resource is a local file and we can return its path immediately.
-	if dry.FileExists(resource) {
-		return resource, nil
+	// Options
+	if options == nil {
+		options = &Options{}
+	}
+
+	// Shortcut: resource is a local file and we can return its path immediately, unless asked to
+	// copy it.
+	if path, ok := localPath(resource); ok {
+		if !options.CopyLocal {
+			return path, nil
+		}
+
+		if options.Destination == "" {
+			return "", errors.New("destination must be either a file or directory path")
+		}
+
+		return copyLocal(path, options)
 	}
 
 	// Parse resource URL
@@ -136,29 +187,38 @@ func Fetch(resource string, options *Options) (string, error) {
 		return "", err
 	}
 
-	if parsedURL.Scheme == "file" {
-		return parsedURL.Path, nil
+	if options.Destination == "" {
+		return "", errors.New("destination must be either a file or directory path")
 	}
 
-	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
-		return "", fmt.Errorf("unsupported URL scheme: %v", parsedURL.Scheme)
+	if parsedURL.Scheme == "ftp" || parsedURL.Scheme == "ftps" {
+		return fetchFTP(parsedURL, options)
 	}
 
-	// Options
-	if options == nil {
-		options = &Options{}
+	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
+		return "", fmt.Errorf("unsupported URL scheme: %v", parsedURL.Scheme)
 	}
 
-	if options.Destination == "" {
-		return "", errors.New("destination must be either a file or directory path")
+	// Request
+	maxRedirects := options.MaxRedirects
+	if maxRedirects <= 0 {
+		maxRedirects = DefaultMaxRedirects
 	}
 
-	// Request
 	var lastLocation *url.URL
 	options.HTTP.CheckRedirect = func(req *http.Request, via []*http.Request) error {
-		// This is the same check used by the CheckRedirect function used in the standard library
-		if len(via) >= 10 {
-			return errors.New("stopped after 10 redirects")
+		// Redirecting back to the same URL once is fine (i.e. after setting a cookie), twice is a loop
+		visits := 0
+		for _, previous := range via {
+			if previous.URL.String() == req.URL.String() {
+				visits++
+			}
+		}
+
+		if visits >= 2 {
+			return fmt.Errorf("redirect loop: %v keeps redirecting back to %v", resource, req.URL)
+		} else if len(via) >= maxRedirects {
+			return fmt.Errorf("stopped after %v redirects", maxRedirects)
 		}
 
 		// Store the last redirect
@@ -205,48 +265,127 @@ func Fetch(resource string, options *Options) (string, error) {
 		return dest, nil
 	}
 
+	// Resume an interrupted download of the same file, if any. The server sends the whole file
+	// again instead if it changed in the meantime, so that we never stitch together two versions.
+	validator := resumeValidator(resp)
+	var offset int64
+	if size, partialValidator, ok := loadPartial(dest); ok && validator != "" {
+		resumed, err := resume(resp.Request.URL.String(), size, partialValidator, options)
+		if err != nil {
+			// An interrupted download that cannot be resumed is of no use, download the whole file
+			// with the response we already have.
+			log.Printf("WARNING: %v, downloading it again in full", err)
+			discardPartial(dest)
+		} else {
+			resp.Body.Close()
+			defer resumed.Body.Close()
+
+			if resumed.StatusCode == http.StatusPartialContent {
+				log.Printf("resuming download of %v from %v bytes", resource, size)
+				offset, validator = size, partialValidator
+			} else {
+				log.Printf("%v changed since its download was interrupted, downloading it again", resource)
+				validator = resumeValidator(resumed)
+			}
+
+			resp = resumed
+		}
+	}
+
 	// Fetch to temporary file
 	destTmp := dest + ".download"
 
-	destTmpWriter, err := os.Create(destTmp)
+	var destTmpWriter *os.File
+	if offset > 0 {
+		if err := os.Rename(dest+partialSuffix, destTmp); err != nil {
+			return "", err
+		}
+
+		destTmpWriter, err = os.OpenFile(destTmp, os.O_WRONLY|os.O_APPEND, 0)
+	} else {
+		destTmpWriter, err = os.Create(destTmp)
+	}
 	if err != nil {
 		return "", err
 	}
 	defer destTmpWriter.Close()
+	discardPartial(dest)
+
+	// Keep interrupted downloads (i.e. when cancelled) to resume them later, if the server allows so
+	// safely. Downloads in multiple segments have holes, and are never kept.
+	segmented := offset == 0 && useSegments(resp, options)
+	if segmented {
+		validator = ""
+	}
+
+	completed := false
+	defer func() {
+		if !completed {
+			destTmpWriter.Close()
+			keepPartial(destTmp, dest, validator)
+		}
+	}()
 
-	var copyWriter io.WriteCloser = destTmpWriter
+	total := resp.ContentLength
+	if total >= 0 {
+		total += offset
+	}
+
+	var progressBar *pb.ProgressBar
 	if options.Progress {
 		log.Println("fetching", resource, "to", dest)
 
-		progressBar := pb.New64(resp.ContentLength)
-		progressBar.Set(pb.Bytes, true)
-		progressBar.SetRefreshRate(time.Second)
-		defer progressBar.Finish()
-
-		copyWriter = progressBar.NewProxyWriter(destTmpWriter)
-		defer copyWriter.Close()
+		if !progressDisabled {
+			progressBar = pb.New64(total)
+			progressBar.Set(pb.Bytes, true)
+			progressBar.SetCurrent(offset)
+			progressBar.SetRefreshRate(time.Second)
+			defer progressBar.Finish()
 
-		progressBar.Start()
+			progressBar.Start()
+		}
 	}
 
-	//==============================================================================================
-	// NOTE: destTmpWriter and copyWriter may actually be the same thing here if `Options.Progress`
-	// is false.
-	//==============================================================================================
+	progress := newProgress(progressBar, total, options.OnProgress)
+	progress.written = offset
+
+	var written int64
+	if segmented {
+		// Fetch the file again in parallel ranged requests, directly from where we were redirected to.
+		resp.Body.Close()
 
-	if _, err := io.Copy(copyWriter, resp.Body); err != nil {
+		written, err = fetchSegments(resp.Request.URL.String(), resp.ContentLength, destTmpWriter, progress, options)
+	} else {
+		written, err = io.Copy(&progressWriter{destTmpWriter, progress}, resp.Body)
+	}
+	if err != nil {
 		return "", err
 	}
 
 	// Must explicitly close these before renaming the file, since defers run too late
-	copyWriter.Close()
 	destTmpWriter.Close()
 	resp.Body.Close()
 
+	// Catch truncated downloads even when we have nothing else to verify the file against. A
+	// negative Content-Length means the server didn't tell us how big the file is.
+	if resp.ContentLength >= 0 && written != resp.ContentLength {
+		return "", &SizeMismatchError{resp.ContentLength, written, resource}
+	}
+
+	// A resumed download must also add up to the whole file, as announced in Content-Range. If it
+	// doesn't, the partial download doesn't belong to this file and cannot be resumed: discard it.
+	if offset > 0 {
+		if size := contentRangeSize(resp); size >= 0 && offset+written != size {
+			validator = ""
+			return "", &SizeMismatchError{size, offset + written, resource}
+		}
+	}
+
 	// Move temporary file back to definitive place
 	if err := os.Rename(destTmp, dest); err != nil {
 		return "", err
 	}
+	completed = true
 
 	return dest, nil
 }
@@ -258,6 +397,10 @@ func get(resource string, options *Options) (*http.Response, error) {
 		return nil, err
 	}
 
+	if options.Context != nil {
+		req = req.WithContext(options.Context)
+	}
+
 	for k, v := range options.HTTP.Headers {
 		req.Header.Set(k, v)
 	}
@@ -276,7 +419,12 @@ func get(resource string, options *Options) (*http.Response, error) {
 		cookieJar.SetCookies(u, []*http.Cookie{&http.Cookie{Name: cookie[0], Value: cookie[1]}})
 	}
 
-	httpClient := NewClient()
+	transport, err := transportFor(options.HTTP.Proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient := newClient(transport)
 	httpClient.CheckRedirect = options.HTTP.CheckRedirect
 	httpClient.Jar = cookieJar
 
@@ -0,0 +1,339 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetch
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ungerik/go-dry"
+
+	"github.com/just-install/just-install/pkg/platform"
+)
+
+// epsvPort extracts the port from the reply to EPSV, i.e. "Entering Extended Passive Mode (|||6446|)".
+var epsvPort = regexp.MustCompile(`\(\|\|\|(\d+)\|\)`)
+
+// ftpConn is a minimal FTP client, only good enough to download files in passive mode. Connections
+// to "ftps" URLs are upgraded to TLS with AUTH TLS (explicit FTPS).
+type ftpConn struct {
+	conn      net.Conn // Replaced by the TLS connection by login, for FTPS
+	text      *textproto.Conn
+	host      string
+	tlsConfig *tls.Config // Only for FTPS
+
+	closed    chan struct{} // Closed by Close, stops watching the context
+	closeOnce sync.Once
+}
+
+// dialFTP connects to the server of the given FTP URL and logs in, with the credentials in the URL,
+// those stored in the Windows Credential Manager for "ftp://host" (or "ftps://host") or anonymously,
+// in this order. The connection is closed when the context is done.
+func dialFTP(ctx context.Context, u *url.URL) (*ftpConn, error) {
+	host := u.Hostname()
+	address := u.Host
+	if u.Port() == "" {
+		address = net.JoinHostPort(host, "21")
+	}
+
+	conn, err := dialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	// Closing the TCP connection also interrupts the TLS one that login layers over it, so watch
+	// conn rather than c.conn, which login replaces concurrently.
+	c := &ftpConn{conn: conn, text: textproto.NewConn(conn), host: host, closed: make(chan struct{})}
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-c.closed:
+		}
+	}()
+
+	if err := c.login(u); err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// login upgrades the connection to TLS if needed, then logs in.
+func (c *ftpConn) login(u *url.URL) error {
+	if _, _, err := c.text.ReadResponse(220); err != nil {
+		return err
+	}
+
+	if u.Scheme == "ftps" {
+		if _, _, err := c.cmd(234, "AUTH TLS"); err != nil {
+			return err
+		}
+
+		c.tlsConfig = &tls.Config{ServerName: c.host, ClientSessionCache: tls.NewLRUClientSessionCache(1)}
+		tlsConn := tls.Client(c.conn, c.tlsConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			return err
+		}
+
+		c.conn = tlsConn
+		c.text = textproto.NewConn(tlsConn)
+	}
+
+	user, password := "anonymous", "anonymous@"
+	if u.User != nil {
+		user = u.User.Username()
+		password, _ = u.User.Password()
+	} else if storedUser, storedPassword, err := platform.Credential(u.Scheme + "://" + u.Host); err == nil {
+		user, password = storedUser, storedPassword
+	}
+
+	code, _, err := c.cmd(0, "USER %v", user)
+	if err != nil {
+		return err
+	}
+
+	if code == 331 {
+		if _, _, err := c.cmd(230, "PASS %v", password); err != nil {
+			return err
+		}
+	} else if code != 230 {
+		return fmt.Errorf("FTP login failed with code %v", code)
+	}
+
+	if c.tlsConfig != nil {
+		// Protect the data connections as well.
+		if _, _, err := c.cmd(200, "PBSZ 0"); err != nil {
+			return err
+		}
+		if _, _, err := c.cmd(200, "PROT P"); err != nil {
+			return err
+		}
+	}
+
+	_, _, err = c.cmd(200, "TYPE I")
+	return err
+}
+
+// cmd sends a command and reads its reply, which must have the given code (or, if it's a single
+// digit, start with it). Unless it's zero, in which case any reply but an error one is accepted.
+func (c *ftpConn) cmd(expected int, format string, args ...interface{}) (int, string, error) {
+	id, err := c.text.Cmd(format, args...)
+	if err != nil {
+		return 0, "", err
+	}
+
+	c.text.StartResponse(id)
+	defer c.text.EndResponse(id)
+
+	code, message, err := c.text.ReadResponse(expected)
+	if err == nil && expected == 0 && code >= 400 {
+		err = &textproto.Error{Code: code, Msg: message}
+	}
+
+	return code, message, err
+}
+
+// size returns the size of the file at the given path, or -1 if the server doesn't say.
+func (c *ftpConn) size(name string) int64 {
+	_, message, err := c.cmd(213, "SIZE %v", name)
+	if err != nil {
+		return -1
+	}
+
+	size, err := strconv.ParseInt(strings.TrimSpace(message), 10, 64)
+	if err != nil {
+		return -1
+	}
+
+	return size
+}
+
+// passivePort enters passive mode, returning the port to open the data connection to. Data
+// connections always go to the host we're talking to, regardless of the address announced by the
+// server, which is often wrong behind NAT.
+func (c *ftpConn) passivePort() (string, error) {
+	if _, message, err := c.cmd(229, "EPSV"); err == nil {
+		if match := epsvPort.FindStringSubmatch(message); match != nil {
+			return match[1], nil
+		}
+	}
+
+	_, message, err := c.cmd(227, "PASV")
+	if err != nil {
+		return "", err
+	}
+
+	// i.e. "Entering Passive Mode (192,168,1,2,19,46)"
+	start, end := strings.Index(message, "("), strings.Index(message, ")")
+	if start < 0 || end < start {
+		return "", fmt.Errorf("invalid reply to PASV: %v", message)
+	}
+
+	fields := strings.Split(message[start+1:end], ",")
+	if len(fields) != 6 {
+		return "", fmt.Errorf("invalid reply to PASV: %v", message)
+	}
+
+	high, err1 := strconv.Atoi(strings.TrimSpace(fields[4]))
+	low, err2 := strconv.Atoi(strings.TrimSpace(fields[5]))
+	if err1 != nil || err2 != nil {
+		return "", fmt.Errorf("invalid reply to PASV: %v", message)
+	}
+
+	return strconv.Itoa(high<<8 | low), nil
+}
+
+// retrieve writes the contents of the file at the given path to w.
+func (c *ftpConn) retrieve(ctx context.Context, name string, w io.Writer) error {
+	port, err := c.passivePort()
+	if err != nil {
+		return err
+	}
+
+	dataConn, err := dialContext(ctx, "tcp", net.JoinHostPort(c.host, port))
+	if err != nil {
+		return err
+	}
+	defer dataConn.Close()
+
+	if c.tlsConfig != nil {
+		dataConn = tls.Client(dataConn, c.tlsConfig)
+	}
+
+	if _, _, err := c.cmd(1, "RETR %v", name); err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(w, dataConn); err != nil {
+		return err
+	}
+	dataConn.Close()
+
+	_, _, err = c.text.ReadResponse(226)
+	return err
+}
+
+// Close logs out and closes the connection.
+func (c *ftpConn) Close() error {
+	c.closeOnce.Do(func() { close(c.closed) })
+
+	c.text.Cmd("QUIT")
+	return c.conn.Close()
+}
+
+// ftpPath returns the path of the file referenced by the given FTP URL, relative to the directory
+// we log into (see RFC 1738).
+func ftpPath(u *url.URL) string {
+	return strings.TrimPrefix(u.Path, "/")
+}
+
+// fetchFTP downloads the file at the given FTP URL, the same way Fetch does for HTTP.
+func fetchFTP(u *url.URL, options *Options) (string, error) {
+	ctx := options.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	dest := options.Destination
+	if dry.FileIsDir(dest) {
+		dest = filepath.Join(dest, path.Base(u.Path))
+	}
+
+	// File already exists, return its path.
+	if dry.FileExists(dest) && !options.Overwrite {
+		return dest, nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	c, err := dialFTP(ctx, u)
+	if err != nil {
+		return "", fmt.Errorf("could not connect to %v: %w", u.Host, err)
+	}
+	defer c.Close()
+
+	size := c.size(ftpPath(u))
+
+	destTmp := dest + ".download"
+	destTmpWriter, err := os.Create(destTmp)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(destTmp) // No-op once renamed
+	defer destTmpWriter.Close()
+
+	if options.Progress {
+		log.Println("fetching", redactURL(u), "to", dest)
+	}
+
+	counter := &progressWriter{destTmpWriter, newProgress(nil, size, options.OnProgress)}
+	if err := c.retrieve(ctx, ftpPath(u), counter); err != nil {
+		return "", fmt.Errorf("could not download %v: %w", redactURL(u), err)
+	}
+
+	if err := destTmpWriter.Close(); err != nil {
+		return "", err
+	}
+
+	if written := counter.progress.written; size >= 0 && written != size {
+		return "", &SizeMismatchError{size, written, redactURL(u)}
+	}
+
+	if err := os.Rename(destTmp, dest); err != nil {
+		return "", err
+	}
+
+	return dest, nil
+}
+
+// checkFTP checks that the file at the given FTP URL can be downloaded, without downloading it.
+func checkFTP(u *url.URL, options *Options) error {
+	ctx := options.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	c, err := dialFTP(ctx, u)
+	if err != nil {
+		return fmt.Errorf("could not connect to %v: %w", u.Host, err)
+	}
+	defer c.Close()
+
+	if c.size(ftpPath(u)) < 0 {
+		return fmt.Errorf("no such file: %v", redactURL(u))
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetch
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestDialFTPCancelled(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	// Greet, then never answer: login hangs until the context is cancelled.
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		conn.Write([]byte("220 ready\r\n"))
+		ioutil.ReadAll(conn)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := dialFTP(ctx, &url.URL{Scheme: "ftp", Host: listener.Addr().String(), User: url.UserPassword("user", "password")})
+		errs <- err
+	}()
+
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Error("expected login to fail once cancelled")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("login not interrupted by the context")
+	}
+}
@@ -16,7 +16,6 @@
 package fetch
 
 import (
-	"net"
 	"net/http"
 	"time"
 )
@@ -30,29 +29,40 @@ const ConnectionPhaseTimeout = 10 * time.Second
 // time needed to download the requested file.
 const RequestTimeout = 30 * time.Minute
 
-// Transport is an HTTP transport optimized to perform a sigle request to a single host, with short
-// timeouts for various connection phases.
+// IdleConnectionTimeout is how long an idle connection is kept around for reuse by later requests to
+// the same host.
+const IdleConnectionTimeout = 90 * time.Second
+
+// Transport is the HTTP transport shared by all requests, with short timeouts for various connection
+// phases. Connections are kept alive and reused across downloads, so that fetching many installers
+// from the same host doesn't pay for a TCP and TLS handshake each time.
 var Transport = &http.Transport{
-	DialContext: (&net.Dialer{
-		DualStack: true,
-		KeepAlive: 0,
-		Timeout:   ConnectionPhaseTimeout,
-	}).DialContext,
-	DisableKeepAlives:     true,
+	DialContext:           dialContext,
 	ExpectContinueTimeout: ConnectionPhaseTimeout,
-	IdleConnTimeout:       ConnectionPhaseTimeout,
-	MaxConnsPerHost:       1,
+	IdleConnTimeout:       IdleConnectionTimeout,
+	MaxIdleConns:          100,
+	MaxIdleConnsPerHost:   8,
 	Proxy:                 http.ProxyFromEnvironment,
 	ResponseHeaderTimeout: ConnectionPhaseTimeout,
 	TLSHandshakeTimeout:   ConnectionPhaseTimeout,
 }
 
 // NewClient creates a new HTTP client with a default request timeout (see also `RequestTimeout`)
-// that uses our `Transport`. Unlike Go stdlib's HTTP client, ours is to be closed and discarded
-// after one request.
+// that uses our shared `Transport`. Clients are cheap to create, since connections are pooled by
+// the transport, and can be customized per request (i.e. with their own cookie jar).
 func NewClient() *http.Client {
+	return newClient(Transport)
+}
+
+// newClient is like NewClient, but uses the given transport.
+func newClient(base *http.Transport) *http.Client {
+	var transport http.RoundTripper = base
+	if tracing {
+		transport = &tracingTransport{base}
+	}
+
 	return &http.Client{
 		Timeout:   RequestTimeout,
-		Transport: Transport,
+		Transport: transport,
 	}
 }
@@ -0,0 +1,110 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetch
+
+import (
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/ungerik/go-dry"
+)
+
+// driveLetter matches a Windows drive letter, i.e. "C:".
+var driveLetter = regexp.MustCompile(`^[A-Za-z]:$`)
+
+// localPath returns the path of the local file the given resource refers to, which can either be a
+// plain path (including UNC paths, i.e. `\\server\share\file.msi`) or a `file://` URL. Both
+// `file:///C:/dir/file.msi` and `file://server/share/file.msi` are understood.
+func localPath(resource string) (string, bool) {
+	if dry.FileExists(resource) {
+		return resource, true
+	}
+
+	parsedURL, err := url.Parse(resource)
+	if err != nil || parsedURL.Scheme != "file" {
+		return "", false
+	}
+
+	path := parsedURL.Path
+
+	switch {
+	case driveLetter.MatchString(parsedURL.Host):
+		// file://C:/dir/file.msi
+		path = parsedURL.Host + path
+	case parsedURL.Host != "" && parsedURL.Host != "localhost":
+		// file://server/share/file.msi
+		return `\\` + parsedURL.Host + filepath.FromSlash(path), true
+	case len(path) > 2 && path[0] == '/' && driveLetter.MatchString(path[1:3]):
+		// file:///C:/dir/file.msi
+		path = path[1:]
+	}
+
+	return filepath.FromSlash(path), true
+}
+
+// copyLocal copies a local file to the destination given in the options, the same way Fetch would
+// have downloaded it.
+func copyLocal(path string, options *Options) (string, error) {
+	dest := options.Destination
+	if dry.FileIsDir(dest) {
+		dest = filepath.Join(dest, filepath.Base(strings.Replace(path, `\`, "/", -1)))
+	}
+
+	// File already exists, return its path.
+	if dry.FileExists(dest) && !options.Overwrite {
+		return dest, nil
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	if options.Progress {
+		log.Println("copying", path, "to", dest)
+	}
+
+	destTmp := dest + ".download"
+
+	destTmpWriter, err := os.Create(destTmp)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := io.Copy(destTmpWriter, src); err != nil {
+		destTmpWriter.Close()
+		os.Remove(destTmp)
+		return "", err
+	}
+
+	if err := destTmpWriter.Close(); err != nil {
+		os.Remove(destTmp)
+		return "", err
+	}
+
+	if err := os.Rename(destTmp, dest); err != nil {
+		os.Remove(destTmp)
+		return "", err
+	}
+
+	return dest, nil
+}
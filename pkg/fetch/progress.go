@@ -0,0 +1,71 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetch
+
+import (
+	"io"
+	"sync/atomic"
+
+	"github.com/cheggaaa/pb/v3"
+)
+
+// progressDisabled is set by DisableProgress.
+var progressDisabled = false
+
+// DisableProgress never shows progress indicators from now on, i.e. when the output is meant to be
+// read by another program. Options.OnProgress is still called.
+func DisableProgress() {
+	progressDisabled = true
+}
+
+// progress reports the progress of a download to the progress indicator and to the OnProgress
+// callback, both optional.
+type progress struct {
+	bar      *pb.ProgressBar
+	callback func(written int64, total int64)
+	total    int64
+	written  int64 // Updated atomically
+}
+
+func newProgress(bar *pb.ProgressBar, total int64, callback func(written int64, total int64)) *progress {
+	return &progress{bar: bar, callback: callback, total: total}
+}
+
+// add records that n more bytes have been downloaded. It's safe for concurrent use.
+func (p *progress) add(n int) {
+	written := atomic.AddInt64(&p.written, int64(n))
+
+	if p.bar != nil {
+		p.bar.Add(n)
+	}
+
+	if p.callback != nil {
+		p.callback(written, p.total)
+	}
+}
+
+// progressWriter is an io.Writer that reports the progress of the bytes written through it.
+type progressWriter struct {
+	w        io.Writer
+	progress *progress
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.progress.add(n)
+
+	return n, err
+}
@@ -0,0 +1,117 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetch
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Direct is the proxy override that connects to servers directly, ignoring the proxy configured in
+// the environment.
+const Direct = "direct"
+
+// proxyTransports are the transports of the proxy overrides in use, one for each, so that
+// connections are still pooled.
+var proxyTransports = struct {
+	sync.Mutex
+	byProxy map[string]*http.Transport
+}{byProxy: make(map[string]*http.Transport)}
+
+// transportFor returns the transport to use with the given proxy override, either a proxy URL or
+// Direct: the shared Transport, honoring HTTP_PROXY and friends, if there is none. Hosts excluded
+// with NO_PROXY are always connected to directly.
+func transportFor(proxy string) (*http.Transport, error) {
+	if proxy == "" {
+		return Transport, nil
+	}
+
+	var proxyURL *url.URL
+	if proxy != Direct {
+		parsed, err := url.Parse(proxy)
+		if err != nil || parsed.Host == "" {
+			return nil, fmt.Errorf("invalid proxy %q, expected a URL or %q", proxy, Direct)
+		}
+		proxyURL = parsed
+	}
+
+	proxyTransports.Lock()
+	defer proxyTransports.Unlock()
+
+	if ret, ok := proxyTransports.byProxy[proxy]; ok {
+		return ret, nil
+	}
+
+	ret := Transport.Clone()
+	ret.Proxy = func(req *http.Request) (*url.URL, error) {
+		if proxyURL == nil || bypassProxy(req.URL) {
+			return nil, nil
+		}
+
+		return proxyURL, nil
+	}
+	proxyTransports.byProxy[proxy] = ret
+
+	return ret, nil
+}
+
+// bypassProxy returns whether requests to the given URL must not go through a proxy, according to
+// NO_PROXY (or no_proxy): a comma-separated list of host names, which also match their subdomains
+// (with or without a leading "."), IP addresses, CIDR ranges, optionally with a port, or "*".
+func bypassProxy(u *url.URL) bool {
+	noProxy := os.Getenv("NO_PROXY")
+	if noProxy == "" {
+		noProxy = os.Getenv("no_proxy")
+	}
+
+	host, port := strings.ToLower(u.Hostname()), u.Port()
+	ip := net.ParseIP(host)
+
+	for _, pattern := range strings.Split(noProxy, ",") {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == "" {
+			continue
+		} else if pattern == "*" {
+			return true
+		}
+
+		if _, network, err := net.ParseCIDR(pattern); err == nil {
+			if ip != nil && network.Contains(ip) {
+				return true
+			}
+			continue
+		}
+
+		if patternHost, patternPort, err := net.SplitHostPort(pattern); err == nil {
+			if patternPort != port {
+				continue
+			}
+			pattern = patternHost
+		}
+
+		pattern = strings.TrimPrefix(pattern, "*")
+		if host == strings.TrimPrefix(pattern, ".") || strings.HasSuffix(host, "."+strings.TrimPrefix(pattern, ".")) {
+			return true
+		}
+	}
+
+	return false
+}
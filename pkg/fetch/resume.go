@@ -0,0 +1,131 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetch
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// partialSuffix is appended to the path of interrupted downloads, kept to be resumed later. The
+// validator to resume them with is saved next to them, with validatorSuffix appended as well.
+const (
+	partialSuffix   = ".partial"
+	validatorSuffix = ".validator"
+)
+
+// resumeValidator returns the value to send in If-Range to safely resume the download of the file in
+// the given response: its ETag, unless weak, or its Last-Modified date. Returns an empty string if
+// the server doesn't support range requests or offers neither.
+func resumeValidator(resp *http.Response) string {
+	if resp.Header.Get("Accept-Ranges") != "bytes" {
+		return ""
+	}
+
+	if etag := resp.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+
+	return resp.Header.Get("Last-Modified")
+}
+
+// loadPartial returns the size of the interrupted download of dest and the validator to resume it
+// with, if there is one.
+func loadPartial(dest string) (int64, string, bool) {
+	info, err := os.Stat(dest + partialSuffix)
+	if err != nil || !info.Mode().IsRegular() || info.Size() == 0 {
+		return 0, "", false
+	}
+
+	validator, err := ioutil.ReadFile(dest + partialSuffix + validatorSuffix)
+	if err != nil || len(validator) == 0 {
+		return 0, "", false
+	}
+
+	return info.Size(), string(validator), true
+}
+
+// keepPartial keeps what was downloaded of dest to tmp so far, to resume it later with the given
+// validator. Without a validator, the download cannot be resumed safely and is discarded.
+func keepPartial(tmp string, dest string, validator string) {
+	if info, err := os.Stat(tmp); err != nil || info.Size() == 0 || validator == "" {
+		os.Remove(tmp)
+		return
+	}
+
+	if err := os.Rename(tmp, dest+partialSuffix); err != nil {
+		os.Remove(tmp)
+		return
+	}
+
+	if err := ioutil.WriteFile(dest+partialSuffix+validatorSuffix, []byte(validator), 0644); err != nil {
+		discardPartial(dest)
+	}
+}
+
+// discardPartial removes the interrupted download of dest, if any.
+func discardPartial(dest string) {
+	os.Remove(dest + partialSuffix)
+	os.Remove(dest + partialSuffix + validatorSuffix)
+}
+
+// contentRangeSize returns the size of the whole file that the given partial response is part of, as
+// announced in its Content-Range header, or -1 if unknown.
+func contentRangeSize(resp *http.Response) int64 {
+	var start, end, size int64
+	if _, err := fmt.Sscanf(resp.Header.Get("Content-Range"), "bytes %d-%d/%d", &start, &end, &size); err != nil {
+		return -1
+	}
+
+	return size
+}
+
+// resume requests the given resource from offset onwards, only if it's still the one identified by
+// validator (If-Range). Otherwise, the server answers with the whole, changed, resource.
+func resume(resource string, offset int64, validator string, options *Options) (*http.Response, error) {
+	ranged := *options
+	ranged.HTTP.Headers = map[string]string{}
+	for k, v := range options.HTTP.Headers {
+		ranged.HTTP.Headers[k] = v
+	}
+	ranged.HTTP.Headers["Range"] = fmt.Sprintf("bytes=%d-", offset)
+	ranged.HTTP.Headers["If-Range"] = validator
+
+	resp, err := get(resource, &ranged)
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp, nil
+	case http.StatusPartialContent:
+		// Make sure that the server continues from where we stopped.
+		var start int64
+		if _, err := fmt.Sscanf(resp.Header.Get("Content-Range"), "bytes %d-", &start); err != nil || start != offset {
+			resp.Body.Close()
+			return nil, fmt.Errorf("cannot resume download of %v: server sent Content-Range %q for Range bytes=%d-", resource, resp.Header.Get("Content-Range"), offset)
+		}
+
+		return resp, nil
+	default:
+		resp.Body.Close()
+		return nil, &HTTPStatusError{http.StatusPartialContent, resp.StatusCode, resource}
+	}
+}
@@ -0,0 +1,166 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetch
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/ungerik/go-dry"
+)
+
+const (
+	testContent   = "0123456789"
+	testValidator = `"v1"`
+	testOffset    = 5 // Bytes of testContent already downloaded
+)
+
+// resumeServer serves testContent at /setup.exe, resuming it from the requested offset with the
+// given Content-Range header. Without one, ranged requests fail with 416 Range Not Satisfiable.
+func resumeServer(t *testing.T, contentRange string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Accept-Ranges", "bytes")
+		w.Header().Set("ETag", testValidator)
+
+		if r.Header.Get("Range") == "" {
+			w.Header().Set("Content-Length", strconv.Itoa(len(testContent)))
+			w.Write([]byte(testContent))
+			return
+		}
+
+		if r.Header.Get("If-Range") != testValidator {
+			t.Errorf("expected If-Range %v, got %q", testValidator, r.Header.Get("If-Range"))
+		}
+
+		if contentRange == "" {
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+
+		w.Header().Set("Content-Range", contentRange)
+		w.Header().Set("Content-Length", strconv.Itoa(len(testContent)-testOffset))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write([]byte(testContent[testOffset:]))
+	}))
+}
+
+// interruptedDownload creates an interrupted download of testContent in a new temporary directory.
+// It returns the path of the file being downloaded and a function that removes the directory.
+func interruptedDownload(t *testing.T) (string, func()) {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "just-install-fetch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+
+	dest := filepath.Join(dir, "setup.exe")
+	if err := ioutil.WriteFile(dest+partialSuffix, []byte(testContent[:testOffset]), 0644); err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(dest+partialSuffix+validatorSuffix, []byte(testValidator), 0644); err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+
+	return dest, cleanup
+}
+
+func TestResume(t *testing.T) {
+	dest, cleanup := interruptedDownload(t)
+	defer cleanup()
+
+	server := resumeServer(t, "bytes 5-9/10")
+	defer server.Close()
+
+	path, err := Fetch(server.URL+"/setup.exe", &Options{Destination: dest})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if data, err := ioutil.ReadFile(path); err != nil || string(data) != testContent {
+		t.Errorf("expected %q, got %q (%v)", testContent, data, err)
+	}
+
+	if dry.FileExists(dest + partialSuffix) {
+		t.Error("partial download left behind")
+	}
+}
+
+func TestResumeTotalMismatch(t *testing.T) {
+	dest, cleanup := interruptedDownload(t)
+	defer cleanup()
+
+	server := resumeServer(t, "bytes 5-9/20")
+	defer server.Close()
+
+	_, err := Fetch(server.URL+"/setup.exe", &Options{Destination: dest})
+
+	var sizeErr *SizeMismatchError
+	if !errors.As(err, &sizeErr) {
+		t.Fatalf("expected a size mismatch, got %v", err)
+	}
+	if sizeErr.Expected != 20 || sizeErr.Received != int64(len(testContent)) {
+		t.Errorf("expected 20 bytes and %v received, got %v", len(testContent), sizeErr)
+	}
+
+	for _, path := range []string{dest, dest + partialSuffix, dest + partialSuffix + validatorSuffix} {
+		if dry.FileExists(path) {
+			t.Errorf("%v left behind", path)
+		}
+	}
+}
+
+func TestResumeFailureDownloadsAgain(t *testing.T) {
+	tests := []struct {
+		name         string
+		contentRange string
+	}{
+		{"range not satisfiable", ""},
+		{"wrong offset", "bytes 3-9/10"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dest, cleanup := interruptedDownload(t)
+			defer cleanup()
+
+			server := resumeServer(t, test.contentRange)
+			defer server.Close()
+
+			path, err := Fetch(server.URL+"/setup.exe", &Options{Destination: dest})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if data, err := ioutil.ReadFile(path); err != nil || string(data) != testContent {
+				t.Errorf("expected %q, got %q (%v)", testContent, data, err)
+			}
+
+			if dry.FileExists(dest + partialSuffix) {
+				t.Error("partial download left behind")
+			}
+		})
+	}
+}
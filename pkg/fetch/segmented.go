@@ -0,0 +1,136 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetch
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// DefaultSegmentThreshold is the minimum size of a file downloaded with multiple connections, when
+// not specified in Options.
+const DefaultSegmentThreshold = 32 * 1024 * 1024
+
+// useSegments returns whether the file in the given response should be downloaded with multiple
+// ranged requests.
+func useSegments(resp *http.Response, options *Options) bool {
+	threshold := options.SegmentThreshold
+	if threshold == 0 {
+		threshold = DefaultSegmentThreshold
+	}
+
+	return options.Segments > 1 &&
+		resp.ContentLength > 0 &&
+		resp.ContentLength >= threshold &&
+		resp.Header.Get("Accept-Ranges") == "bytes"
+}
+
+// fetchSegments downloads the given resource of the given size to dest, split in segments that are
+// downloaded in parallel. Returns the number of bytes written, the first error encountered aborts
+// the whole download.
+func fetchSegments(resource string, size int64, dest *os.File, progress *progress, options *Options) (int64, error) {
+	segments := int64(options.Segments)
+	if segments > size {
+		segments = size
+	}
+
+	written := make([]int64, segments)
+	errs := make([]error, segments)
+	var wg sync.WaitGroup
+
+	for i := int64(0); i < segments; i++ {
+		wg.Add(1)
+
+		go func(i int64) {
+			defer wg.Done()
+
+			start := i * size / segments
+			end := (i+1)*size/segments - 1
+
+			written[i], errs[i] = fetchSegment(resource, start, end, dest, progress, options)
+		}(i)
+	}
+
+	wg.Wait()
+
+	var ret int64
+	for i := range errs {
+		if errs[i] != nil {
+			return 0, errs[i]
+		}
+
+		ret += written[i]
+	}
+
+	return ret, nil
+}
+
+// fetchSegment downloads the given inclusive range of bytes of the resource, writing it at the same
+// offset in dest.
+func fetchSegment(resource string, start int64, end int64, dest *os.File, progress *progress, options *Options) (int64, error) {
+	segmentOptions := *options
+	segmentOptions.HTTP.CheckRedirect = nil
+	segmentOptions.HTTP.Headers = map[string]string{"Range": fmt.Sprintf("bytes=%d-%d", start, end)}
+	for k, v := range options.HTTP.Headers {
+		segmentOptions.HTTP.Headers[k] = v
+	}
+
+	resp, err := get(resource, &segmentOptions)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		return 0, &HTTPStatusError{http.StatusPartialContent, resp.StatusCode, resource}
+	}
+
+	expected := end - start + 1
+	if resp.ContentLength >= 0 && resp.ContentLength != expected {
+		return 0, &SizeMismatchError{expected, resp.ContentLength, resource}
+	}
+
+	buf := make([]byte, 32*1024)
+	offset := start
+
+	for offset <= end {
+		n, err := resp.Body.Read(buf)
+		if int64(n) > end-offset+1 {
+			return offset - start, &SizeMismatchError{expected, offset - start + int64(n), resource}
+		}
+
+		if n > 0 {
+			if _, err := dest.WriteAt(buf[:n], offset); err != nil {
+				return offset - start, err
+			}
+
+			offset += int64(n)
+			progress.add(n)
+		}
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return offset - start, err
+		}
+	}
+
+	return offset - start, nil
+}
@@ -0,0 +1,98 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package fetch
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/just-install/just-install/pkg/redact"
+)
+
+// redactedHeaders are headers whose values are never logged.
+var redactedHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization", "Set-Cookie"}
+
+// tracing is set by EnableTracing.
+var tracing = false
+
+// EnableTracing logs all HTTP requests and responses made from now on, with sensitive headers
+// redacted.
+func EnableTracing() {
+	tracing = true
+}
+
+// tracingTransport is an http.RoundTripper that logs requests and responses.
+type tracingTransport struct {
+	next http.RoundTripper
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	log.Println("http: >", req.Method, redactURL(req.URL))
+	logHeaders("http: >", req.Header)
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	elapsed := time.Since(start).Round(time.Millisecond)
+
+	if err != nil {
+		log.Println("http: <", req.Method, redactURL(req.URL), "failed after", elapsed, "with", err)
+		return resp, err
+	}
+
+	log.Println("http: <", resp.Status, "in", elapsed)
+	logHeaders("http: <", resp.Header)
+
+	return resp, nil
+}
+
+// logHeaders logs the given headers, sorted by name, redacting sensitive ones.
+func logHeaders(prefix string, headers http.Header) {
+	var names []string
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		value := strings.Join(headers[name], ", ")
+
+		for _, redacted := range redactedHeaders {
+			if strings.EqualFold(name, redacted) {
+				value = redact.Placeholder
+				break
+			}
+		}
+
+		log.Printf("%v %v: %v", prefix, name, redact.String(value))
+	}
+}
+
+// redactURL returns the given URL as a string, without the password (if any) or other sensitive
+// values.
+func redactURL(u *url.URL) string {
+	if _, hasPassword := u.User.Password(); !hasPassword {
+		return redact.String(u.String())
+	}
+
+	redacted := *u
+	redacted.User = url.UserPassword(u.User.Username(), "redacted")
+
+	return redact.String(redacted.String())
+}
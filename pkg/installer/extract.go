@@ -17,13 +17,16 @@ package installer
 
 import (
 	"archive/zip"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 // ExtractZIP extracts the given ZIP archive to the given destination directory. If the destination
-// directory does not exist, it is created.
+// directory does not exist, it is created. Archives with entries outside of the destination
+// directory are rejected.
 func ExtractZIP(path string, dest string) error {
 	if err := os.MkdirAll(dest, 0700); err != nil {
 		return err
@@ -37,6 +40,9 @@ func ExtractZIP(path string, dest string) error {
 
 	for _, zipFile := range zipReader.File {
 		destinationPath := filepath.Join(dest, zipFile.Name)
+		if !insideDir(dest, destinationPath) {
+			return fmt.Errorf("%v: entry %v is outside of the archive", path, zipFile.Name)
+		}
 
 		if zipFile.FileInfo().IsDir() {
 			if err := os.MkdirAll(destinationPath, zipFile.Mode()); err != nil {
@@ -71,3 +77,9 @@ func ExtractZIP(path string, dest string) error {
 
 	return nil
 }
+
+// insideDir returns whether the given path is within the given directory.
+func insideDir(dir string, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
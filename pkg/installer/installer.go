@@ -30,7 +30,7 @@ type InstallerType string
 // IsValid returns whether the given installer type is known.
 func (it InstallerType) IsValid() bool {
 	switch it {
-	case AdvancedInstaller, AsIs, InnoSetup, JetBrainsNSIS, MSI, NSIS, Squirrel:
+	case AdvancedInstaller, AsIs, InnoSetup, JetBrainsNSIS, MSI, MSIX, MSU, NSIS, Squirrel:
 		return true
 	default:
 		return false
@@ -43,6 +43,8 @@ const (
 	InnoSetup         InstallerType = "innosetup"
 	JetBrainsNSIS     InstallerType = "jetbrains-nsis"
 	MSI               InstallerType = "msi"
+	MSIX              InstallerType = "msix"
+	MSU               InstallerType = "msu"
 	NSIS              InstallerType = "nsis"
 	Squirrel          InstallerType = "squirrel"
 )
@@ -69,6 +71,10 @@ func Command(path string, installerType InstallerType) ([]string, error) {
 		return []string{path, "/S", "/CONFIG=" + config}, nil
 	case MSI:
 		return []string{"msiexec.exe", "/q", "/i", path, "ALLUSERS=1", "REBOOT=ReallySuppress"}, nil
+	case MSIX:
+		return MSIXCommand(path, nil), nil
+	case MSU:
+		return []string{"wusa.exe", path, "/quiet", "/norestart"}, nil
 	case NSIS:
 		return []string{path, "/S"}, nil
 	case Squirrel:
@@ -77,3 +83,20 @@ func Command(path string, installerType InstallerType) ([]string, error) {
 		return nil, errors.New("unknown installer type")
 	}
 }
+
+// ErrNoUninstallCommand is returned by UninstallCommand for installer types that cannot be
+// uninstalled from their installer alone.
+var ErrNoUninstallCommand = errors.New("installer type has no known uninstall command")
+
+// UninstallCommand returns the command needed to uninstall what the given installer of the given
+// type installed. MSIX packages are uninstalled by identity instead, see MSIXUninstallCommand.
+func UninstallCommand(path string, installerType InstallerType) ([]string, error) {
+	switch installerType {
+	case MSI:
+		return []string{"msiexec.exe", "/q", "/x", path, "REBOOT=ReallySuppress"}, nil
+	case MSU:
+		return []string{"wusa.exe", "/uninstall", path, "/quiet", "/norestart"}, nil
+	default:
+		return nil, ErrNoUninstallCommand
+	}
+}
@@ -0,0 +1,99 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package installer
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// msixManifests are the manifests that contain the identity of MSIX packages and bundles.
+var msixManifests = []string{"AppxManifest.xml", "AppxMetadata/AppxBundleManifest.xml"}
+
+// MSIXCommand returns the command needed to install the given MSIX (or APPX) package, along with
+// the given dependency packages.
+func MSIXCommand(path string, dependencies []string) []string {
+	script := "Add-AppxPackage -ForceApplicationShutdown -Path " + powerShellQuote(path)
+	if len(dependencies) > 0 {
+		var quoted []string
+		for _, d := range dependencies {
+			quoted = append(quoted, powerShellQuote(d))
+		}
+
+		script += " -DependencyPath " + strings.Join(quoted, ",")
+	}
+
+	return powerShellCommand(script)
+}
+
+// MSIXUninstallCommand returns the command needed to remove the MSIX (or APPX) package with the
+// given identity name, as returned by MSIXName, for the current user.
+func MSIXUninstallCommand(name string) []string {
+	return powerShellCommand("Get-AppxPackage -Name " + powerShellQuote(name) + " | Remove-AppxPackage")
+}
+
+// MSIXName returns the identity name of the given MSIX (or APPX) package or bundle.
+func MSIXName(path string) (string, error) {
+	zipReader, err := zip.OpenReader(path)
+	if err != nil {
+		return "", err
+	}
+	defer zipReader.Close()
+
+	for _, zipFile := range zipReader.File {
+		for _, manifest := range msixManifests {
+			if zipFile.Name != manifest {
+				continue
+			}
+
+			r, err := zipFile.Open()
+			if err != nil {
+				return "", err
+			}
+			defer r.Close()
+
+			var parsed struct {
+				Identity struct {
+					Name string `xml:"Name,attr"`
+				}
+			}
+			if err := xml.NewDecoder(r).Decode(&parsed); err != nil {
+				return "", fmt.Errorf("cannot read %v: %w", manifest, err)
+			}
+
+			if parsed.Identity.Name == "" {
+				return "", fmt.Errorf("%v has no package identity", manifest)
+			}
+
+			return parsed.Identity.Name, nil
+		}
+	}
+
+	return "", fmt.Errorf("%v is not an MSIX package: no manifest found", path)
+}
+
+// powerShellCommand returns the command line that runs the given PowerShell script, exiting with a
+// non-zero code if any of its commands fails.
+func powerShellCommand(script string) []string {
+	return []string{"powershell.exe", "-NoProfile", "-NonInteractive", "-Command", "$ErrorActionPreference = 'Stop'; " + script}
+}
+
+// powerShellQuote quotes the given string for use as a literal in PowerShell scripts.
+func powerShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,99 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/just-install/just-install/pkg/platform"
+)
+
+// associations returns the file associations declared by the entry for the given architecture,
+// with placeholders expanded.
+func (e *RegistryEntry) associations(arch string) ([]platform.FileAssociation, error) {
+	declared, ok := e.Installer.options(arch)["associations"].([]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	var ret []platform.FileAssociation
+	for i, v := range declared {
+		association, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("association %v is not an object", i)
+		}
+
+		get := func(key string) string {
+			value, _ := association[key].(string)
+			return e.ExpandString(value)
+		}
+
+		ret = append(ret, platform.FileAssociation{
+			Extension:   get("extension"),
+			ProgID:      get("progId"),
+			Description: get("description"),
+			Command:     get("command"),
+			Icon:        get("icon"),
+		})
+	}
+
+	return ret, nil
+}
+
+// RegisterAssociations registers the file associations declared by the entry, for all users if we
+// are elevated or for the current user otherwise. Extensions already associated with another
+// program, or for which the user picked a default program, are left alone with a warning unless
+// force is set.
+func (e *RegistryEntry) RegisterAssociations(arch string, force bool) {
+	associations, err := e.associations(arch)
+	if err != nil {
+		log.Println("WARNING: not registering file associations:", err)
+		return
+	}
+
+	systemWide := platform.IsElevated()
+
+	for _, a := range associations {
+		log.Println("associating", a.Extension, "with", a.ProgID)
+
+		if err := platform.RegisterFileAssociation(a, force, systemWide); errors.Is(err, platform.ErrAssociationInUse) {
+			log.Printf("WARNING: not associating %v with %v: %v (see --force-associations)", a.Extension, a.ProgID, err)
+		} else if err != nil {
+			log.Printf("WARNING: could not associate %v with %v: %v", a.Extension, a.ProgID, err)
+		}
+	}
+}
+
+// RemoveAssociations removes the file associations declared by the entry.
+func (e *RegistryEntry) RemoveAssociations(arch string) {
+	associations, err := e.associations(arch)
+	if err != nil {
+		log.Println("WARNING: not removing file associations:", err)
+		return
+	}
+
+	systemWide := platform.IsElevated()
+
+	for _, a := range associations {
+		log.Println("removing association of", a.Extension, "with", a.ProgID)
+
+		if err := platform.UnregisterFileAssociation(a, systemWide); err != nil {
+			log.Printf("WARNING: could not remove association of %v with %v: %v", a.Extension, a.ProgID, err)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ungerik/go-dry"
+
+	"github.com/just-install/just-install/pkg/cmd"
+	"github.com/just-install/just-install/pkg/platform"
+)
+
+// ErrSkipped is returned (wrapped) when a package is deliberately not installed.
+var ErrSkipped = errors.New("installation skipped")
+
+// commandCheckTimeout is how long a "command" check may run before it is considered failed.
+const commandCheckTimeout = time.Minute
+
+// dotNetFrameworkReleases maps .NET Framework versions to the minimum "Release" registry value that
+// identifies them, see https://docs.microsoft.com/en-us/dotnet/framework/migration-guide/how-to-determine-which-versions-are-installed
+var dotNetFrameworkReleases = map[string]uint32{
+	"4.5":   378389,
+	"4.5.1": 378675,
+	"4.5.2": 379893,
+	"4.6":   393295,
+	"4.6.1": 394254,
+	"4.6.2": 394802,
+	"4.7":   460798,
+	"4.7.1": 461308,
+	"4.7.2": 461808,
+	"4.8":   528040,
+}
+
+// check is a condition that must be met on the host, i.e. before installing a package.
+type check struct {
+	Type      string // One of "command", "dotnet", "file" or "registry"
+	Value     string
+	Arguments []string // Optional, passed to the program of "command" checks
+	Policy    string   // Either "fail" (the default) or "skip", "warn" instead of "skip" after installing
+}
+
+// CheckResult is the outcome of a post-install check.
+type CheckResult struct {
+	Check  string `json:"check"` // Type and value, i.e. "file C:\\Program Files\\Foo\\foo.exe"
+	Passed bool   `json:"passed"`
+	Error  string `json:"error,omitempty"` // Why the check did not pass
+}
+
+// evaluate returns nil if the check is met, otherwise an error describing why it wasn't.
+func (c *check) evaluate() error {
+	return c.evaluateIn("")
+}
+
+// evaluateIn is like evaluate, for a package installed in the given prefix: the paths of "command"
+// and "file" checks are moved there.
+func (c *check) evaluateIn(prefix string) error {
+	value := c.value(prefix)
+
+	switch c.Type {
+	case "command":
+		args := []string{value}
+		for _, arg := range c.Arguments {
+			args = append(args, expandString(os.ExpandEnv(arg), nil))
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), commandCheckTimeout)
+		defer cancel()
+
+		if err := cmd.RunOutput(ctx, ioutil.Discard, args...); err != nil {
+			return fmt.Errorf("%v failed: %w", strings.Join(args, " "), err)
+		}
+	case "dotnet":
+		required, ok := dotNetFrameworkReleases[value]
+		if !ok {
+			return fmt.Errorf("unknown .NET Framework version: %v", value)
+		}
+
+		release, err := platform.DotNetFrameworkRelease()
+		if err != nil {
+			return fmt.Errorf("cannot determine .NET Framework version: %w", err)
+		}
+
+		if release < required {
+			return fmt.Errorf(".NET Framework %v or later is not installed", value)
+		}
+	case "file":
+		if !dry.FileExists(value) {
+			return fmt.Errorf("%v does not exist", value)
+		}
+	case "registry":
+		exists, err := platform.RegistryKeyExists(value)
+		if err != nil {
+			return fmt.Errorf("cannot read registry key %v: %w", value, err)
+		}
+
+		if !exists {
+			return fmt.Errorf("registry key %v does not exist", value)
+		}
+	default:
+		return fmt.Errorf("unknown check type: %v", c.Type)
+	}
+
+	return nil
+}
+
+// checkPreInstall evaluates the pre-install checks of the entry. It returns an error wrapping
+// ErrSkipped if an unmet check asks to skip installation, or a plain error if it asks to fail.
+func (e *RegistryEntry) checkPreInstall() error {
+	for _, c := range e.PreInstallChecks {
+		err := c.evaluate()
+		if err == nil {
+			continue
+		}
+
+		switch c.Policy {
+		case "", "fail":
+			return fmt.Errorf("pre-install check failed: %w", err)
+		case "skip":
+			return fmt.Errorf("%w: pre-install check not met: %v", ErrSkipped, err)
+		default:
+			return fmt.Errorf("unknown pre-install check policy: %v", c.Policy)
+		}
+	}
+
+	return nil
+}
+
+// value returns the expanded value of the check, for a package installed in the given prefix.
+func (c *check) value(prefix string) string {
+	value := expandString(os.ExpandEnv(c.Value), nil)
+	if c.Type == "command" || c.Type == "file" {
+		value = prefixed(value, prefix)
+	}
+
+	return value
+}
+
+// verifyInstall evaluates the post-install checks of the entry, installed in the given prefix if
+// any, returning their results. It returns an error if an unmet check asks to fail, after logging a
+// warning for those that ask to warn.
+func (e *RegistryEntry) verifyInstall(prefix string) ([]CheckResult, error) {
+	var ret []CheckResult
+	var failed error
+
+	for _, c := range e.PostInstallChecks {
+		result := CheckResult{Check: c.Type + " " + c.value(prefix), Passed: true}
+
+		if err := c.evaluateIn(prefix); err != nil {
+			result.Passed, result.Error = false, err.Error()
+
+			switch c.Policy {
+			case "", "fail":
+				if failed == nil {
+					failed = fmt.Errorf("post-install check failed: %w", err)
+				}
+			case "warn":
+				log.Println("WARNING: post-install check failed:", err)
+			default:
+				return ret, fmt.Errorf("unknown post-install check policy: %v", c.Policy)
+			}
+		}
+
+		ret = append(ret, result)
+	}
+
+	return ret, failed
+}
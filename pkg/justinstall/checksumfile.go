@@ -0,0 +1,145 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ungerik/go-dry"
+
+	"github.com/just-install/just-install/pkg/checksum"
+	"github.com/just-install/just-install/pkg/fetch"
+	"github.com/just-install/just-install/pkg/paths"
+)
+
+// checksumFileTTL is how long a downloaded checksum file is used before downloading it again.
+const checksumFileTTL = 10 * time.Minute
+
+// checksumFileEntry references a checksum file published by upstream, i.e. "SHA256SUMS".
+type checksumFileEntry struct {
+	URL       string
+	Algorithm string // Optional, defaults to SHA-256
+	X86       string // Optional, name of the x86 installer in the file, defaults to its file name
+	X86_64    string // Optional, name of the x86_64 installer in the file, defaults to its file name
+}
+
+// algorithm returns the algorithm of the digests in the checksum file.
+func (c *checksumFileEntry) algorithm() string {
+	if c.Algorithm == "" {
+		return checksum.SHA256
+	}
+
+	return strings.ToLower(c.Algorithm)
+}
+
+// name returns the name of the given installer in the checksum file.
+func (c *checksumFileEntry) name(source installerSource, e *RegistryEntry) string {
+	name := c.X86
+	if source.Arch == "x86_64" {
+		name = c.X86_64
+	}
+
+	if name != "" {
+		return e.ExpandString(name)
+	}
+
+	if parsedURL, err := url.Parse(source.URL); err == nil && parsedURL.Path != "" {
+		return filepath.Base(parsedURL.Path)
+	}
+
+	return filepath.Base(source.URL)
+}
+
+// addPublishedHash adds to the digests of the given installer the one from the checksum file
+// referenced by the entry, if any. Digests in the registry take precedence, if available the
+// checksum file isn't even downloaded. With cacheOnly, the checksum file is never downloaded, a
+// cached copy is used regardless of its age.
+func (e *RegistryEntry) addPublishedHash(ctx context.Context, source *installerSource, cacheOnly bool) error {
+	if e.Installer.ChecksumFile == nil || checksum.Strongest(source.Hashes) != "" {
+		return nil
+	}
+
+	algorithm, digest, err := e.publishedHash(ctx, *source, cacheOnly)
+	if err != nil {
+		return err
+	}
+
+	source.Hashes[algorithm] = digest
+
+	return nil
+}
+
+// publishedHash returns the algorithm and digest of the given installer according to the checksum
+// file referenced by the entry, downloading it if needed (and not cacheOnly).
+func (e *RegistryEntry) publishedHash(ctx context.Context, source installerSource, cacheOnly bool) (string, string, error) {
+	c := e.Installer.ChecksumFile
+
+	algorithm := c.algorithm()
+	if !checksum.Supported(algorithm) {
+		return "", "", fmt.Errorf("unsupported checksum algorithm: %v", algorithm)
+	}
+
+	path, err := fetchChecksumFile(ctx, e.expandURL(c.URL), e.Installer.Proxy, cacheOnly)
+	if err != nil {
+		return "", "", fmt.Errorf("could not download checksum file: %w", err)
+	}
+
+	data, err := dry.FileGetString(path)
+	if err != nil {
+		return "", "", err
+	}
+
+	name := c.name(source, e)
+	digest, ok := checksum.ParseSums(data)[name]
+	if fields := strings.Fields(data); !ok && len(fields) == 1 {
+		// Checksum files published for a single installer may hold just its digest
+		digest, ok = strings.ToLower(fields[0]), true
+	}
+	if !ok {
+		return "", "", fmt.Errorf("the checksum file %v has no checksum for %v", c.URL, name)
+	}
+
+	return algorithm, digest, nil
+}
+
+// fetchChecksumFile downloads the checksum file at the given URL to the cache, unless it has been
+// downloaded recently (or at all, with cacheOnly), and returns its path. Unlike installers, checksum
+// files are expected to change under the same URL. The proxy overrides the one from the environment,
+// if not empty.
+func fetchChecksumFile(ctx context.Context, rawurl string, proxy string, cacheOnly bool) (string, error) {
+	digest := sha256.Sum256([]byte(rawurl))
+
+	dest, err := paths.CacheFileCreate("checksums-" + hex.EncodeToString(digest[:8]) + ".txt")
+	if err != nil {
+		return "", err
+	}
+
+	if info, err := os.Stat(dest); err == nil && (cacheOnly || time.Since(info.ModTime()) < checksumFileTTL) {
+		return dest, nil
+	} else if cacheOnly {
+		return "", fmt.Errorf("%v is not cached", rawurl)
+	}
+
+	return fetch.Fetch(rawurl, &fetch.Options{Context: ctx, CopyLocal: true, Destination: dest, HTTP: fetch.HTTPOptions{Proxy: proxy}, Overwrite: true})
+}
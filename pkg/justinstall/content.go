@@ -0,0 +1,93 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/just-install/just-install/pkg/installer"
+)
+
+// Signatures that installers start with, by format.
+var (
+	signatureCAB = []byte("MSCF")                                         // Windows Update standalone packages
+	signatureCFB = []byte{0xd0, 0xcf, 0x11, 0xe0, 0xa1, 0xb1, 0x1a, 0xe1} // Compound File Binary, i.e. Windows Installer packages
+	signatureEXE = []byte("MZ")                                           // Executables
+	signatureZIP = []byte("PK\x03\x04")                                   // ZIP archives, including MSIX and APPX packages
+)
+
+// contentFormat is the format that the installer of an entry must be in, as far as it can be told
+// from its kind.
+type contentFormat struct {
+	Name       string
+	Signatures [][]byte
+}
+
+// installerFormat returns the format the installer of the entry for the given architecture must be
+// in. Kinds that can run or copy anything (i.e. "as-is", "custom" and "copy") have no format.
+func (e *RegistryEntry) installerFormat(arch string) (contentFormat, bool) {
+	if _, ok, _ := e.containedInstaller(arch); ok || e.Installer.Kind == "zip" {
+		return contentFormat{"ZIP archive", [][]byte{signatureZIP}}, true
+	}
+
+	switch installer.InstallerType(e.Installer.Kind) {
+	case installer.AdvancedInstaller, installer.InnoSetup, installer.JetBrainsNSIS, installer.NSIS, installer.Squirrel:
+		return contentFormat{"executable", [][]byte{signatureEXE}}, true
+	case installer.MSI:
+		return contentFormat{"Windows Installer package", [][]byte{signatureCFB}}, true
+	case installer.MSIX:
+		return contentFormat{"MSIX or APPX package", [][]byte{signatureZIP}}, true
+	case installer.MSU:
+		return contentFormat{"Windows Update package", [][]byte{signatureCAB}}, true
+	default:
+		return contentFormat{}, false
+	}
+}
+
+// checkContent returns an error if the file at the given path, downloaded from the given URL, is
+// obviously not in the given format: typically, an HTML error or login page served instead of the
+// installer with a 200 status code.
+func checkContent(path string, rawurl string, format contentFormat) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	head := make([]byte, 512) // As much as http.DetectContentType looks at
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return err
+	}
+	head = head[:n]
+
+	for _, signature := range format.Signatures {
+		if bytes.HasPrefix(head, signature) {
+			return nil
+		}
+	}
+
+	if detected := http.DetectContentType(head); strings.HasPrefix(detected, "text/html") {
+		return fmt.Errorf("%v served an HTML page instead of a %v (i.e. an error or login page)", rawurl, format.Name)
+	}
+
+	return fmt.Errorf("%v did not serve a %v, but something else (see --no-content-check)", rawurl, format.Name)
+}
@@ -0,0 +1,60 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/just-install/just-install/pkg/platform"
+)
+
+// detectEntry describes how to find an installation of the package made without just-install.
+type detectEntry struct {
+	DisplayName string // Pattern matching the name of the program in "Programs and Features"
+}
+
+// detectInstalled returns the version of the package installed outside of just-install, if it can
+// be found.
+func (e *RegistryEntry) detectInstalled() (platform.InstalledProgram, bool) {
+	if e.Detect == nil || e.Detect.DisplayName == "" {
+		return platform.InstalledProgram{}, false
+	}
+
+	programs, err := platform.InstalledPrograms()
+	if err != nil {
+		log.Println("cannot list installed programs, assuming the package is not installed:", err)
+		return platform.InstalledProgram{}, false
+	}
+
+	return platform.FindInstalledProgram(programs, e.Detect.DisplayName)
+}
+
+// checkInstalled returns ErrSkipped if the package is already installed, outside of just-install,
+// in the same or a newer version than the one in the registry. Versions that cannot be compared
+// (i.e. "latest") never skip installation.
+func (e *RegistryEntry) checkInstalled() error {
+	program, ok := e.detectInstalled()
+	if !ok {
+		return nil
+	}
+
+	if cmp, ok := platform.CompareVersions(program.DisplayVersion, e.Version); ok && cmp >= 0 {
+		return fmt.Errorf("%w: %v %v is already installed (see --force)", ErrSkipped, program.DisplayName, program.DisplayVersion)
+	}
+
+	return nil
+}
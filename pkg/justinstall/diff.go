@@ -0,0 +1,79 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+)
+
+// Kinds of changes to a package between two registries.
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeVersion = "version" // The version changed, along with anything else
+	ChangeEntry   = "entry"   // Something other than the version changed
+)
+
+// Change is a difference in a package between two registries.
+type Change struct {
+	Package    string `json:"package"`
+	Kind       string `json:"kind"`
+	OldVersion string `json:"oldVersion,omitempty"`
+	NewVersion string `json:"newVersion,omitempty"`
+}
+
+// DiffRegistries returns the changes to packages from the old registry to the new one, sorted by
+// package name.
+func DiffRegistries(old *Registry, new *Registry) []Change {
+	var ret []Change
+
+	names := old.SortedPackageNames()
+	for _, name := range new.SortedPackageNames() {
+		if _, ok := old.Packages[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		oldEntry, inOld := old.Packages[name]
+		newEntry, inNew := new.Packages[name]
+
+		switch {
+		case !inOld:
+			ret = append(ret, Change{name, ChangeAdded, "", newEntry.Version})
+		case !inNew:
+			ret = append(ret, Change{name, ChangeRemoved, oldEntry.Version, ""})
+		case oldEntry.Version != newEntry.Version:
+			ret = append(ret, Change{name, ChangeVersion, oldEntry.Version, newEntry.Version})
+		case !sameEntry(oldEntry, newEntry):
+			ret = append(ret, Change{name, ChangeEntry, oldEntry.Version, newEntry.Version})
+		}
+	}
+
+	return ret
+}
+
+// sameEntry returns whether the given entries are the same, as far as the registry file is
+// concerned (i.e. regardless of where the registry files are).
+func sameEntry(a RegistryEntry, b RegistryEntry) bool {
+	aData, aErr := json.Marshal(a)
+	bData, bErr := json.Marshal(b)
+
+	return aErr == nil && bErr == nil && bytes.Equal(aData, bData)
+}
@@ -0,0 +1,119 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"log"
+	"os"
+	"sort"
+
+	"github.com/just-install/just-install/pkg/platform"
+)
+
+// environment returns the environment variables declared by the entry for the given architecture,
+// with placeholders expanded. Besides the usual ones, `{{.destination}}` expands to the destination
+// of copy and zip installers.
+func (e *RegistryEntry) environment(arch string) map[string]string {
+	declared, ok := e.Installer.options(arch)["environment"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	context := map[string]string{"version": e.Version}
+	if destination, ok := e.Installer.options(arch)["destination"].(string); ok {
+		context["destination"] = expandString(os.ExpandEnv(destination), nil)
+	}
+
+	ret := make(map[string]string)
+	for name, value := range declared {
+		value, _ := value.(string)
+		ret[name] = expandString(value, context)
+	}
+
+	return ret
+}
+
+// SetEnvironment persistently sets the environment variables declared by the entry, for all users
+// if we are elevated or for the current user otherwise. Variables that are already set to another
+// value are left alone with a warning, unless force is set.
+func (e *RegistryEntry) SetEnvironment(arch string, force bool) {
+	environment := e.environment(arch)
+	if len(environment) == 0 {
+		return
+	}
+
+	systemWide := platform.IsElevated()
+
+	for _, name := range sortedNames(environment) {
+		value := environment[name]
+
+		if current, ok, err := platform.PersistentEnv(name, systemWide); err != nil {
+			log.Printf("WARNING: could not read environment variable %v: %v", name, err)
+			continue
+		} else if ok && current != value && !force {
+			log.Printf("WARNING: not setting environment variable %v, already set to %v (see --force)", name, current)
+			continue
+		}
+
+		log.Printf("setting environment variable %v=%v", name, value)
+		if err := platform.SetPersistentEnv(name, value, systemWide); err != nil {
+			log.Printf("WARNING: could not set environment variable %v: %v", name, err)
+		}
+	}
+
+	platform.BroadcastEnvironmentChange()
+}
+
+// RemoveEnvironment removes the environment variables declared by the entry. Variables that have
+// been set to another value in the meantime are left alone.
+func (e *RegistryEntry) RemoveEnvironment(arch string) {
+	environment := e.environment(arch)
+	if len(environment) == 0 {
+		return
+	}
+
+	systemWide := platform.IsElevated()
+
+	for _, name := range sortedNames(environment) {
+		if current, ok, err := platform.PersistentEnv(name, systemWide); err != nil {
+			log.Printf("WARNING: could not read environment variable %v: %v", name, err)
+			continue
+		} else if !ok {
+			continue
+		} else if current != environment[name] {
+			log.Printf("WARNING: not removing environment variable %v, it has been changed to %v", name, current)
+			continue
+		}
+
+		log.Println("removing environment variable", name)
+		if err := platform.UnsetPersistentEnv(name, systemWide); err != nil {
+			log.Printf("WARNING: could not remove environment variable %v: %v", name, err)
+		}
+	}
+
+	platform.BroadcastEnvironmentChange()
+}
+
+func sortedNames(m map[string]string) []string {
+	var ret []string
+	for k := range m {
+		ret = append(ret, k)
+	}
+
+	sort.Strings(ret)
+
+	return ret
+}
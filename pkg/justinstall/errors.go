@@ -0,0 +1,87 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/just-install/just-install/pkg/checksum"
+	"github.com/just-install/just-install/pkg/cmd"
+)
+
+// Errors returned by the functions of this package, to be checked with errors.Is. More details
+// are available with errors.As on the types below and on checksum.MismatchError.
+var (
+	ErrUnknownPackage   = errors.New("unknown package")
+	ErrUnsupportedArch  = errors.New("unsupported architecture")
+	ErrChecksumMismatch = checksum.ErrMismatch
+	ErrDownloadFailed   = errors.New("download failed")
+	ErrInstallerExit    = errors.New("installer failed")
+	ErrUnknownProfile   = errors.New("unknown installer profile")
+)
+
+// DownloadError is returned when the installer cannot be downloaded. It matches ErrDownloadFailed.
+type DownloadError struct {
+	URL string
+	Err error
+}
+
+func (d *DownloadError) Error() string {
+	return fmt.Sprintf("could not download installer: %v", d.Err)
+}
+
+func (d *DownloadError) Unwrap() error {
+	return d.Err
+}
+
+func (d *DownloadError) Is(target error) bool {
+	return target == ErrDownloadFailed
+}
+
+// InstallerExitError is returned when an installer (or uninstaller) exits with a code that signals
+// a failure. It matches ErrInstallerExit.
+type InstallerExitError struct {
+	Code int
+	Err  error
+}
+
+func (i *InstallerExitError) Error() string {
+	return fmt.Sprintf("installer exited with code %v: %v", i.Code, i.Err)
+}
+
+func (i *InstallerExitError) Unwrap() error {
+	return i.Err
+}
+
+func (i *InstallerExitError) Is(target error) bool {
+	return target == ErrInstallerExit
+}
+
+// runInstaller runs the given installer command line, returning an InstallerExitError if it fails.
+// Its output is written to out, unless it's nil.
+func runInstaller(ctx context.Context, out io.Writer, args []string) error {
+	err := cmd.RunOutput(ctx, out, args...)
+
+	var exitErr *cmd.ExitError
+	if errors.As(err, &exitErr) {
+		return &InstallerExitError{exitErr.Code, err}
+	}
+
+	return err
+}
@@ -0,0 +1,260 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/ungerik/go-dry"
+
+	"github.com/just-install/just-install/pkg/checksum"
+	"github.com/just-install/just-install/pkg/fetch"
+	"github.com/just-install/just-install/pkg/paths"
+	"github.com/just-install/just-install/pkg/platform"
+	"github.com/just-install/just-install/pkg/redact"
+	"github.com/just-install/just-install/pkg/resolver"
+)
+
+// Explain describes, step by step, the decisions that would be taken to install the entry for the
+// given architecture with the given options, without downloading or installing anything.
+func (e *RegistryEntry) Explain(arch string, options *Options) []string {
+	if options == nil {
+		options = &Options{}
+	}
+
+	var ret []string
+	say := func(format string, a ...interface{}) {
+		ret = append(ret, fmt.Sprintf(format, a...))
+	}
+
+	// Windows version
+	version, versionErr := platform.WindowsVersion()
+	if versionErr != nil {
+		say("cannot determine Windows version (%v), using default installer URLs", versionErr)
+	} else {
+		say("running on Windows %v", version)
+	}
+
+	if e.Installer.MinWindowsVersion != "" && versionErr != nil {
+		say("package requires Windows %v or later, cannot verify requirement", e.Installer.MinWindowsVersion)
+	} else if e.Installer.MinWindowsVersion != "" {
+		if err := e.checkWindowsVersion(); err != nil {
+			say("stopping: %v", err)
+			return ret
+		}
+
+		say("package requires Windows %v or later, requirement met", e.Installer.MinWindowsVersion)
+	}
+
+	if program, ok := e.detectInstalled(); ok && options.Prefix == "" {
+		if err := e.checkInstalled(); err != nil && !options.Force {
+			say("stopping: %v", err)
+			return ret
+		}
+
+		say("found %v %v, installed without just-install, would install version %v anyway", program.DisplayName, program.DisplayVersion, e.Version)
+	}
+
+	if e.Installer.RequiredDiskSpace > 0 {
+		if err := e.checkDiskSpace(arch); err != nil {
+			say("stopping: %v", err)
+			return ret
+		}
+
+		say("package requires about %v MiB of free disk space, requirement met", e.Installer.RequiredDiskSpace)
+	}
+
+	if versionErr == nil {
+		for _, w := range e.Installer.WindowsVersions {
+			if matches, err := w.matches(version); err == nil && matches {
+				say("using installer URLs for %v", w.description())
+				break
+			}
+		}
+	}
+
+	// Installer URL
+	if r := e.Installer.Resolver; r != nil {
+		say("version and installer URLs would be found by the %v resolver at install time", r.Name)
+		if _, err := resolver.Lookup(r.Name); err != nil {
+			say("stopping: %v", err)
+			return ret
+		}
+	}
+
+	source, err := e.installerSource(arch)
+	if err != nil {
+		say("stopping: cannot determine installer URL: %v", err)
+		return ret
+	}
+
+	if source.Arch != arch && options.NoFallbackArch {
+		say("stopping: %v", noFallbackError(arch))
+		return ret
+	} else if source.Arch != arch {
+		say("no %v installer available, falling back to the %v one", arch, source.Arch)
+	}
+	say("installer URL is %v", redact.String(source.URL))
+	for _, mirror := range source.Mirrors {
+		say("installer mirror is %v", redact.String(mirror))
+	}
+
+	headers, err := e.headers()
+	if err != nil {
+		say("stopping: %v", err)
+		return ret
+	}
+
+	for _, name := range sortedNames(headers) {
+		say("would send HTTP header %v: %v", name, redact.String(headers[name]))
+	}
+
+	switch proxy := e.Installer.Proxy; proxy {
+	case "":
+		// Whatever the environment says
+	case fetch.Direct:
+		say("would connect to the installer host directly, ignoring the proxy from the environment")
+	default:
+		say("would download the installer through proxy %v, unless excluded by NO_PROXY", redact.String(proxy))
+	}
+
+	// Cache
+	cached := cachedInstallerPath(source.URL)
+	if e.Installer.Volatile {
+		say("installer is volatile, would always be downloaded to %v", cached)
+	} else if dry.FileExists(cached) {
+		say("installer is cached at %v (cache hit)", cached)
+	} else {
+		say("installer is not cached, would be downloaded to %v (cache miss)", cached)
+	}
+
+	// Integrity
+	if algorithm := checksum.Strongest(source.Hashes); algorithm != "" {
+		say("installer will be verified against %v checksum %v", algorithm, source.Hashes[algorithm])
+		if checksum.Weak(algorithm) {
+			say("%v is a weak algorithm, refused with --require-strong-hash", algorithm)
+		}
+	} else if c := e.Installer.ChecksumFile; c != nil {
+		say("installer will be verified against the %v checksum of %v published at %v", c.algorithm(), c.name(source, e), e.expandURL(c.URL))
+	} else {
+		say("no checksum available, download size will be checked against Content-Length")
+	}
+
+	if format, ok := e.installerFormat(source.Arch); ok && !options.NoContentCheck {
+		say("download will be rejected unless it is a %v", format.Name)
+	}
+
+	// Installation
+	installerPath := cached
+	if inner, ok, err := e.containedInstaller(arch); err != nil {
+		say("stopping: %v", err)
+		return ret
+	} else if ok {
+		installerPath = filepath.Join(extractionPath(cached), inner)
+		say("would extract the downloaded archive and use %v from it", inner)
+	}
+
+	if options.Profile != "" {
+		if _, err := e.profileArguments(arch, options.Profile, installerPath); err != nil {
+			say("stopping: %v", err)
+			return ret
+		}
+
+		say("using installer profile %v", options.Profile)
+	}
+
+	if options.Prefix != "" && !e.CanInstallInPrefix() {
+		say("stopping: cannot install %v packages in a prefix, only copy and zip ones", e.Installer.Kind)
+		return ret
+	}
+
+	switch e.Installer.Kind {
+	case "copy":
+		say("would copy %v to %v", installerPath, e.installDestination(arch, options.Prefix))
+	case "zip":
+		say("would extract %v to %v", installerPath, e.installDestination(arch, options.Prefix))
+	default:
+		for _, dependency := range e.dependencies(arch) {
+			say("would install dependency %v along with the package", dependency)
+		}
+
+		args, err := e.commandLine(arch, installerPath, options.Profile)
+		if err != nil {
+			say("stopping: %v", err)
+			return ret
+		}
+
+		say("would run %v", redact.String(strings.Join(args, " ")))
+	}
+
+	if shims, err := e.shims(arch); len(shims) > 0 && options.NoShims {
+		say("would not create %v shim(s), because of --no-shim", len(shims))
+	} else if err != nil {
+		say("stopping: %v", err)
+		return ret
+	} else if len(shims) > 0 && options.Prefix != "" {
+		say("would create %v shim(s) in %v", len(shims), filepath.Join(options.Prefix, prefixShimsDir))
+	} else if len(shims) > 0 {
+		say("would create %v shim(s) if exeproxy is installed", len(shims))
+		for _, name := range e.ShimNames(arch) {
+			say("would create shim %v", name)
+		}
+	}
+
+	if options.Prefix != "" {
+		say("would record the package in %v", filepath.Join(options.Prefix, PrefixManifestName))
+	} else {
+		associations, err := e.associations(arch)
+		if err != nil {
+			say("would not register file associations: %v", err)
+		}
+		for _, a := range associations {
+			say("would associate %v files with %v (%v)", a.Extension, a.ProgID, a.Command)
+		}
+
+		environment := e.environment(arch)
+		for _, name := range sortedNames(environment) {
+			say("would set environment variable %v=%v", name, redact.String(environment[name]))
+		}
+	}
+
+	if options.VerifyAfterInstall {
+		for _, c := range e.PostInstallChecks {
+			say("would verify %v %v once installed", c.Type, c.value(options.Prefix))
+		}
+	}
+
+	return ret
+}
+
+// cachedInstallerPath returns where the installer at the given URL is (or would be) cached,
+// assuming that the server does not pick a different file name.
+func cachedInstallerPath(rawurl string) string {
+	name := filepath.Base(rawurl)
+	if parsedURL, err := url.Parse(rawurl); err == nil {
+		name = filepath.Base(parsedURL.Path)
+	}
+
+	cacheDir, err := paths.CacheDirCreate()
+	if err != nil {
+		return name
+	}
+
+	return filepath.Join(cacheDir, name)
+}
@@ -0,0 +1,131 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/just-install/just-install/pkg/checksum"
+	"github.com/just-install/just-install/pkg/installer"
+	"github.com/just-install/just-install/pkg/resolver"
+)
+
+// GitHubEntry returns an ad-hoc registry entry, named after the repository, for the asset matching
+// the given pattern (i.e. "*.msi") in the latest release of the given GitHub repository ("owner/name").
+// The entry is resolved with the github resolver, so it installs the latest release. The installer type
+// is guessed from the asset name, unless the given kind isn't empty. The asset is verified against
+// the checksums published with the release, if any (installers without one are downloaded with a
+// warning).
+func GitHubEntry(ctx context.Context, repo string, assetPattern string, kind string) (string, RegistryEntry, error) {
+	parts := strings.Split(repo, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", RegistryEntry{}, fmt.Errorf("invalid GitHub repository %q, expected owner/name", repo)
+	}
+
+	if assetPattern == "" {
+		return "", RegistryEntry{}, fmt.Errorf("installing from GitHub requires an asset pattern")
+	}
+
+	release, err := resolver.GitHubLatestRelease(repo, &resolver.Options{Context: ctx})
+	if err != nil {
+		return "", RegistryEntry{}, err
+	}
+
+	var asset string
+	for _, a := range release.Assets {
+		if matched, err := path.Match(assetPattern, a.Name); err != nil {
+			return "", RegistryEntry{}, fmt.Errorf("invalid asset pattern %q: %w", assetPattern, err)
+		} else if matched {
+			asset = a.Name
+			break
+		}
+	}
+
+	if asset == "" {
+		return "", RegistryEntry{}, fmt.Errorf("release %v of %v has no asset matching %q", release.TagName, repo, assetPattern)
+	}
+
+	if kind == "" {
+		if kind = assetKind(asset); kind == "" {
+			return "", RegistryEntry{}, fmt.Errorf("cannot tell the installer type of %v, specify one", asset)
+		}
+	} else if !installer.InstallerType(kind).IsValid() {
+		return "", RegistryEntry{}, fmt.Errorf("unknown installer type: %v", kind)
+	}
+
+	ret := RegistryEntry{}
+	ret.Installer.Kind = kind
+	ret.Installer.Resolver = &resolverEntry{
+		Name: "github",
+		Params: map[string]string{
+			"repo":   repo,
+			"x86":    asset,
+			"x86_64": asset,
+		},
+	}
+	ret.Installer.ChecksumFile = releaseChecksumFile(release, asset) // Without one, downloading warns
+
+	// Resolve right away, with the release just fetched, so that the entry can be explained too
+	if err := ret.resolve(ctx); err != nil {
+		return "", RegistryEntry{}, err
+	}
+
+	return strings.ToLower(parts[1]), ret, nil
+}
+
+// assetKind guesses the installer type of the given release asset from its extension, if possible.
+// Executables are left out, there's no telling which tool made them.
+func assetKind(name string) string {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".msi":
+		return string(installer.MSI)
+	case ".appx", ".msix", ".msixbundle":
+		return string(installer.MSIX)
+	case ".msu":
+		return string(installer.MSU)
+	default:
+		return ""
+	}
+}
+
+// releaseChecksumFile returns the checksum file published with the given release for the given
+// asset, if any: either one for the asset alone (i.e. "foo.msi.sha256") or one for all of them (i.e.
+// "SHA256SUMS" or "foo_1.0_checksums.txt").
+func releaseChecksumFile(release *resolver.Release, asset string) *checksumFileEntry {
+	for _, algorithm := range []string{checksum.SHA512, checksum.SHA256} {
+		for _, suffix := range []string{"." + algorithm, "." + algorithm + "sum"} {
+			if url, ok := release.Asset(asset + suffix); ok {
+				return &checksumFileEntry{URL: url, Algorithm: algorithm}
+			}
+		}
+	}
+
+	for _, a := range release.Assets {
+		name := strings.ToLower(a.Name)
+
+		switch {
+		case strings.Contains(name, checksum.SHA512+"sum"):
+			return &checksumFileEntry{URL: a.URL, Algorithm: checksum.SHA512}
+		case strings.Contains(name, checksum.SHA256+"sum"), strings.HasSuffix(name, "checksums.txt"):
+			return &checksumFileEntry{URL: a.URL, Algorithm: checksum.SHA256}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/just-install/just-install/pkg/checksum"
+)
+
+// integrityDir is the directory, next to cached installers, that holds their integrity records.
+const integrityDir = "integrity"
+
+// integrityRecord describes a cached installer as it was when it was downloaded and verified, so
+// that changes made to it afterwards (i.e. by malware, or by hand) can be detected before reusing it.
+type integrityRecord struct {
+	URL        string    `json:"url"`
+	Size       int64     `json:"size"`
+	Algorithm  string    `json:"algorithm"`
+	Digest     string    `json:"digest"`
+	VerifiedAt time.Time `json:"verifiedAt"`
+}
+
+// integrityPath returns where the integrity record of the given cached installer is kept.
+func integrityPath(installer string) string {
+	return filepath.Join(filepath.Dir(installer), integrityDir, filepath.Base(installer)+".json")
+}
+
+// recordIntegrity saves the integrity record of the given installer, just downloaded from rawurl and
+// verified against the given digest, if any. Without a digest from the registry, the SHA-256 digest
+// of the file as downloaded is recorded. Failures only cost a check next time, so they are logged
+// rather than returned.
+func recordIntegrity(installer string, rawurl string, algorithm string, digest string) {
+	info, err := os.Stat(installer)
+	if err != nil {
+		log.Println("WARNING: could not record the integrity of the cached installer:", err)
+		return
+	}
+
+	if algorithm == "" {
+		algorithm = checksum.SHA256
+		if digest, err = checksum.FileWith(installer, algorithm); err != nil {
+			log.Println("WARNING: could not record the integrity of the cached installer:", err)
+			return
+		}
+	}
+
+	data, err := json.MarshalIndent(&integrityRecord{rawurl, info.Size(), algorithm, digest, time.Now().UTC()}, "", "  ")
+	if err != nil {
+		log.Println("WARNING: could not record the integrity of the cached installer:", err)
+		return
+	}
+
+	path := integrityPath(installer)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		log.Println("WARNING: could not record the integrity of the cached installer:", err)
+		return
+	}
+
+	if err := ioutil.WriteFile(path+".tmp", data, 0644); err != nil {
+		log.Println("WARNING: could not record the integrity of the cached installer:", err)
+		return
+	}
+
+	if err := os.Rename(path+".tmp", path); err != nil {
+		log.Println("WARNING: could not record the integrity of the cached installer:", err)
+	}
+}
+
+// checkIntegrity returns an error if the given cached installer changed since its integrity record
+// was saved. Installers cached before records were kept have none, and always pass. Digests are not
+// computed again when the caller is about to verify the installer against the same one anyway.
+func checkIntegrity(installer string, algorithm string, digest string) error {
+	data, err := ioutil.ReadFile(integrityPath(installer))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var record integrityRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return fmt.Errorf("corrupted integrity record: %w", err)
+	}
+
+	info, err := os.Stat(installer)
+	if err != nil {
+		return err
+	}
+
+	if info.Size() != record.Size {
+		return fmt.Errorf("size is %v bytes, %v when verified on %v", info.Size(), record.Size, record.VerifiedAt.Format(time.RFC3339))
+	}
+
+	if record.Algorithm == algorithm && record.Digest == digest {
+		return nil
+	}
+
+	if err := checksum.VerifyWith(installer, record.Algorithm, record.Digest); err != nil {
+		return fmt.Errorf("%w, when verified on %v", err, record.VerifiedAt.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// forgetIntegrity removes the integrity record of the given installer, once it's removed from the
+// cache.
+func forgetIntegrity(installer string) {
+	os.Remove(integrityPath(installer))
+}
@@ -0,0 +1,353 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// errInvalidJSON is returned when the document to edit is not valid JSON.
+var errInvalidJSON = errors.New("invalid JSON")
+
+// jsonMember is a member of a JSON object, as found by scanObject.
+type jsonMember struct {
+	key        string
+	keyStart   int // Offset of the opening quote of the key
+	valueStart int
+	valueEnd   int // Offset right after the value
+}
+
+// editJSON returns a copy of the given JSON document, with the value at the given path replaced
+// by the given one. Everything else is left as it was, including whitespace and the order of keys.
+// Object keys are matched like matchKey does, list elements are addressed by their index. Missing
+// object members along the path are added at the end of their parent object, indented like its
+// other members.
+func editJSON(data []byte, path []string, value interface{}) ([]byte, error) {
+	start := skipSpace(data, 0)
+
+	for i, component := range path {
+		if start >= len(data) {
+			return nil, errInvalidJSON
+		}
+
+		switch data[start] {
+		case '{':
+			members, end, err := scanObject(data, start)
+			if err != nil {
+				return nil, err
+			}
+
+			member, ok := matchMember(members, component)
+			if !ok {
+				return insertMember(data, start, end, members, path[i:], value)
+			}
+
+			start = member.valueStart
+		case '[':
+			elements, _, err := scanArray(data, start)
+			if err != nil {
+				return nil, err
+			}
+
+			index, err := strconv.Atoi(component)
+			if err != nil || index < 0 || index >= len(elements) {
+				return nil, fmt.Errorf("invalid index %v in %v", component, strings.Join(path, "."))
+			}
+
+			start = elements[index]
+		default:
+			return nil, fmt.Errorf("cannot set %v: %v is not an object or a list", strings.Join(path, "."), strings.Join(path[:i], "."))
+		}
+	}
+
+	end, err := skipValue(data, start)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, err := marshalCompact(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return splice(data, start, end, encoded), nil
+}
+
+// matchMember returns the member whose key matches the given one, preferring an exact match to a
+// case-insensitive one, like matchKey.
+func matchMember(members []jsonMember, key string) (jsonMember, bool) {
+	for _, m := range members {
+		if m.key == key {
+			return m, true
+		}
+	}
+
+	for _, m := range members {
+		if strings.EqualFold(m.key, key) {
+			return m, true
+		}
+	}
+
+	return jsonMember{}, false
+}
+
+// insertMember adds a member for the first component of the given path to the object between
+// offsets start and end, with nested objects for the other components and the given value at the
+// bottom.
+func insertMember(data []byte, start int, end int, members []jsonMember, path []string, value interface{}) ([]byte, error) {
+	var nested interface{} = value
+	for i := len(path) - 1; i > 0; i-- {
+		nested = map[string]interface{}{path[i]: nested}
+	}
+
+	key, err := marshalCompact(path[0])
+	if err != nil {
+		return nil, err
+	}
+
+	if len(members) == 0 {
+		encoded, err := marshalCompact(nested)
+		if err != nil {
+			return nil, err
+		}
+
+		return splice(data, start+1, end-1, append(append(key, ": "...), encoded...)), nil
+	}
+
+	// Copy the layout of the existing members: what precedes their key, and what separates it from
+	// their value.
+	first := members[0]
+	prefix := data[start+1 : first.keyStart]
+	if len(members) > 1 {
+		between := data[first.valueEnd:members[1].keyStart]
+		prefix = between[bytes.IndexByte(between, ',')+1:]
+	} else if bytes.IndexByte(prefix, '\n') < 0 {
+		prefix = []byte(" ")
+	}
+	keyEnd, err := skipValue(data, first.keyStart)
+	if err != nil {
+		return nil, err
+	}
+	separator := data[keyEnd:first.valueStart]
+
+	var encoded []byte
+	if newline := bytes.LastIndexByte(prefix, '\n'); newline >= 0 {
+		indent := string(prefix[newline+1:])
+		encoded, err = marshalIndented(nested, indent, indentUnit(data, start, indent))
+	} else {
+		encoded, err = marshalCompact(nested)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var member []byte
+	member = append(member, ',')
+	member = append(member, prefix...)
+	member = append(member, key...)
+	member = append(member, separator...)
+	member = append(member, encoded...)
+
+	last := members[len(members)-1]
+	return splice(data, last.valueEnd, last.valueEnd, member), nil
+}
+
+// indentUnit returns the indentation added for each level of nesting, guessed from the indentation
+// of the members of the object at the given offset and that of the line it starts on.
+func indentUnit(data []byte, start int, memberIndent string) string {
+	lineStart := bytes.LastIndexByte(data[:start], '\n') + 1
+	lineIndent := string(data[lineStart:skipSpace(data[:start], lineStart)])
+
+	if strings.HasPrefix(memberIndent, lineIndent) && len(memberIndent) > len(lineIndent) {
+		return memberIndent[len(lineIndent):]
+	}
+
+	return "  "
+}
+
+// splice returns a copy of data with the bytes between offsets start and end replaced by the given
+// ones.
+func splice(data []byte, start int, end int, replacement []byte) []byte {
+	ret := make([]byte, 0, len(data)-(end-start)+len(replacement))
+	ret = append(ret, data[:start]...)
+	ret = append(ret, replacement...)
+
+	return append(ret, data[end:]...)
+}
+
+// marshalCompact encodes the given value as JSON on a single line, without escaping HTML.
+func marshalCompact(v interface{}) ([]byte, error) {
+	return marshalIndented(v, "", "")
+}
+
+// marshalIndented encodes the given value as JSON, without escaping HTML. Lines after the first
+// one start with prefix, followed by indent for each level of nesting, as with json.MarshalIndent.
+func marshalIndented(v interface{}, prefix string, indent string) ([]byte, error) {
+	var buf bytes.Buffer
+
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if indent != "" {
+		encoder.SetIndent(prefix, indent)
+	}
+
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// scanObject returns the members of the object starting at the given offset, and the offset right
+// after it.
+func scanObject(data []byte, start int) ([]jsonMember, int, error) {
+	var members []jsonMember
+
+	i := skipSpace(data, start+1)
+	if i < len(data) && data[i] == '}' {
+		return nil, i + 1, nil
+	}
+
+	for {
+		if i >= len(data) || data[i] != '"' {
+			return nil, 0, errInvalidJSON
+		}
+
+		keyEnd, err := skipString(data, i)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		var key string
+		if err := json.Unmarshal(data[i:keyEnd], &key); err != nil {
+			return nil, 0, errInvalidJSON
+		}
+
+		colon := skipSpace(data, keyEnd)
+		if colon >= len(data) || data[colon] != ':' {
+			return nil, 0, errInvalidJSON
+		}
+
+		valueStart := skipSpace(data, colon+1)
+		valueEnd, err := skipValue(data, valueStart)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		members = append(members, jsonMember{key, i, valueStart, valueEnd})
+
+		i = skipSpace(data, valueEnd)
+		if i >= len(data) {
+			return nil, 0, errInvalidJSON
+		} else if data[i] == '}' {
+			return members, i + 1, nil
+		} else if data[i] != ',' {
+			return nil, 0, errInvalidJSON
+		}
+
+		i = skipSpace(data, i+1)
+	}
+}
+
+// scanArray returns the offsets of the elements of the array starting at the given offset, and
+// the offset right after it.
+func scanArray(data []byte, start int) ([]int, int, error) {
+	var elements []int
+
+	i := skipSpace(data, start+1)
+	if i < len(data) && data[i] == ']' {
+		return nil, i + 1, nil
+	}
+
+	for {
+		end, err := skipValue(data, i)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		elements = append(elements, i)
+
+		i = skipSpace(data, end)
+		if i >= len(data) {
+			return nil, 0, errInvalidJSON
+		} else if data[i] == ']' {
+			return elements, i + 1, nil
+		} else if data[i] != ',' {
+			return nil, 0, errInvalidJSON
+		}
+
+		i = skipSpace(data, i+1)
+	}
+}
+
+// skipValue returns the offset right after the JSON value starting at the given offset.
+func skipValue(data []byte, start int) (int, error) {
+	if start >= len(data) {
+		return 0, errInvalidJSON
+	}
+
+	switch c := data[start]; {
+	case c == '{':
+		_, end, err := scanObject(data, start)
+		return end, err
+	case c == '[':
+		_, end, err := scanArray(data, start)
+		return end, err
+	case c == '"':
+		return skipString(data, start)
+	case c == '-' || c == 't' || c == 'f' || c == 'n' || (c >= '0' && c <= '9'):
+		end := start
+		for end < len(data) && strings.IndexByte("+-.0123456789Eaeflnrstu", data[end]) >= 0 {
+			end++
+		}
+
+		if !json.Valid(data[start:end]) {
+			return 0, errInvalidJSON
+		}
+
+		return end, nil
+	default:
+		return 0, errInvalidJSON
+	}
+}
+
+// skipString returns the offset right after the JSON string starting at the given offset.
+func skipString(data []byte, start int) (int, error) {
+	for i := start + 1; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1, nil
+		}
+	}
+
+	return 0, errInvalidJSON
+}
+
+// skipSpace returns the offset of the first non-whitespace byte at or after the given offset.
+func skipSpace(data []byte, i int) int {
+	for i < len(data) && strings.IndexByte(" \t\r\n", data[i]) >= 0 {
+		i++
+	}
+
+	return i
+}
@@ -0,0 +1,168 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/just-install/just-install/pkg/fetch"
+)
+
+// Strategies to pick the URL to download an installer with mirrors from, see Options.
+const (
+	MirrorFirst   = "first"   // The installer URL, then mirrors in the order they are listed
+	MirrorFastest = "fastest" // The most responsive server first
+	MirrorRandom  = "random"  // A random order, to spread the load
+)
+
+// mirrorProbeTimeout is how long to wait for a server to respond to a probe.
+const mirrorProbeTimeout = 5 * time.Second
+
+// latencies caches how long each server took to respond to a probe, so that each one is probed at
+// most once per run. Unresponsive servers have a negative latency.
+var latencies = struct {
+	sync.Mutex
+	hosts map[string]time.Duration
+}{hosts: make(map[string]time.Duration)}
+
+// SupportedMirrorStrategy returns whether the given mirror strategy is known.
+func SupportedMirrorStrategy(strategy string) bool {
+	switch strategy {
+	case "", MirrorFirst, MirrorFastest, MirrorRandom:
+		return true
+	default:
+		return false
+	}
+}
+
+// orderURLs returns the given URLs of the same installer in the order they should be tried,
+// according to the given strategy.
+func orderURLs(ctx context.Context, urls []string, strategy string) ([]string, error) {
+	ret := append([]string(nil), urls...)
+	if len(ret) < 2 {
+		return ret, nil
+	}
+
+	switch strategy {
+	case "", MirrorFirst:
+		// Nothing to do
+	case MirrorRandom:
+		rand.New(rand.NewSource(time.Now().UnixNano())).Shuffle(len(ret), func(i, j int) { ret[i], ret[j] = ret[j], ret[i] })
+	case MirrorFastest:
+		latency := probeLatencies(ctx, ret)
+
+		// Unresponsive servers go last, but are still tried in case the probe was unlucky.
+		sort.SliceStable(ret, func(i, j int) bool {
+			a, b := latency[ret[i]], latency[ret[j]]
+			if a < 0 || b < 0 {
+				return a >= 0 && b < 0
+			}
+
+			return a < b
+		})
+	default:
+		return nil, fmt.Errorf("unknown mirror strategy: %v", strategy)
+	}
+
+	return ret, nil
+}
+
+// probeLatencies returns how long the server of each of the given URLs takes to respond, probing
+// those that haven't been probed yet in parallel. Local installers respond immediately.
+func probeLatencies(ctx context.Context, urls []string) map[string]time.Duration {
+	latencies.Lock()
+	defer latencies.Unlock()
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex // Guards latencies.hosts while probing
+
+	for _, rawurl := range urls {
+		host := urlHost(rawurl)
+		if _, ok := latencies.hosts[host]; ok || host == "" {
+			continue
+		}
+
+		latencies.hosts[host] = -1 // Don't probe a host twice
+
+		wg.Add(1)
+		go func(rawurl string, host string) {
+			defer wg.Done()
+
+			latency := probeLatency(ctx, rawurl)
+			if latency < 0 {
+				log.Println("WARNING: mirror", host, "did not respond")
+			}
+
+			mutex.Lock()
+			latencies.hosts[host] = latency
+			mutex.Unlock()
+		}(rawurl, host)
+	}
+
+	wg.Wait()
+
+	ret := make(map[string]time.Duration)
+	for _, rawurl := range urls {
+		ret[rawurl] = latencies.hosts[urlHost(rawurl)]
+	}
+
+	return ret
+}
+
+// probeLatency returns how long it takes for the server of the given URL to respond to a HEAD
+// request, or a negative duration if it doesn't. Any response counts, redirects are not followed.
+func probeLatency(ctx context.Context, rawurl string) time.Duration {
+	ctx, cancel := context.WithTimeout(ctx, mirrorProbeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequest("HEAD", rawurl, nil)
+	if err != nil {
+		return -1
+	}
+
+	client := fetch.NewClient()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	start := time.Now()
+
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return -1
+	}
+	resp.Body.Close()
+
+	return time.Since(start)
+}
+
+// urlHost returns the host of the given URL, or an empty string for local installers.
+func urlHost(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+
+	return u.Host
+}
@@ -0,0 +1,126 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ungerik/go-dry"
+
+	"github.com/just-install/just-install/pkg/checksum"
+	"github.com/just-install/just-install/pkg/resolver"
+)
+
+// InstallerPlan describes the installer that would be downloaded and run to install a package, as
+// returned by Plan.
+type InstallerPlan struct {
+	Version      string            `json:"version"`                // Package version, as found by its resolver if it has one
+	Arch         string            `json:"arch"`                   // May differ from the requested one, when falling back to the x86 installer
+	Kind         string            `json:"kind"`                   // Installer kind, as in the registry
+	URL          string            `json:"url"`                    // With placeholders expanded
+	Mirrors      []string          `json:"mirrors,omitempty"`      // Alternative URLs of the same installer
+	Hashes       map[string]string `json:"hashes,omitempty"`       // Algorithm -> hex-encoded digest, including published ones
+	Dependencies []string          `json:"dependencies,omitempty"` // URLs of the packages installed along with it
+	Interactive  bool              `json:"interactive,omitempty"`  // Might require user interaction
+	Cached       bool              `json:"cached"`                 // Whether the installer is in the installer cache, and matches its checksum
+	Unknown      bool              `json:"unknown,omitempty"`      // Found by a resolver, which needs the network (see CacheOnly)
+}
+
+// unknown is the version and URL of the installers that can only be found with the network.
+const unknown = "unknown"
+
+// Plan returns the installer that would be used to install the entry for the given architecture
+// with the given options, without downloading it. Resolvers and published checksum files are
+// queried, so the plan is as accurate as possible, unless CacheOnly is set: then the version and URL
+// of entries with a resolver are "unknown", and only cached checksum files are used.
+func (e *RegistryEntry) Plan(ctx context.Context, arch string, options *Options) (*InstallerPlan, error) {
+	if options == nil {
+		options = &Options{}
+	}
+
+	if err := e.checkWindowsVersion(); err != nil {
+		return nil, err
+	}
+
+	if e.Installer.Resolver != nil && options.CacheOnly {
+		if _, err := resolver.Lookup(e.Installer.Resolver.Name); err != nil {
+			return nil, err
+		}
+
+		return &InstallerPlan{Version: unknown, Arch: arch, Kind: e.Installer.Kind, URL: unknown, Interactive: e.Installer.Interactive, Unknown: true}, nil
+	}
+
+	if err := e.resolve(ctx); err != nil {
+		return nil, err
+	}
+
+	source, err := e.installerSource(arch)
+	if err != nil {
+		return nil, fmt.Errorf("cannot determine installer URL: %w", err)
+	}
+
+	if source.Arch != arch && options.NoFallbackArch {
+		return nil, noFallbackError(arch)
+	}
+
+	if err := e.addPublishedHash(ctx, &source, options.CacheOnly); err != nil {
+		return nil, err
+	}
+
+	algorithm, err := selectHash(source.Hashes, options)
+	if err != nil {
+		return nil, err
+	}
+
+	// Like CachedInstaller, but without removing corrupted installers.
+	cached := false
+	if path := cachedInstallerPath(source.URL); !e.Installer.Volatile && dry.FileExists(path) {
+		cached = algorithm == "" || checksum.VerifyWith(path, algorithm, source.Hashes[algorithm]) == nil
+	}
+
+	return &InstallerPlan{
+		Version:      e.Version,
+		Arch:         source.Arch,
+		Kind:         e.Installer.Kind,
+		URL:          source.URL,
+		Mirrors:      source.Mirrors,
+		Hashes:       source.Hashes,
+		Dependencies: e.dependencies(arch),
+		Interactive:  e.Installer.Interactive,
+		Cached:       cached,
+	}, nil
+}
+
+// Pin makes the entry install exactly the installer in the given plan, as returned by Plan: the
+// version, kind, URLs and checksums of the plan replace those of the entry, which is no longer
+// resolved. Installer options are left as they are.
+func (e *RegistryEntry) Pin(plan *InstallerPlan) {
+	e.Version = plan.Version
+	e.Installer.Kind = plan.Kind
+	e.Installer.Resolver = nil
+	e.Installer.ChecksumFile = nil
+	e.Installer.WindowsVersions = nil
+	e.Installer.Checksums = nil
+	e.Installer.Hashes = map[string]map[string]string{plan.Arch: plan.Hashes}
+	e.Installer.Mirrors = map[string][]string{plan.Arch: plan.Mirrors}
+
+	if plan.Arch == "x86_64" {
+		e.Installer.X86, e.Installer.X86_64 = "", plan.URL
+	} else {
+		e.Installer.X86, e.Installer.X86_64 = plan.URL, ""
+	}
+}
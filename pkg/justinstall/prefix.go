@@ -0,0 +1,250 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ungerik/go-dry"
+)
+
+// PrefixManifestName is the name of the manifest at the root of a prefix, recording what was
+// installed in it.
+const PrefixManifestName = "just-install-prefix.json"
+
+// prefixShimsDir is the directory, relative to a prefix, where the shims of the packages installed
+// in it are created.
+const prefixShimsDir = "Shims"
+
+// prefixRoots are the environment variables pointing to the directories that install destinations
+// are usually relative to. Destinations below one of them are moved to the same relative path in a
+// prefix, others keep their path without the drive.
+var prefixRoots = []string{"APPDATA", "LOCALAPPDATA", "ProgramData", "ProgramFiles", "ProgramFiles(x86)", "ProgramW6432", "USERPROFILE", "SystemDrive"}
+
+// prefixManifestMutex serializes updates to prefix manifests, made as packages are installed.
+var prefixManifestMutex sync.Mutex
+
+// PrefixManifest records the packages installed in a prefix, and the files they created there.
+type PrefixManifest struct {
+	Packages map[string]PrefixPackage `json:"packages"`
+}
+
+// PrefixPackage is a package installed in a prefix.
+type PrefixPackage struct {
+	Version     string    `json:"version"`
+	Arch        string    `json:"arch"`
+	Paths       []string  `json:"paths"` // Relative to the prefix, so that it can be moved
+	InstalledAt time.Time `json:"installedAt"`
+}
+
+// CanInstallInPrefix returns whether the entry can be installed in a prefix: only packages that are
+// copied or extracted can, since installers decide by themselves where files go.
+func (e *RegistryEntry) CanInstallInPrefix() bool {
+	return e.Installer.Kind == "copy" || e.Installer.Kind == "zip"
+}
+
+// prefixed returns where the given absolute path goes in the given prefix. Relative paths and paths
+// already in the prefix are returned unchanged, as are all paths without a prefix.
+func prefixed(path string, prefix string) string {
+	if prefix == "" || (!filepath.IsAbs(path) && filepath.VolumeName(path) == "") {
+		return path
+	}
+
+	if rel, ok := relativeTo(path, prefix); ok {
+		return filepath.Join(prefix, rel)
+	}
+
+	// Prefer the deepest root, i.e. ProgramFiles over SystemDrive
+	var roots []string
+	for _, name := range prefixRoots {
+		if root := os.Getenv(name); root != "" {
+			roots = append(roots, root)
+		}
+	}
+	sort.Slice(roots, func(i, j int) bool { return len(roots[i]) > len(roots[j]) })
+
+	for _, root := range roots {
+		if rel, ok := relativeTo(path, root); ok {
+			return filepath.Join(prefix, rel)
+		}
+	}
+
+	return filepath.Join(prefix, strings.TrimPrefix(path, filepath.VolumeName(path)))
+}
+
+// relativeTo returns the given path relative to the given directory, if it is below it. Paths are
+// compared ignoring case, as Windows does.
+func relativeTo(path string, dir string) (string, bool) {
+	path, dir = filepath.Clean(path), filepath.Clean(dir)
+	if strings.EqualFold(path, dir) {
+		return ".", true
+	}
+
+	dir = strings.TrimSuffix(dir, string(filepath.Separator)) + string(filepath.Separator)
+	if len(path) <= len(dir) || !strings.EqualFold(path[:len(dir)], dir) {
+		return "", false
+	}
+
+	return path[len(dir):], true
+}
+
+// installDestination returns where the entry is copied or extracted to, in the given prefix if any.
+func (e *RegistryEntry) installDestination(arch string, prefix string) string {
+	return prefixed(e.destination(arch), prefix)
+}
+
+// prefixShim is a shim of an entry installed in a prefix, for one of the targets matching it.
+type prefixShim struct {
+	shim   shim
+	target string // Executable in the prefix
+	path   string // Batch file in the shim directory of the prefix
+}
+
+// prefixShims returns the shims of the entry in the given prefix, for the executables that exist.
+func (e *RegistryEntry) prefixShims(arch string, prefix string) ([]prefixShim, error) {
+	shims, err := e.shims(arch)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []prefixShim
+	for _, s := range shims {
+		for _, target := range shimTargets(prefixed(s.Target, prefix)) {
+			name := s.fileName(target)
+			path := filepath.Join(prefix, prefixShimsDir, strings.TrimSuffix(name, filepath.Ext(name))+".cmd")
+
+			ret = append(ret, prefixShim{s, target, path})
+		}
+	}
+
+	return ret, nil
+}
+
+// createPrefixShims creates the shims declared by the entry in the given prefix. Unlike those made
+// by CreateShims, they are batch files running their target relative to themselves, so that they
+// keep working once the prefix is moved, and they don't need exeproxy.
+func (e *RegistryEntry) createPrefixShims(arch string, prefix string) error {
+	shims, err := e.prefixShims(arch, prefix)
+	if err != nil || len(shims) == 0 {
+		return err
+	}
+
+	dir := filepath.Join(prefix, prefixShimsDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("could not create shim directory: %w", err)
+	}
+
+	for _, s := range shims {
+		rel, err := filepath.Rel(dir, s.target)
+		if err != nil {
+			return fmt.Errorf("cannot create shim for %v: %w", s.target, err)
+		}
+
+		log.Printf("creating shim for %s (%s)\n", s.target, s.path)
+		if err := s.shim.writeBatchCommand(s.path, `"%~dp0`+strings.ReplaceAll(rel, "%", "%%")+`"`); err != nil {
+			return fmt.Errorf("could not create shim: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// LoadPrefixManifest reads the manifest of the given prefix. A prefix without a manifest has no
+// packages.
+func LoadPrefixManifest(prefix string) (*PrefixManifest, error) {
+	ret := &PrefixManifest{Packages: make(map[string]PrefixPackage)}
+
+	data, err := ioutil.ReadFile(filepath.Join(prefix, PrefixManifestName))
+	if errors.Is(err, os.ErrNotExist) {
+		return ret, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("could not read prefix manifest: %w", err)
+	}
+
+	if err := json.Unmarshal(data, ret); err != nil {
+		return nil, fmt.Errorf("corrupted prefix manifest: %w", err)
+	}
+	if ret.Packages == nil {
+		ret.Packages = make(map[string]PrefixPackage)
+	}
+
+	return ret, nil
+}
+
+// save writes the manifest at the root of the given prefix.
+func (m *PrefixManifest) save(prefix string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(prefix, PrefixManifestName)
+	if err := ioutil.WriteFile(path+".tmp", data, 0644); err != nil {
+		return fmt.Errorf("could not write prefix manifest: %w", err)
+	}
+
+	return os.Rename(path+".tmp", path)
+}
+
+// RecordInPrefix adds the entry, just installed in the given prefix under the given name, to the
+// manifest of the prefix along with the files and shims it created there.
+func (e *RegistryEntry) RecordInPrefix(prefix string, name string, arch string) error {
+	paths := []string{e.installDestination(arch, prefix)}
+	if shims, err := e.prefixShims(arch, prefix); err == nil {
+		for _, s := range shims {
+			if dry.FileExists(s.path) {
+				paths = append(paths, s.path)
+			}
+		}
+	}
+
+	var relPaths []string
+	for _, path := range paths {
+		if rel, ok := relativeTo(path, prefix); ok {
+			relPaths = append(relPaths, rel)
+		}
+	}
+
+	prefixManifestMutex.Lock()
+	defer prefixManifestMutex.Unlock()
+
+	manifest, err := LoadPrefixManifest(prefix)
+	if err != nil {
+		return err
+	}
+
+	manifest.Packages[name] = PrefixPackage{e.Version, arch, relPaths, time.Now().UTC()}
+	return manifest.save(prefix)
+}
+
+// CheckPrefix returns an error if the given directory is not a prefix, i.e. it has no manifest.
+func CheckPrefix(prefix string) error {
+	if !dry.FileExists(filepath.Join(prefix, PrefixManifestName)) {
+		return fmt.Errorf("%v is not a just-install prefix, it has no %v", prefix, PrefixManifestName)
+	}
+
+	return nil
+}
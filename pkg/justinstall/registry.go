@@ -16,25 +16,49 @@
 package justinstall
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/gotopkg/mslnk/pkg/mslnk"
 	"github.com/ungerik/go-dry"
 
+	"github.com/just-install/just-install/pkg/checksum"
 	"github.com/just-install/just-install/pkg/cmd"
 	"github.com/just-install/just-install/pkg/fetch"
 	"github.com/just-install/just-install/pkg/installer"
 	"github.com/just-install/just-install/pkg/paths"
+	"github.com/just-install/just-install/pkg/platform"
+	"github.com/just-install/just-install/pkg/timing"
+	"github.com/just-install/just-install/pkg/yaml"
 )
 
-const registrySupportedVersion = 4
+// Registry formats understood by this version of just-install. RegistryVersion is bumped for
+// backward-incompatible changes, which registries must match exactly. RegistrySchemaVersion is bumped
+// when fields are added, which older versions of just-install would silently ignore: registries with
+// a newer schema version still load, with a warning.
+const (
+	RegistryVersion       = 4
+	RegistrySchemaVersion = 1
+)
+
+// newerSchemaWarning warns about registries with a newer schema version once, even though the
+// registry is parsed more than once when downloaded.
+var newerSchemaWarning sync.Once
+
+// updateAdvice tells users how to get a version of just-install that understands newer registries.
+const updateAdvice = "please update just-install by running `just-install self-update` (or msiexec.exe /i https://just-install.github.io/stable/just-install.msi)"
 
 var (
 	shimsPath = os.ExpandEnv("${SystemDrive}\\Shims")
@@ -46,23 +70,93 @@ var (
 //
 
 // LoadRegistry unmarshals the registry from a local file path.
-func LoadRegistry(path string) Registry {
+func LoadRegistry(path string) (*Registry, error) {
 	data, err := ioutil.ReadFile(path)
 	if err != nil {
-		log.Fatalf("Unable to read the registry file.")
+		return nil, fmt.Errorf("unable to read the registry file: %w", err)
+	}
+
+	data, _, err = registryJSON(data, path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse the registry file: %w", err)
 	}
 
-	var ret Registry
+	return parseRegistry(data, path)
+}
 
-	if err := json.Unmarshal(data, &ret); err != nil {
-		log.Fatalln("Unable to parse the registry file.")
+// LoadRegistryWithOverlays is like LoadRegistry, but applies the given overlays (see
+// RegistryFile.ApplyOverlay) to the registry after reading it. The file itself is left untouched.
+func LoadRegistryWithOverlays(path string, overlays []string) (*Registry, error) {
+	if len(overlays) == 0 {
+		return LoadRegistry(path)
 	}
 
-	if ret.Version != registrySupportedVersion {
-		log.Fatalln("Please update to a new version of just-install by running: msiexec.exe /i https://just-install.github.io/stable/just-install.msi")
+	registryFile, err := ReadRegistryFile(path)
+	if err != nil {
+		return nil, err
 	}
 
-	return ret
+	for _, overlay := range overlays {
+		if err := registryFile.ApplyOverlay(overlay); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := registryFile.Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	return parseRegistry(data, path)
+}
+
+// parseRegistry unmarshals the registry from the JSON contents of the registry file at the given
+// path.
+func parseRegistry(data []byte, path string) (*Registry, error) {
+	ret := &Registry{}
+
+	if err := json.Unmarshal(data, ret); err != nil {
+		return nil, fmt.Errorf("unable to parse the registry file: %w", err)
+	}
+
+	if ret.Version > RegistryVersion {
+		return nil, fmt.Errorf("the registry is in format %v, newer than format %v understood by this version of just-install: %v", ret.Version, RegistryVersion, updateAdvice)
+	} else if ret.Version != RegistryVersion {
+		return nil, fmt.Errorf("the registry is in format %v, older than format %v understood by this version of just-install: update the registry file", ret.Version, RegistryVersion)
+	}
+
+	if ret.SchemaVersion > RegistrySchemaVersion {
+		newerSchemaWarning.Do(func() {
+			log.Printf("WARNING: the registry uses schema version %v, newer than version %v understood by this version of just-install, some packages might not be installed as intended: %v", ret.SchemaVersion, RegistrySchemaVersion, updateAdvice)
+		})
+	}
+
+	// Relative installer paths are relative to the registry file
+	if baseDir, err := filepath.Abs(filepath.Dir(path)); err == nil {
+		for name, entry := range ret.Packages {
+			entry.baseDir = baseDir
+			ret.Packages[name] = entry
+		}
+	}
+
+	return ret, nil
+}
+
+// registryJSON returns the given registry file as JSON, converting it if it's written in YAML. YAML
+// files are recognized by their extension or, failing that, by not starting with a JSON object. The
+// returned boolean reports whether a conversion took place.
+func registryJSON(data []byte, path string) ([]byte, bool, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext != ".yaml" && ext != ".yml" && bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return data, false, nil
+	}
+
+	ret, err := yaml.ToJSONFor(data, &Registry{})
+	if err != nil {
+		return nil, false, err
+	}
+
+	return ret, true, nil
 }
 
 //
@@ -70,11 +164,130 @@ func LoadRegistry(path string) Registry {
 //
 
 type installerEntry struct {
-	Interactive bool
-	Kind        string
-	Options     map[string]interface{} // Optional
-	X86         string
-	X86_64      string
+	ChecksumFile      *checksumFileEntry           // Optional, checksum file published by upstream
+	Checksums         map[string]string            // Optional, architecture -> hex-encoded SHA-256 digest
+	Hashes            map[string]map[string]string // Optional, architecture -> algorithm -> hex-encoded digest
+	Headers           map[string]string            // Optional, extra HTTP headers to send when downloading the installer
+	Interactive       bool
+	Kind              string
+	MinWindowsVersion string                 // Optional
+	Mirrors           map[string][]string    // Optional, architecture -> alternative URLs of the same installer
+	Options           map[string]interface{} // Optional
+	ParallelSafe      bool                   // Optional, can be installed alongside other packages
+	Proxy             string                 // Optional, proxy URL for downloads of the installer, or "direct"
+	RequiredDiskSpace int64                  // Optional, approximate MiB needed to download and install the package
+	Resolver          *resolverEntry         // Optional, finds the version and URLs at install time
+	Volatile          bool                   // Optional, the installer changes without its URL changing
+	WindowsVersions   []windowsVersionEntry  // Optional
+	X86               string
+	X86_64            string
+}
+
+// windowsVersionEntry contains alternative installer URLs for the Windows versions in the given
+// range. Both ends of the range are inclusive and optional.
+type windowsVersionEntry struct {
+	Checksums map[string]string            // Optional, architecture -> hex-encoded SHA-256 digest
+	Hashes    map[string]map[string]string // Optional, architecture -> algorithm -> hex-encoded digest
+	Min       string
+	Max       string
+	X86       string
+	X86_64    string
+}
+
+// installerSource is where the installer for an architecture is downloaded from.
+type installerSource struct {
+	Arch    string // May differ from the requested one, when falling back to the x86 installer
+	URL     string
+	Hashes  map[string]string // Optional, algorithm -> hex-encoded digest
+	Mirrors []string          // Optional, alternative URLs of the same installer
+}
+
+// mergeHashes returns the digests of the installer for the given architecture, from both the
+// SHA-256 checksums and the hashes of any algorithm.
+func mergeHashes(checksums map[string]string, hashes map[string]map[string]string, arch string) map[string]string {
+	ret := make(map[string]string)
+
+	for algorithm, digest := range hashes[arch] {
+		ret[strings.ToLower(algorithm)] = digest
+	}
+
+	if checksums[arch] != "" {
+		ret[checksum.SHA256] = checksums[arch]
+	}
+
+	return ret
+}
+
+// matches returns whether the given Windows version falls in the range of this entry.
+func (w *windowsVersionEntry) matches(version platform.Version) (bool, error) {
+	if w.Min != "" {
+		min, err := platform.ParseVersion(w.Min)
+		if err != nil {
+			return false, err
+		}
+
+		if version.Compare(min) < 0 {
+			return false, nil
+		}
+	}
+
+	if w.Max != "" {
+		max, err := platform.ParseVersion(w.Max)
+		if err != nil {
+			return false, err
+		}
+
+		if version.Compare(max) > 0 {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// description returns a human-readable description of the version range.
+func (w *windowsVersionEntry) description() string {
+	switch {
+	case w.Min != "" && w.Max != "":
+		return fmt.Sprintf("Windows %v to %v", w.Min, w.Max)
+	case w.Min != "":
+		return fmt.Sprintf("Windows %v or later", w.Min)
+	case w.Max != "":
+		return fmt.Sprintf("Windows %v or earlier", w.Max)
+	default:
+		return "any Windows version"
+	}
+}
+
+// sources returns the x86 and x86_64 installer sources to use on the given version of Windows.
+// Without a version, the default sources are returned.
+func (s *installerEntry) sources(version *platform.Version) (installerSource, installerSource, error) {
+	x86 := installerSource{"x86", s.X86, mergeHashes(s.Checksums, s.Hashes, "x86"), s.Mirrors["x86"]}
+	x86_64 := installerSource{"x86_64", s.X86_64, mergeHashes(s.Checksums, s.Hashes, "x86_64"), s.Mirrors["x86_64"]}
+
+	if version == nil {
+		return x86, x86_64, nil
+	}
+
+	for _, w := range s.WindowsVersions {
+		matches, err := w.matches(*version)
+		if err != nil {
+			return x86, x86_64, err
+		}
+
+		if matches {
+			if w.X86 != "" {
+				x86 = installerSource{"x86", w.X86, mergeHashes(w.Checksums, w.Hashes, "x86"), nil}
+			}
+			if w.X86_64 != "" {
+				x86_64 = installerSource{"x86_64", w.X86_64, mergeHashes(w.Checksums, w.Hashes, "x86_64"), nil}
+			}
+
+			break
+		}
+	}
+
+	return x86, x86_64, nil
 }
 
 // options returns the architecture-specific options (if available), otherwise returns the whole
@@ -94,8 +307,9 @@ func (s *installerEntry) options(arch string) map[string]interface{} {
 
 // Registry is a list of packages that just-install knows how to install.
 type Registry struct {
-	Version  int
-	Packages map[string]RegistryEntry
+	Version       int
+	SchemaVersion int // Optional, see RegistrySchemaVersion
+	Packages      map[string]RegistryEntry
 }
 
 // SortedPackageNames returns the list of packages present in the registry, sorted alphabetically.
@@ -111,100 +325,839 @@ func (r *Registry) SortedPackageNames() []string {
 	return keys
 }
 
+// Package returns the entry of the given package, or an error matching ErrUnknownPackage if the
+// registry doesn't have it.
+func (r *Registry) Package(name string) (*RegistryEntry, error) {
+	entry, ok := r.Packages[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", ErrUnknownPackage, name)
+	}
+
+	return &entry, nil
+}
+
+// Conflict returns whether the two given packages cannot be installed at the same time, as declared
+// by either of them.
+func (r *Registry) Conflict(a string, b string) bool {
+	declares := func(pkg string, other string) bool {
+		entry, ok := r.Packages[pkg]
+		return ok && dry.StringInSlice(other, entry.Conflicts)
+	}
+
+	return a != b && (declares(a, b) || declares(b, a))
+}
+
 // RegistryEntry is a single entry in the just-install registry.
 type RegistryEntry struct {
-	Version   string
-	Installer installerEntry
-	SkipAudit bool
+	Version           string
+	Installer         installerEntry
+	Category          string       // Optional, what the package is for (i.e. "dev"), for `list --category`
+	Conflicts         []string     // Optional, packages that cannot be installed alongside this one
+	Detect            *detectEntry // Optional, finds installations made without just-install
+	PreInstallChecks  []check      // Optional
+	PostInstallChecks []check      // Optional, evaluated with Options.VerifyAfterInstall
+	Replaces          []string     // Optional, packages superseded by this one
+	SkipAudit         bool
+	Versions          []versionEntry // Optional, other versions that can be installed on request
+
+	baseDir string // Directory of the registry file, relative installer paths are resolved against it
 }
 
-// DownloadInstaller downloads the installer for the current entry in the temporary directory.
-func (e *RegistryEntry) DownloadInstaller(arch string, force bool) string {
-	url, err := e.installerURL(arch)
+// Options that influence DownloadInstaller and JustInstall.
+type Options struct {
+	CacheOnly          bool          // Never use the network, for Plan: resolvers and checksum files that aren't cached are skipped.
+	CleanupAfter       bool          // Remove the installer from the cache once installed successfully.
+	DownloadTimeout    time.Duration // Give up each attempt to download an installer after this long, if not zero.
+	Force              bool          // Re-download the installer even if cached.
+	ForceAssociations  bool          // Register file associations even if the user picked another program.
+	HashAlgorithm      string        // Verify the installer with this algorithm, instead of the strongest available one.
+	Profile            string        // Run the installer with the arguments of this profile, declared in the registry.
+	KillOnCancel       bool          // Kill a running installer when the context is done, instead of waiting for it.
+	MaxRedirects       int           // Give up downloading an installer after this many redirects, fetch.DefaultMaxRedirects if zero.
+	MirrorStrategy     string        // How to pick among the mirrors of an installer, MirrorFirst by default.
+	MSIXIdentity       string        // With Uninstall, the identity of the installed MSIX package, as passed to Identified.
+	NoContentCheck     bool          // Don't reject installers that are obviously not of the kind of the entry (i.e. HTML pages).
+	NoFallbackArch     bool          // Fail instead of falling back to the x86 installer of x86_64-less packages.
+	NoShims            bool          // Don't create shims, even for packages that declare some.
+	Prefix             string        // Install copy and zip packages, and their shims, in this directory instead, see RecordInPrefix.
+	Reinstall          bool          // Don't skip packages already installed outside of just-install, see BeforeInstall.
+	RequireStrongHash  bool          // Refuse installers that can only be verified with a weak algorithm.
+	Segments           int           // Download large installers with this many parallel connections, if supported by the server.
+	VerifyAfterInstall bool          // Evaluate the post-install checks of the entry once installed.
+
+	BeforeInstall    func() error                     // Optional, called once the installer is downloaded and verified, right before running it.
+	DownloadProgress func(written int64, total int64) // Optional, called as installers are downloaded.
+	Identified       func(identity string)            // Optional, called with the identity of MSIX packages once installed.
+	InstallerOutput  io.Writer                        // Optional, receives the standard output and error of installers.
+	Timings          *timing.Recorder                 // Optional, records how long each phase takes.
+	Verified         func(results []CheckResult)      // Optional, called with the results of post-install checks.
+}
+
+// DownloadInstaller downloads the installer for the current entry in the installer cache.
+// Downloads whose size doesn't match the one announced by the server, or whose checksum doesn't
+// match the one in the registry, are rejected. Checksums are verified with the strongest algorithm
+// available, unless `HashAlgorithm` is set. Volatile installers are always downloaded again.
+func (e *RegistryEntry) DownloadInstaller(ctx context.Context, arch string, options *Options) (string, error) {
+	if options == nil {
+		options = &Options{}
+	}
+
+	if err := e.checkWindowsVersion(); err != nil {
+		return "", err
+	}
+
+	endResolve := options.Timings.Start("resolve")
+	if err := e.resolve(ctx); err != nil {
+		endResolve()
+		return "", err
+	}
+
+	source, err := e.installerSource(arch)
+	endResolve()
 	if err != nil {
-		// FIXME: Add proper error handling
-		log.Fatalln("Cannot determine installer URL:", err)
+		return "", fmt.Errorf("cannot determine installer URL: %w", err)
 	}
 
-	downloadDir, err := paths.TempDirCreate()
+	if source.Arch != arch && options.NoFallbackArch {
+		return "", noFallbackError(arch)
+	}
+
+	if err := e.addPublishedHash(ctx, &source, false); err != nil {
+		return "", err
+	}
+
+	if source.Arch != arch {
+		log.Printf("WARNING: no %v installer available, falling back to the %v one", arch, source.Arch)
+	}
+
+	algorithm, err := selectHash(source.Hashes, options)
 	if err != nil {
-		// FIXME: Add proper error handling
-		log.Fatalln("Could not create temporary directory:", err)
+		return "", err
+	}
+
+	if algorithm == "" {
+		log.Println("WARNING: no checksum available, the installer cannot be verified:", source.URL)
 	}
 
-	ret, err := fetch.Fetch(url, &fetch.Options{Destination: downloadDir, Overwrite: force, Progress: true})
+	headers, err := e.headers()
 	if err != nil {
-		log.Fatalln(err)
+		return "", err
 	}
 
-	return ret
+	downloadDir, err := paths.CacheDirCreate()
+	if err != nil {
+		return "", fmt.Errorf("could not create cache directory: %w", err)
+	}
+
+	// Volatile installers change under the same URL, a cached copy may be stale.
+	overwrite := options.Force
+	if e.Installer.Volatile && !overwrite {
+		log.Println("re-downloading volatile installer", source.URL)
+		overwrite = true
+	}
+
+	// Catch error pages and the like, even for installers without a checksum
+	var format *contentFormat
+	if f, ok := e.installerFormat(source.Arch); ok && !options.NoContentCheck {
+		format = &f
+	}
+
+	defer options.Timings.Start("download")()
+
+	urls, err := orderURLs(ctx, append([]string{source.URL}, source.Mirrors...), options.MirrorStrategy)
+	if err != nil {
+		return "", err
+	}
+
+	for i, rawurl := range urls {
+		// Installers downloaded from mirrors are cached under the same name as the original one.
+		destination := downloadDir
+		if rawurl != source.URL {
+			destination = cachedInstallerPath(source.URL)
+		}
+
+		// Local installers are copied to the cache as well, so that a bad file is never deleted from
+		// its original location.
+		fetchOptions := &fetch.Options{
+			Context:      ctx,
+			CopyLocal:    true,
+			Destination:  destination,
+			HTTP:         fetch.HTTPOptions{Headers: headers, Proxy: e.Installer.Proxy},
+			MaxRedirects: options.MaxRedirects,
+			OnProgress:   options.DownloadProgress,
+			Overwrite:    overwrite,
+			Progress:     true,
+			Segments:     options.Segments,
+		}
+
+		ret, err := fetchInstaller(rawurl, fetchOptions, options.DownloadTimeout, format, source.Hashes, algorithm)
+		if err == nil || ctx.Err() != nil || i == len(urls)-1 {
+			return ret, err
+		}
+
+		log.Printf("WARNING: %v, trying %v", err, urls[i+1])
+	}
+
+	return "", nil // Not reached
 }
 
-// JustInstall will download and install the given registry entry. Setting `force` to true will
-// force a re-download and re-installation the package.
-func (e *RegistryEntry) JustInstall(arch string, force bool) error {
-	options := e.Installer.options(arch)
-	downloadedFile := e.DownloadInstaller(arch, force)
+// fetchInstaller downloads the installer at the given URL, checking that it is in the given format
+// and verifying it with the given algorithm (if any). A cached installer that doesn't pass, or that
+// changed since it was downloaded, is considered corrupt and downloaded again, once. Each download
+// fails if it takes longer than timeout, unless it's zero.
+func fetchInstaller(rawurl string, options *fetch.Options, timeout time.Duration, format *contentFormat, hashes map[string]string, algorithm string) (string, error) {
+	cached := options.Destination
+	if dry.FileIsDir(cached) {
+		cached = cachedInstallerPath(rawurl)
+	}
+	wasCached := !options.Overwrite && dry.FileExists(cached)
 
-	if container, ok := options["container"]; ok {
-		tempDir, err := paths.TempDirCreate()
-		if err != nil {
-			return err
+	if wasCached {
+		if err := checkIntegrity(cached, algorithm, hashes[algorithm]); err != nil {
+			log.Printf("WARNING: cached installer %v changed since it was downloaded (%v), downloading it again", cached, err)
+			os.Remove(cached)
+			forgetIntegrity(cached)
+			wasCached = false
+		}
+	}
+
+	ret, err := fetchWithTimeout(rawurl, options, timeout)
+	if err != nil {
+		return "", &DownloadError{rawurl, err}
+	}
+
+	verify := func() error {
+		if format != nil {
+			if err := checkContent(ret, rawurl, *format); err != nil {
+				return err
+			}
+		}
+
+		if algorithm != "" {
+			return checksum.VerifyWith(ret, algorithm, hashes[algorithm])
 		}
-		tempDir = filepath.Join(tempDir, filepath.Base(downloadedFile)+"_extracted")
 
-		if err := installer.ExtractZIP(downloadedFile, tempDir); err != nil {
+		return nil
+	}
+
+	if err := verify(); err != nil {
+		// Don't keep a bad file in the cache, we want to download it again next time.
+		os.Remove(ret)
+		forgetIntegrity(ret)
+
+		if wasCached && ret == cached {
+			log.Printf("WARNING: cached installer %v is corrupt (%v), downloading it again", ret, err)
+
+			fresh := *options
+			fresh.Overwrite = true
+			return fetchInstaller(rawurl, &fresh, timeout, format, hashes, algorithm)
+		}
+
+		return "", err
+	}
+
+	if !wasCached || !dry.FileExists(integrityPath(ret)) {
+		recordIntegrity(ret, rawurl, algorithm, hashes[algorithm])
+	}
+
+	return ret, nil
+}
+
+// fetchWithTimeout is like fetch.Fetch, but fails if the download takes longer than timeout, unless
+// it's zero. A download that times out is never cached as the installer: like any interrupted
+// download, what was received so far is only kept as a `.partial` file, which the next attempt
+// resumes if the server guarantees that the file didn't change since (see fetch.Fetch).
+func fetchWithTimeout(rawurl string, options *fetch.Options, timeout time.Duration) (string, error) {
+	if timeout <= 0 {
+		return fetch.Fetch(rawurl, options)
+	}
+
+	parent := options.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+
+	timed := *options
+	timed.Context = ctx
+
+	ret, err := fetch.Fetch(rawurl, &timed)
+	if err != nil && ctx.Err() == context.DeadlineExceeded && parent.Err() == nil {
+		return "", fmt.Errorf("download took longer than %v (see --download-timeout)", timeout)
+	}
+
+	return ret, err
+}
+
+// CachedInstaller returns the path to the installer for the given architecture, if it is already in
+// the installer cache and its checksum matches the one in the registry. Corrupted installers are
+// removed from the cache. Volatile installers are never considered cached.
+func (e *RegistryEntry) CachedInstaller(ctx context.Context, arch string, options *Options) (string, bool) {
+	if options == nil {
+		options = &Options{}
+	}
+
+	if err := e.resolve(ctx); err != nil {
+		return "", false
+	}
+
+	source, err := e.installerSource(arch)
+	if err != nil || e.Installer.Volatile || (source.Arch != arch && options.NoFallbackArch) {
+		return "", false
+	}
+
+	ret := cachedInstallerPath(source.URL)
+	if !dry.FileExists(ret) {
+		return "", false
+	}
+
+	if err := e.addPublishedHash(ctx, &source, false); err != nil {
+		return "", false
+	}
+
+	// The installer cannot be verified as DownloadInstaller would, which reports why.
+	algorithm, err := selectHash(source.Hashes, options)
+	if err != nil {
+		return "", false
+	}
+
+	if err := checkIntegrity(ret, algorithm, source.Hashes[algorithm]); err != nil {
+		log.Printf("WARNING: removing cached installer %v, it changed since it was downloaded (%v)", ret, err)
+		os.Remove(ret)
+		forgetIntegrity(ret)
+		return "", false
+	}
+
+	if algorithm != "" {
+		if err := checksum.VerifyWith(ret, algorithm, source.Hashes[algorithm]); err != nil {
+			log.Println("WARNING: removing corrupted installer from the cache:", err)
+			os.Remove(ret)
+			forgetIntegrity(ret)
+			return "", false
+		}
+	}
+
+	return ret, true
+}
+
+// JustInstall will download and install the given registry entry. Setting `Force` in the options
+// will force a re-download and re-installation the package. Cancelling the context interrupts the
+// download, while a running installer is only killed if `KillOnCancel` is set.
+func (e *RegistryEntry) JustInstall(ctx context.Context, arch string, options *Options) error {
+	if options == nil {
+		options = &Options{}
+	}
+
+	if err := e.checkPreInstall(); err != nil {
+		return err
+	}
+
+	if options.Prefix != "" && !e.CanInstallInPrefix() {
+		return fmt.Errorf("cannot install %v packages in a prefix, only copy and zip ones", e.Installer.Kind)
+	}
+
+	if !options.Force && !options.Reinstall && options.Prefix == "" {
+		if err := e.checkInstalled(); err != nil {
 			return err
 		}
+	}
 
-		installer := container.(map[string]interface{})["installer"].(string)
-		if err := e.install(arch, filepath.Join(tempDir, installer)); err != nil {
+	if _, _, err := e.containedInstaller(arch); err != nil {
+		return err
+	}
+
+	if _, err := e.profileArguments(arch, options.Profile, ""); err != nil {
+		return err
+	}
+
+	if _, err := e.shims(arch); err != nil && !options.NoShims {
+		return err
+	}
+
+	if err := e.checkDiskSpace(arch); err != nil {
+		return err
+	}
+
+	downloadedFile, err := e.DownloadInstaller(ctx, arch, options)
+	if err != nil {
+		return err
+	}
+
+	installCtx := context.Background()
+	if options.KillOnCancel {
+		installCtx = ctx
+	} else if ctx.Err() != nil {
+		// Don't start installing if we have been cancelled while downloading.
+		return ctx.Err()
+	}
+
+	if options.BeforeInstall != nil {
+		if err := options.BeforeInstall(); err != nil {
 			return err
 		}
-	} else {
-		if err := e.install(arch, downloadedFile); err != nil {
+	}
+
+	endInstall := options.Timings.Start("install")
+	err = e.installDownloaded(installCtx, arch, downloadedFile, options)
+	endInstall()
+	if err != nil {
+		return err
+	}
+
+	if !options.NoShims && options.Prefix != "" {
+		endShim := options.Timings.Start("shim")
+		err := e.createPrefixShims(arch, options.Prefix)
+		endShim()
+		if err != nil {
 			return err
 		}
+	} else if !options.NoShims {
+		endShim := options.Timings.Start("shim")
+		e.CreateShims(arch)
+		endShim()
 	}
 
-	e.CreateShims(arch)
+	// Nothing is registered outside of a prefix, so that it can be moved or removed as a whole.
+	if options.Prefix == "" {
+		e.RegisterAssociations(arch, options.ForceAssociations)
+		e.SetEnvironment(arch, options.Force)
+	}
+
+	if options.VerifyAfterInstall && len(e.PostInstallChecks) > 0 {
+		endVerify := options.Timings.Start("verify")
+		results, err := e.verifyInstall(options.Prefix)
+		endVerify()
+
+		if options.Verified != nil {
+			options.Verified(results)
+		}
+
+		if err != nil {
+			return err // Keep the installer for a retry, even with CleanupAfter
+		}
+	}
+
+	if options.CleanupAfter {
+		removeInstaller(downloadedFile)
+	}
 
 	return nil
 }
 
-func (e *RegistryEntry) installerURL(arch string) (string, error) {
-	var url string
+// removeInstaller removes the given installer from the installer cache, along with the directory it
+// has been extracted to, if any.
+func removeInstaller(downloadedFile string) {
+	log.Println("removing", downloadedFile, "from the installer cache")
+	if err := os.Remove(downloadedFile); err != nil {
+		log.Println("WARNING: could not remove installer:", err)
+	}
+	forgetIntegrity(downloadedFile)
+
+	if dir, err := extractionDir(downloadedFile); err == nil && dry.FileIsDir(dir) {
+		if err := os.RemoveAll(dir); err != nil {
+			log.Println("WARNING: could not remove extracted installer:", err)
+		}
+	}
+}
+
+// extractionDir returns the temporary directory where the given archive containing an installer is
+// extracted, creating just-install's temporary directory if missing.
+func extractionDir(downloadedFile string) (string, error) {
+	if _, err := paths.TempDirCreate(); err != nil {
+		return "", err
+	}
+
+	return extractionPath(downloadedFile), nil
+}
+
+// extractionPath is the same as extractionDir() but doesn't create anything.
+func extractionPath(downloadedFile string) string {
+	return filepath.Join(paths.TempDir(), filepath.Base(downloadedFile)+"_extracted")
+}
+
+// installDownloaded installs the downloaded installer, extracting it first if it is contained in an
+// archive.
+func (e *RegistryEntry) installDownloaded(ctx context.Context, arch string, downloadedFile string, options *Options) error {
+	inner, ok, err := e.containedInstaller(arch)
+	if err != nil {
+		return err
+	} else if !ok {
+		return e.install(ctx, arch, downloadedFile, options)
+	}
+
+	tempDir, err := extractionDir(downloadedFile)
+	if err != nil {
+		return err
+	}
+
+	// Don't run a stale installer left behind by a previous extraction.
+	if err := os.RemoveAll(tempDir); err != nil {
+		return err
+	}
+
+	if err := installer.ExtractZIP(downloadedFile, tempDir); err != nil {
+		return err
+	}
+
+	installerPath := filepath.Join(tempDir, inner)
+	if !dry.FileExists(installerPath) {
+		return fmt.Errorf("%v does not contain %v", filepath.Base(downloadedFile), inner)
+	}
+
+	return e.install(ctx, arch, installerPath, options)
+}
+
+// dependencies returns the URLs of the packages the installer depends on, with placeholders
+// expanded.
+func (e *RegistryEntry) dependencies(arch string) []string {
+	var ret []string
+	if dependencies, ok := e.Installer.options(arch)["dependencies"].([]interface{}); ok {
+		for _, v := range dependencies {
+			ret = append(ret, e.expandURL(v.(string)))
+		}
+	}
+
+	return ret
+}
+
+// fetchDependencies downloads the packages the installer depends on to the installer cache, unless
+// already there, returning their paths.
+func (e *RegistryEntry) fetchDependencies(ctx context.Context, arch string) ([]string, error) {
+	cacheDir, err := paths.CacheDirCreate()
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []string
+	for _, rawurl := range e.dependencies(arch) {
+		path := cachedInstallerPath(rawurl)
+		if !dry.FileExists(path) {
+			log.Println("downloading dependency", rawurl)
+
+			if path, err = fetch.Fetch(rawurl, &fetch.Options{Context: ctx, CopyLocal: true, Destination: cacheDir, Progress: true}); err != nil {
+				return nil, fmt.Errorf("cannot download dependency: %w", err)
+			}
+		}
+
+		ret = append(ret, path)
+	}
+
+	return ret, nil
+}
+
+// containedInstaller returns the path of the installer within the downloaded archive, for packages
+// whose installer is distributed in a ZIP archive.
+func (e *RegistryEntry) containedInstaller(arch string) (string, bool, error) {
+	container, ok := e.Installer.options(arch)["container"]
+	if !ok {
+		return "", false, nil
+	}
+
+	inner, _ := container.(map[string]interface{})["installer"].(string)
+	if inner == "" {
+		return "", false, errors.New("the container option requires the path of the installer in the archive")
+	}
+
+	inner = filepath.Clean(filepath.FromSlash(inner))
+	if filepath.IsAbs(inner) || inner == ".." || strings.HasPrefix(inner, ".."+string(filepath.Separator)) {
+		return "", false, fmt.Errorf("the installer in the archive must be a relative path: %v", inner)
+	}
+
+	return inner, true, nil
+}
+
+// selectHash returns the algorithm to verify an installer with the given digests with, or an empty
+// string if it cannot be verified.
+func selectHash(hashes map[string]string, options *Options) (string, error) {
+	algorithm := checksum.Strongest(hashes)
+
+	if options.HashAlgorithm != "" {
+		algorithm = strings.ToLower(options.HashAlgorithm)
+		if hashes[algorithm] == "" {
+			return "", fmt.Errorf("the registry has no %v checksum for this installer", algorithm)
+		}
+	}
+
+	if algorithm != "" && checksum.Weak(algorithm) {
+		if options.RequireStrongHash {
+			return "", fmt.Errorf("this installer can only be verified with the weak %v algorithm", algorithm)
+		}
+
+		log.Printf("WARNING: this installer can only be verified with the weak %v algorithm", algorithm)
+	}
+
+	return algorithm, nil
+}
+
+// installerSource returns where to download the installer for the given architecture from, with
+// placeholders in its URL expanded.
+func (e *RegistryEntry) installerSource(arch string) (installerSource, error) {
+	// Without knowing which Windows version we are on we can only use the default URLs.
+	var version *platform.Version
+	if v, err := platform.WindowsVersion(); err == nil {
+		version = &v
+	}
+
+	x86, x86_64, err := e.Installer.sources(version)
+	if err != nil {
+		return installerSource{}, err
+	}
+
+	var ret installerSource
 
 	if arch == "x86_64" {
-		if e.Installer.X86_64 != "" {
-			url = e.Installer.X86_64
-		} else if e.Installer.X86 != "" {
-			url = e.Installer.X86
+		if x86_64.URL != "" {
+			ret = x86_64
+		} else if x86.URL != "" {
+			ret = x86
 		} else {
-			return "", errors.New("no fallback 32-bit download")
+			return ret, fmt.Errorf("%w %v: no fallback 32-bit download", ErrUnsupportedArch, arch)
 		}
 	} else if arch == "x86" {
-		if e.Installer.X86 != "" {
-			url = e.Installer.X86
+		if x86.URL != "" {
+			ret = x86
 		} else {
-			return "", errors.New("64-bit only package")
+			return ret, fmt.Errorf("%w %v: 64-bit only package", ErrUnsupportedArch, arch)
 		}
 	} else {
-		return "", errors.New("unknown architecture")
+		return ret, fmt.Errorf("%w %v", ErrUnsupportedArch, arch)
+	}
+
+	for _, rawurl := range append([]string{ret.URL}, ret.Mirrors...) {
+		if _, err := expandStringStrict(rawurl, map[string]string{"version": e.Version}); err != nil {
+			return ret, fmt.Errorf("cannot expand installer URL %v: %w", rawurl, err)
+		}
+	}
+
+	ret.URL = e.expandURL(ret.URL)
+
+	mirrors := make([]string, len(ret.Mirrors))
+	for i, mirror := range ret.Mirrors {
+		mirrors[i] = e.expandURL(mirror)
+	}
+	ret.Mirrors = mirrors
+
+	return ret, nil
+}
+
+// noFallbackError is returned when a package has no installer for the given architecture and falling
+// back to the x86 one has been disabled.
+func noFallbackError(arch string) error {
+	return fmt.Errorf("%w %v: no 64-bit download, not falling back to the 32-bit one", ErrUnsupportedArch, arch)
+}
+
+// checkDiskSpace returns an error if there isn't the free disk space required by the entry, both in
+// the installer cache and where the package is installed.
+func (e *RegistryEntry) checkDiskSpace(arch string) error {
+	if e.Installer.RequiredDiskSpace <= 0 {
+		return nil
+	}
+
+	cacheDir, err := paths.CacheDirCreate()
+	if err != nil {
+		return err
+	}
+
+	dirs := []string{cacheDir}
+	if e.Installer.Kind == "copy" || e.Installer.Kind == "zip" {
+		dirs = append(dirs, e.destination(arch))
+	} else if programFiles := os.Getenv("ProgramFiles"); programFiles != "" {
+		dirs = append(dirs, programFiles)
+	}
+
+	required := uint64(e.Installer.RequiredDiskSpace) * 1024 * 1024
+	for _, dir := range dirs {
+		// The destination might not exist yet, check the volume it will be created on.
+		for !dry.FileExists(dir) && filepath.Dir(dir) != dir {
+			dir = filepath.Dir(dir)
+		}
+
+		available, err := platform.FreeDiskSpace(dir)
+		if err != nil {
+			log.Println("cannot determine free disk space, assuming there is enough:", err)
+			continue
+		}
+
+		if available < required {
+			return fmt.Errorf("this package requires about %v MiB of free disk space, but only %v MiB are available for %v", e.Installer.RequiredDiskSpace, available/1024/1024, dir)
+		}
+	}
+
+	return nil
+}
+
+// checkWindowsVersion returns an error if the package requires a newer version of Windows than the
+// one we are running on.
+func (e *RegistryEntry) checkWindowsVersion() error {
+	if e.Installer.MinWindowsVersion == "" {
+		return nil
+	}
+
+	min, err := platform.ParseVersion(e.Installer.MinWindowsVersion)
+	if err != nil {
+		return err
+	}
+
+	current, err := platform.WindowsVersion()
+	if err != nil {
+		log.Println("cannot determine Windows version, assuming it is supported:", err)
+		return nil
+	}
+
+	if current.Compare(min) < 0 {
+		return fmt.Errorf("this package requires Windows %v or later, but this machine runs Windows %v", min, current)
+	}
+
+	return nil
+}
+
+// InstallerURL is one of the URLs the installer of a package can be downloaded from.
+type InstallerURL struct {
+	Description  string // i.e. "x86_64" or "x86_64, Windows 10.0.22000 or later"
+	URL          string
+	Checksum     string            // Hex-encoded SHA-256 digest, if known
+	ChecksumPath string            // Where the checksum is stored in the package entry, i.e. "installer.checksums.x86"
+	Hashes       map[string]string // All known digests, algorithm -> hex-encoded digest, including Checksum
+	Mirrors      []string          // Alternative URLs of the same installer, with placeholders expanded
+}
+
+// InstallerURLs returns all the installer URLs of the entry, including those for specific Windows
+// versions and for the other `versions` of the package, with placeholders expanded.
+func (e *RegistryEntry) InstallerURLs() []InstallerURL {
+	var ret []InstallerURL
+
+	add := func(description string, url string, checksums map[string]string, hashes map[string]map[string]string, arch string, checksumPath string) {
+		if url != "" {
+			ret = append(ret, InstallerURL{description, e.expandURL(url), checksums[arch], checksumPath + arch, mergeHashes(checksums, hashes, arch), nil})
+		}
+	}
+
+	add("x86", e.Installer.X86, e.Installer.Checksums, e.Installer.Hashes, "x86", "installer.checksums.")
+	add("x86_64", e.Installer.X86_64, e.Installer.Checksums, e.Installer.Hashes, "x86_64", "installer.checksums.")
+
+	// Mirrors don't apply to the URLs for specific Windows versions.
+	for i := range ret {
+		for _, mirror := range e.Installer.Mirrors[ret[i].Description] {
+			ret[i].Mirrors = append(ret[i].Mirrors, e.expandURL(mirror))
+		}
+	}
+
+	for i, w := range e.Installer.WindowsVersions {
+		checksumPath := fmt.Sprintf("installer.windowsVersions.%d.checksums.", i)
+
+		add("x86, "+w.description(), w.X86, w.Checksums, w.Hashes, "x86", checksumPath)
+		add("x86_64, "+w.description(), w.X86_64, w.Checksums, w.Hashes, "x86_64", checksumPath)
+	}
+
+	// Other versions have their own checksums, even when downloaded from the same URLs.
+	for i, v := range e.Versions {
+		pinned, err := e.AtVersion(v.Version)
+		if err != nil {
+			continue
+		}
+
+		checksumPath := fmt.Sprintf("versions.%d.checksums.", i)
+		for _, arch := range []string{"x86", "x86_64"} {
+			url := pinned.Installer.X86
+			if arch == "x86_64" {
+				url = pinned.Installer.X86_64
+			}
+
+			if url == "" {
+				continue
+			}
+
+			u := InstallerURL{arch + ", version " + v.Version, pinned.expandURL(url), v.Checksums[arch], checksumPath + arch, mergeHashes(v.Checksums, v.Hashes, arch), nil}
+			for _, mirror := range pinned.Installer.Mirrors[arch] {
+				u.Mirrors = append(u.Mirrors, pinned.expandURL(mirror))
+			}
+
+			ret = append(ret, u)
+		}
+	}
+
+	return ret
+}
+
+// Architectures returns the architectures for which the entry has an installer.
+func (e *RegistryEntry) Architectures() []string {
+	hasX86 := e.Installer.X86 != ""
+	hasX86_64 := e.Installer.X86_64 != ""
+
+	for _, w := range e.Installer.WindowsVersions {
+		hasX86 = hasX86 || w.X86 != ""
+		hasX86_64 = hasX86_64 || w.X86_64 != ""
 	}
 
-	return e.ExpandString(url), nil
+	if r := e.Installer.Resolver; r != nil {
+		// The built-in resolvers take a parameter for each architecture
+		hasX86 = hasX86 || r.Params["x86"] != ""
+		hasX86_64 = hasX86_64 || r.Params["x86_64"] != ""
+	}
+
+	var ret []string
+	if hasX86 {
+		ret = append(ret, "x86")
+	}
+	if hasX86_64 {
+		ret = append(ret, "x86_64")
+	}
+
+	return ret
+}
+
+// ParallelSafe returns whether the entry can be installed while other packages are being installed.
+// Only packages that declare so and are copied or extracted by just-install itself qualify, since
+// running installers can step on each other (i.e. concurrent MSI installations fail).
+func (e *RegistryEntry) ParallelSafe() bool {
+	return e.Installer.ParallelSafe && (e.Installer.Kind == "copy" || e.Installer.Kind == "zip")
 }
 
 func (e *RegistryEntry) ExpandString(s string) string {
 	return expandString(s, map[string]string{"version": e.Version})
 }
 
-func (e *RegistryEntry) install(arch string, path string) error {
+// headers returns the extra HTTP headers to send when downloading the installer, with placeholders
+// in their values expanded. Values obtained with `env` are redacted from logs, like in arguments.
+func (e *RegistryEntry) headers() (map[string]string, error) {
+	if len(e.Installer.Headers) == 0 {
+		return nil, nil
+	}
+
+	ret := make(map[string]string)
+	for name, value := range e.Installer.Headers {
+		expanded, err := expandStringStrict(value, map[string]string{"version": e.Version})
+		if err != nil {
+			return nil, fmt.Errorf("cannot expand header %v: %w", name, err)
+		}
+
+		ret[name] = expanded
+	}
+
+	return ret, nil
+}
+
+// expandURL expands placeholders in the given installer URL, resolving relative paths against the
+// directory of the registry file.
+func (e *RegistryEntry) expandURL(rawurl string) string {
+	ret := e.ExpandString(rawurl)
+
+	if e.baseDir == "" || strings.Contains(ret, "://") || strings.HasPrefix(ret, `\\`) || filepath.IsAbs(ret) || filepath.VolumeName(ret) != "" {
+		return ret
+	}
+
+	return filepath.Join(e.baseDir, filepath.FromSlash(ret))
+}
+
+func (e *RegistryEntry) install(ctx context.Context, arch string, path string, options *Options) error {
+	out := options.InstallerOutput
+
 	// One-off, custom, installers
 	switch e.Installer.Kind {
 	case "copy":
-		destination := e.destination(arch)
+		destination := e.installDestination(arch, options.Prefix)
 
 		parentDir := filepath.Dir(destination)
 		log.Println("creating", parentDir)
@@ -215,21 +1168,21 @@ func (e *RegistryEntry) install(arch string, path string) error {
 		log.Println("copying to", destination)
 		return dry.FileCopy(path, destination)
 	case "custom":
-		var args []string
-
-		for _, v := range e.Installer.options(arch)["arguments"].([]interface{}) {
-			args = append(args, expandString(v.(string), map[string]string{"installer": path}))
+		args, err := e.commandLine(arch, platform.ShortPath(path), options.Profile)
+		if err != nil {
+			return err
 		}
 
-		return cmd.Run(args...)
+		return runInstaller(ctx, out, args)
 	case "zip":
-		log.Println("extracting to", e.destination(arch))
+		destination := e.installDestination(arch, options.Prefix)
+		log.Println("extracting to", destination)
 
-		if err := installer.ExtractZIP(path, e.destination(arch)); err != nil {
+		if err := installer.ExtractZIP(path, destination); err != nil {
 			return err
 		}
 
-		if shortcuts, prs := e.Installer.options(arch)["shortcuts"]; prs {
+		if shortcuts, prs := e.Installer.options(arch)["shortcuts"]; prs && options.Prefix == "" {
 			for _, shortcut := range shortcuts.([]interface{}) {
 				shortcutName := expandString(shortcut.(map[string]interface{})["name"].(string), nil)
 				shortcutTarget := expandString(os.ExpandEnv(shortcut.(map[string]interface{})["target"].(string)), nil)
@@ -246,30 +1199,130 @@ func (e *RegistryEntry) install(arch string, path string) error {
 		return nil
 	}
 
-	// Regular installer
+	// Regular installer. Many installers (and Windows itself, when starting them) can't cope with
+	// long paths, i.e. in the cache of a user with a long name.
+	path = platform.ShortPath(path)
+
+	if e.Installer.Kind == string(installer.MSIX) {
+		dependencies, err := e.fetchDependencies(ctx, arch)
+		if err != nil {
+			return err
+		}
+
+		if err := runInstaller(ctx, out, installer.MSIXCommand(path, dependencies)); err != nil {
+			return err
+		}
+
+		if options.Identified != nil {
+			identity, err := installer.MSIXName(path)
+			if err != nil {
+				log.Println("WARNING: could not read the identity of the installed package:", err)
+			} else {
+				options.Identified(identity)
+			}
+		}
+
+		return nil
+	}
+
+	installerCommand, err := e.commandLine(arch, path, options.Profile)
+	if err != nil {
+		return err
+	}
+
+	return runInstaller(ctx, out, installerCommand)
+}
+
+// commandLine returns the command line needed to run the installer at the given path, for
+// installer kinds that run a command, with the arguments of the given profile (if any) at the end.
+func (e *RegistryEntry) commandLine(arch string, path string, profile string) ([]string, error) {
+	profileArgs, err := e.profileArguments(arch, profile, path)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.Installer.Kind == "custom" {
+		var args []string
+
+		for _, v := range e.Installer.options(arch)["arguments"].([]interface{}) {
+			arg, err := expandStringStrict(v.(string), map[string]string{"installer": path})
+			if err != nil {
+				return nil, fmt.Errorf("cannot expand installer argument: %w", err)
+			}
+
+			args = append(args, arg)
+		}
+
+		return append(args, profileArgs...), nil
+	}
+
 	installerType := installer.InstallerType(e.Installer.Kind)
 	if !installerType.IsValid() {
-		return fmt.Errorf("unknown installer type: %v", e.Installer.Kind)
+		return nil, fmt.Errorf("unknown installer type: %v", e.Installer.Kind)
 	}
 
-	installerCommand, err := installer.Command(path, installerType)
+	args, err := installer.Command(path, installerType)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	return append(args, profileArgs...), nil
+}
+
+// profileArguments returns the installer arguments of the given profile, declared in the "profiles"
+// option as lists of arguments by name, with placeholders expanded. No profile has no arguments.
+func (e *RegistryEntry) profileArguments(arch string, profile string, path string) ([]string, error) {
+	if profile == "" {
+		return nil, nil
+	}
+
+	profiles, _ := e.Installer.options(arch)["profiles"].(map[string]interface{})
+	declared, ok := profiles[profile].([]interface{})
+	if !ok {
+		var names []string
+		for name := range profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		if len(names) == 0 {
+			return nil, fmt.Errorf("%w %q: the package has no installer profiles", ErrUnknownProfile, profile)
+		}
+
+		return nil, fmt.Errorf("%w %q, available profiles are: %v", ErrUnknownProfile, profile, strings.Join(names, ", "))
+	}
+
+	var ret []string
+	for _, v := range declared {
+		arg, err := expandStringStrict(v.(string), map[string]string{"installer": path})
+		if err != nil {
+			return nil, fmt.Errorf("cannot expand argument of profile %v: %w", profile, err)
+		}
+
+		ret = append(ret, arg)
 	}
 
-	return cmd.Run(installerCommand...)
+	return ret, nil
 }
 
 func (e *RegistryEntry) destination(arch string) string {
 	return expandString(os.ExpandEnv(e.Installer.options(arch)["destination"].(string)), nil)
 }
 
+// CreateShims creates the shims declared by the entry, if exeproxy is installed. Shims with
+// arguments are batch files running their target.
 func (e *RegistryEntry) CreateShims(arch string) {
 	exeproxy := os.ExpandEnv("${ProgramFiles(x86)}\\exeproxy\\exeproxy.exe")
 	if !dry.FileExists(exeproxy) {
 		return
 	}
 
+	shims, err := e.shims(arch)
+	if err != nil {
+		log.Println("WARNING: not creating shims:", err)
+		return
+	}
+
 	if !dry.FileIsDir(shimsPath) {
 		if err := os.MkdirAll(shimsPath, 0); err != nil {
 			// FIXME: add proper error handling
@@ -277,10 +1330,9 @@ func (e *RegistryEntry) CreateShims(arch string) {
 		}
 	}
 
-	if shims, ok := e.Installer.options(arch)["shims"]; ok {
-		for _, v := range shims.([]interface{}) {
-			shimTarget := e.ExpandString(v.(string))
-			shim := filepath.Join(shimsPath, filepath.Base(shimTarget))
+	for _, s := range shims {
+		for _, shimTarget := range shimTargets(s.Target) {
+			shim := filepath.Join(shimsPath, s.fileName(shimTarget))
 
 			if dry.FileExists(shim) {
 				os.Remove(shim)
@@ -288,10 +1340,49 @@ func (e *RegistryEntry) CreateShims(arch string) {
 
 			log.Printf("creating shim for %s (%s)\n", shimTarget, shim)
 
-			if err := cmd.Run(exeproxy, "exeproxy-copy", shim, shimTarget); err != nil {
+			if len(s.Args) > 0 {
+				err = s.writeBatch(shim, shimTarget)
+			} else {
+				err = cmd.Run(exeproxy, "exeproxy-copy", shim, shimTarget)
+			}
+			if err != nil {
 				// FIXME: add proper error handling
 				log.Fatalln("could not create shim:", err)
 			}
 		}
 	}
 }
+
+// shimTargets returns the executables matching the given shim target, which can also be a glob
+// pattern (i.e. for portable packages that ship many executables). Executables that the installer
+// didn't create, i.e. optional components, are skipped with a warning rather than getting a broken
+// shim.
+func shimTargets(pattern string) []string {
+	if !strings.ContainsAny(pattern, "*?[") {
+		if !dry.FileExists(pattern) {
+			log.Println("WARNING: not creating shim for missing executable", pattern)
+			return nil
+		}
+
+		return []string{pattern}
+	}
+
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		log.Println("WARNING: invalid shim pattern", pattern+":", err)
+		return nil
+	}
+
+	var ret []string
+	for _, match := range matches {
+		if dry.FileExists(match) && !dry.FileIsDir(match) {
+			ret = append(ret, match)
+		}
+	}
+
+	if len(ret) == 0 {
+		log.Println("WARNING: no executables match shim pattern", pattern)
+	}
+
+	return ret
+}
@@ -0,0 +1,111 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ungerik/go-dry"
+
+	"github.com/just-install/just-install/pkg/paths"
+)
+
+func TestCachedInstallerWithoutRequestedHash(t *testing.T) {
+	dir, err := ioutil.TempDir("", "just-install-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	previous, set := os.LookupEnv(paths.CacheDirEnv)
+	os.Setenv(paths.CacheDirEnv, dir)
+	defer func() {
+		if set {
+			os.Setenv(paths.CacheDirEnv, previous)
+		} else {
+			os.Unsetenv(paths.CacheDirEnv)
+		}
+	}()
+
+	content := []byte("installer")
+	digest := sha256.Sum256(content)
+
+	entry := RegistryEntry{Installer: installerEntry{
+		Kind:   "as-is",
+		Hashes: map[string]map[string]string{"x86_64": {"sha256": hex.EncodeToString(digest[:])}},
+		X86_64: "https://example.com/setup.exe",
+	}}
+
+	path := cachedInstallerPath(entry.Installer.X86_64)
+	if err := ioutil.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := entry.CachedInstaller(context.Background(), "x86_64", &Options{HashAlgorithm: "sha512"}); ok {
+		t.Error("expected the installer not to be cached without a sha512 checksum")
+	}
+
+	if !dry.FileExists(path) {
+		t.Fatal("removed a valid cached installer")
+	}
+
+	if cached, ok := entry.CachedInstaller(context.Background(), "x86_64", &Options{}); !ok || cached != path {
+		t.Errorf("expected the installer cached at %v, got %v", path, cached)
+	}
+}
+
+func TestExplainContainedInstallerPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "just-install-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	previous, set := os.LookupEnv(paths.CacheDirEnv)
+	os.Setenv(paths.CacheDirEnv, dir)
+	defer func() {
+		if set {
+			os.Setenv(paths.CacheDirEnv, previous)
+		} else {
+			os.Unsetenv(paths.CacheDirEnv)
+		}
+	}()
+
+	entry := RegistryEntry{Installer: installerEntry{
+		Kind:    "nsis",
+		Options: map[string]interface{}{"container": map[string]interface{}{"installer": "bin/setup.exe"}},
+		X86_64:  "https://example.com/setup.zip",
+	}}
+
+	expected := filepath.Join(paths.TempDir(), "setup.zip_extracted", "bin", "setup.exe")
+	for _, line := range entry.Explain("x86_64", nil) {
+		if strings.HasPrefix(line, "would run ") {
+			if !strings.Contains(line, expected) {
+				t.Errorf("expected %q to run %v", line, expected)
+			}
+			return
+		}
+	}
+
+	t.Error("expected the command that would run")
+}
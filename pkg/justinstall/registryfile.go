@@ -0,0 +1,326 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// RegistryFile is a registry file loaded as a generic JSON document, so that it can be modified and
+// written back without losing anything we don't know about.
+type RegistryFile struct {
+	data []byte // The file with the changes made with Set, nil for YAML files
+	tree map[string]interface{}
+	yaml bool // Whether the file is written in YAML
+}
+
+// ReadRegistryFile reads the registry file at the given path.
+func ReadRegistryFile(path string) (*RegistryFile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	converted, isYAML, err := registryJSON(data, path)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse %v: %w", path, err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(converted))
+	decoder.UseNumber() // Don't lose precision on numbers
+
+	var tree map[string]interface{}
+	if err := decoder.Decode(&tree); err != nil {
+		return nil, fmt.Errorf("could not parse %v: %w", path, err)
+	}
+
+	if isYAML {
+		data = nil
+	}
+
+	return &RegistryFile{data, tree, isYAML}, nil
+}
+
+// Set sets the value at the given dot-separated path (i.e. "installer.checksums.x86") below the
+// entry of the given package. Missing objects along the path are created, list elements are
+// addressed by their index. Keys are matched case-insensitively against existing ones.
+func (f *RegistryFile) Set(pkg string, path string, value interface{}) error {
+	if err := f.set(pkg, path, value); err != nil {
+		return err
+	}
+
+	if f.data == nil {
+		return nil
+	}
+
+	data, err := editJSON(f.data, append([]string{matchKey(f.tree, "packages"), pkg}, strings.Split(path, ".")...), value)
+	if err != nil {
+		return err
+	}
+	f.data = data
+
+	return nil
+}
+
+// set is like Set, but only changes the parsed tree.
+func (f *RegistryFile) set(pkg string, path string, value interface{}) error {
+	packages, ok := lookupKey(f.tree, "packages").(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("registry file has no packages")
+	}
+
+	var node interface{} = packages
+	components := append([]string{pkg}, strings.Split(path, ".")...)
+
+	for i, component := range components {
+		last := i == len(components)-1
+
+		switch n := node.(type) {
+		case map[string]interface{}:
+			key := matchKey(n, component)
+
+			if last {
+				n[key] = value
+				return nil
+			}
+
+			if _, ok := n[key]; !ok {
+				if i == 0 {
+					return fmt.Errorf("%w: %v", ErrUnknownPackage, pkg)
+				}
+
+				n[key] = make(map[string]interface{})
+			}
+
+			node = n[key]
+		case []interface{}:
+			index, err := strconv.Atoi(component)
+			if err != nil || index < 0 || index >= len(n) {
+				return fmt.Errorf("invalid index %v in %v", component, path)
+			}
+
+			if last {
+				n[index] = value
+				return nil
+			}
+
+			node = n[index]
+		default:
+			return fmt.Errorf("cannot set %v: %v is not an object or a list", path, strings.Join(components[1:i+1], "."))
+		}
+	}
+
+	return nil
+}
+
+// ApplyOverlay applies a change in the form "package.path=value" (i.e.
+// "firefox.installer.x86_64=https://example.com/setup.exe"), where path is the same as in Set. The
+// value is taken as a string, unless it is `true`, `false`, `null` or a JSON object or list. Unlike
+// Set, objects along the path must already exist and the resulting package entry must only contain
+// known fields, so that typos are reported instead of being silently ignored.
+func (f *RegistryFile) ApplyOverlay(overlay string) error {
+	eq := strings.Index(overlay, "=")
+	if eq < 0 {
+		return fmt.Errorf("invalid overlay %v: expected package.path=value", overlay)
+	}
+
+	target, rawValue := overlay[:eq], overlay[eq+1:]
+
+	packages, ok := lookupKey(f.tree, "packages").(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("registry file has no packages")
+	}
+
+	// Package names can contain dots themselves, pick the longest one that matches.
+	pkg, path := "", ""
+	for i := len(target) - 1; i > 0; i-- {
+		if target[i] != '.' {
+			continue
+		}
+
+		if _, ok := packages[target[:i]]; ok {
+			pkg, path = target[:i], target[i+1:]
+			break
+		}
+	}
+
+	if pkg == "" {
+		return fmt.Errorf("invalid overlay %v: %w", overlay, ErrUnknownPackage)
+	}
+
+	var value interface{} = rawValue
+	switch trimmed := strings.TrimSpace(rawValue); {
+	case trimmed == "true", trimmed == "false", trimmed == "null", strings.HasPrefix(trimmed, "["), strings.HasPrefix(trimmed, "{"):
+		if err := json.Unmarshal([]byte(trimmed), &value); err != nil {
+			return fmt.Errorf("invalid overlay %v: %w", overlay, err)
+		}
+	}
+
+	if err := f.checkOverlayPath(packages[pkg], path); err != nil {
+		return fmt.Errorf("invalid overlay %v: %w", overlay, err)
+	}
+
+	if err := f.Set(pkg, path, value); err != nil {
+		return fmt.Errorf("invalid overlay %v: %w", overlay, err)
+	}
+
+	// Make sure that the entry is still valid
+	data, err := json.Marshal(packages[pkg])
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var entry RegistryEntry
+	if err := decoder.Decode(&entry); err != nil {
+		return fmt.Errorf("invalid overlay %v: %w", overlay, err)
+	}
+
+	return nil
+}
+
+// checkOverlayPath returns an error if any object along the given dot-separated path, except for
+// the last component, doesn't exist below the given node.
+func (f *RegistryFile) checkOverlayPath(node interface{}, path string) error {
+	components := strings.Split(path, ".")
+
+	for i, component := range components[:len(components)-1] {
+		switch n := node.(type) {
+		case map[string]interface{}:
+			key := matchKey(n, component)
+			if _, ok := n[key]; !ok {
+				return fmt.Errorf("%v does not exist", strings.Join(components[:i+1], "."))
+			}
+
+			node = n[key]
+		case []interface{}:
+			// Set checks indexes
+			return nil
+		default:
+			return fmt.Errorf("%v is not an object or a list", strings.Join(components[:i], "."))
+		}
+	}
+
+	return nil
+}
+
+// Extract returns a registry file with only the entries of the given packages, and everything else
+// at the top level (i.e. the version) taken from this one. Entries are shared, not copied.
+func (f *RegistryFile) Extract(names ...string) (*RegistryFile, error) {
+	packages, ok := lookupKey(f.tree, "packages").(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("registry file has no packages")
+	}
+
+	tree := make(map[string]interface{}, len(f.tree))
+	for k, v := range f.tree {
+		tree[k] = v
+	}
+
+	extracted := make(map[string]interface{}, len(names))
+	for _, name := range names {
+		entry, ok := packages[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %v", ErrUnknownPackage, name)
+		}
+
+		extracted[name] = entry
+	}
+
+	tree[matchKey(f.tree, "packages")] = extracted
+
+	return &RegistryFile{nil, tree, f.yaml}, nil
+}
+
+// EntryBytes returns the canonical representation (see Bytes) of the entry of the given package.
+func (f *RegistryFile) EntryBytes(pkg string) ([]byte, error) {
+	packages, ok := lookupKey(f.tree, "packages").(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("registry file has no packages")
+	}
+
+	entry, ok := packages[pkg]
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", ErrUnknownPackage, pkg)
+	}
+
+	return canonicalJSON(entry)
+}
+
+// Bytes returns the canonical representation of the registry file: keys sorted alphabetically and
+// two-space indentation.
+func (f *RegistryFile) Bytes() ([]byte, error) {
+	return canonicalJSON(f.tree)
+}
+
+// Write writes the registry file to the given path, with the changes made with Set. Everything else
+// is written as it was read, so that the formatting of the file is preserved. Files written in YAML
+// are refused, since they would be overwritten with JSON, losing comments and formatting.
+func (f *RegistryFile) Write(path string) error {
+	if f.yaml {
+		return errors.New("writing YAML registry files is not supported, update the file by hand")
+	}
+
+	if f.data == nil {
+		return errors.New("cannot write an extracted registry file")
+	}
+
+	return ioutil.WriteFile(path, f.data, 0644)
+}
+
+// canonicalJSON encodes the given value with keys sorted alphabetically and two-space indentation.
+func canonicalJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// lookupKey returns the value of the given key in the map, matching it case-insensitively.
+func lookupKey(m map[string]interface{}, key string) interface{} {
+	return m[matchKey(m, key)]
+}
+
+// matchKey returns the existing key in the map that case-insensitively matches the given one. If
+// there's no such key, the given one is returned as-is.
+func matchKey(m map[string]interface{}, key string) string {
+	if _, ok := m[key]; ok {
+		return key
+	}
+
+	for k := range m {
+		if strings.EqualFold(k, key) {
+			return k
+		}
+	}
+
+	return key
+}
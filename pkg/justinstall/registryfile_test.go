@@ -0,0 +1,116 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRegistryFileWriteKeepsFormatting(t *testing.T) {
+	// Tab indentation, keys in no particular order and a compact object, unlike the canonical form
+	original := "{\n" +
+		"\t\"version\": 4,\n" +
+		"\t\"packages\": {\n" +
+		"\t\t\"zip\": {\n" +
+		"\t\t\t\"version\": \"1.0\",\n" +
+		"\t\t\t\"installer\": {\n" +
+		"\t\t\t\t\"x86\": \"https://example.com/zip.exe\",\n" +
+		"\t\t\t\t\"kind\": \"as-is\",\n" +
+		"\t\t\t\t\"checksums\": {\"x86\": \"old\"}\n" +
+		"\t\t\t}\n" +
+		"\t\t},\n" +
+		"\t\t\"app\": {\n" +
+		"\t\t\t\"version\": \"2.0\",\n" +
+		"\t\t\t\"installer\": {\n" +
+		"\t\t\t\t\"kind\": \"msi\",\n" +
+		"\t\t\t\t\"x86\": \"https://example.com/app-x86.msi\",\n" +
+		"\t\t\t\t\"x86_64\": \"https://example.com/app-x64.msi\"\n" +
+		"\t\t\t}\n" +
+		"\t\t}\n" +
+		"\t}\n" +
+		"}\n"
+
+	expected := "{\n" +
+		"\t\"version\": 4,\n" +
+		"\t\"packages\": {\n" +
+		"\t\t\"zip\": {\n" +
+		"\t\t\t\"version\": \"1.0\",\n" +
+		"\t\t\t\"installer\": {\n" +
+		"\t\t\t\t\"x86\": \"https://example.com/zip.exe\",\n" +
+		"\t\t\t\t\"kind\": \"as-is\",\n" +
+		"\t\t\t\t\"checksums\": {\"x86\": \"new\", \"x86_64\": \"added\"}\n" +
+		"\t\t\t}\n" +
+		"\t\t},\n" +
+		"\t\t\"app\": {\n" +
+		"\t\t\t\"version\": \"2.0\",\n" +
+		"\t\t\t\"installer\": {\n" +
+		"\t\t\t\t\"kind\": \"msi\",\n" +
+		"\t\t\t\t\"x86\": \"https://example.com/app-x86.msi\",\n" +
+		"\t\t\t\t\"x86_64\": \"https://example.com/app-x64.msi\",\n" +
+		"\t\t\t\t\"checksums\": {\n" +
+		"\t\t\t\t\t\"x86\": \"created\"\n" +
+		"\t\t\t\t}\n" +
+		"\t\t\t}\n" +
+		"\t\t}\n" +
+		"\t}\n" +
+		"}\n"
+
+	dir, err := ioutil.TempDir("", "just-install-registry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "just-install.json")
+	if err := ioutil.WriteFile(path, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	registryFile, err := ReadRegistryFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes := []struct{ pkg, path, value string }{
+		{"zip", "installer.checksums.x86", "new"},
+		{"zip", "Installer.Checksums.x86_64", "added"},
+		{"app", "installer.checksums.x86", "created"},
+	}
+	for _, change := range changes {
+		if err := registryFile.Set(change.pkg, change.path, change.value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := registryFile.Write(path); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, data)
+	}
+
+	if _, err := LoadRegistry(path); err != nil {
+		t.Errorf("could not load the written registry: %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/just-install/just-install/pkg/resolver"
+)
+
+// resolverEntry references a resolver, registered with resolver.RegisterResolver, that finds the
+// version and installer URLs of a package when it is installed.
+type resolverEntry struct {
+	Name   string
+	Params map[string]string // Optional, depend on the resolver
+}
+
+// resolve replaces the version and installer URLs of the entry with those found by its resolver, if
+// it has one. The entry is resolved at most once.
+func (e *RegistryEntry) resolve(ctx context.Context) error {
+	if e.Installer.Resolver == nil {
+		return nil
+	}
+
+	r, err := resolver.Lookup(e.Installer.Resolver.Name)
+	if err != nil {
+		return err
+	}
+
+	resolution, err := r.Resolve(ctx, e.Installer.Resolver.Params)
+	if err != nil {
+		return fmt.Errorf("cannot resolve installer with the %v resolver: %w", e.Installer.Resolver.Name, err)
+	}
+
+	if resolution.Version != "" {
+		e.Version = resolution.Version
+	}
+	if resolution.X86 != "" {
+		e.Installer.X86 = resolution.X86
+	}
+	if resolution.X86_64 != "" {
+		e.Installer.X86_64 = resolution.X86_64
+	}
+	e.Installer.Resolver = nil
+
+	return nil
+}
@@ -0,0 +1,147 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// shim is a shim declared by a registry entry, either as a plain string with its target or as a
+// JSON object with the name of the shim, its target and its arguments.
+type shim struct {
+	Name   string   // File name of the shim, defaults to the file name of the target
+	Target string   // Executable, or glob pattern matching executables, with placeholders expanded
+	Args   []string // Arguments passed to the target before those given to the shim
+}
+
+// shims returns the shims declared by the entry for the given architecture. Returns an error if
+// any of them is invalid, or if two of them have the same name.
+func (e *RegistryEntry) shims(arch string) ([]shim, error) {
+	declared, ok := e.Installer.options(arch)["shims"].([]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	var ret []shim
+	names := make(map[string]bool)
+
+	for _, v := range declared {
+		var s shim
+
+		switch v := v.(type) {
+		case string:
+			s.Target = e.ExpandString(v)
+		case map[string]interface{}:
+			name, _ := v["name"].(string)
+			target, _ := v["target"].(string)
+			s = shim{Name: e.ExpandString(name), Target: e.ExpandString(target)}
+
+			args, _ := v["args"].([]interface{})
+			for _, arg := range args {
+				arg, _ := arg.(string)
+				s.Args = append(s.Args, e.ExpandString(arg))
+			}
+		default:
+			return nil, fmt.Errorf("invalid shim: %v", v)
+		}
+
+		if s.Target == "" {
+			return nil, fmt.Errorf("shim %v has no target", s.Name)
+		}
+		if strings.ContainsAny(s.Name, `/\`) {
+			return nil, fmt.Errorf("invalid shim name %v", s.Name)
+		}
+
+		glob := strings.ContainsAny(s.Target, "*?[")
+		if glob && s.Name != "" {
+			return nil, fmt.Errorf("shim %v cannot have a name, since its target is a pattern", s.Target)
+		}
+
+		if name := strings.ToLower(s.fileName(s.Target)); !glob && names[name] {
+			return nil, fmt.Errorf("duplicate shim %v", s.fileName(s.Target))
+		} else if !glob {
+			names[name] = true
+		}
+
+		ret = append(ret, s)
+	}
+
+	return ret, nil
+}
+
+// ShimNames returns the file names of the shims the entry creates for the given architecture, as
+// far as they are known before installing it: shims whose target is a pattern are left out.
+func (e *RegistryEntry) ShimNames(arch string) []string {
+	shims, err := e.shims(arch)
+	if err != nil {
+		return nil
+	}
+
+	var ret []string
+	for _, s := range shims {
+		if !strings.ContainsAny(s.Target, "*?[") {
+			ret = append(ret, s.fileName(s.Target))
+		}
+	}
+
+	return ret
+}
+
+// fileName returns the file name of the shim for the given target, one of those matching the shim
+// target. Shims with arguments are batch files, since exeproxy cannot add arguments.
+func (s shim) fileName(target string) string {
+	name := s.Name
+	if name == "" {
+		name = filepath.Base(target)
+	} else if filepath.Ext(name) == "" {
+		name += filepath.Ext(target)
+	}
+
+	if len(s.Args) > 0 {
+		name = strings.TrimSuffix(name, filepath.Ext(name)) + ".cmd"
+	}
+
+	return name
+}
+
+// writeBatch writes a batch file at the given path that runs the given target with the arguments of
+// the shim, followed by those given to the batch file.
+func (s shim) writeBatch(path string, target string) error {
+	return s.writeBatchCommand(path, batchQuote(target))
+}
+
+// writeBatchCommand is like writeBatch, with the target given as it must appear in the batch file.
+func (s shim) writeBatchCommand(path string, target string) error {
+	command := []string{target}
+	for _, arg := range s.Args {
+		command = append(command, batchQuote(arg))
+	}
+
+	return ioutil.WriteFile(path, []byte("@"+strings.Join(command, " ")+" %*\r\n"), 0644)
+}
+
+// batchQuote quotes the given argument for a batch file, if needed.
+func batchQuote(arg string) string {
+	arg = strings.ReplaceAll(arg, "%", "%%")
+	if arg == "" || strings.ContainsAny(arg, " \t&|<>^()") {
+		return `"` + arg + `"`
+	}
+
+	return arg
+}
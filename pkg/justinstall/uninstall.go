@@ -0,0 +1,157 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ungerik/go-dry"
+
+	"github.com/just-install/just-install/pkg/installer"
+	"github.com/just-install/just-install/pkg/platform"
+)
+
+// ErrUninstallUnsupported is returned by Uninstall for packages that just-install doesn't know how
+// to uninstall.
+var ErrUninstallUnsupported = errors.New("don't know how to uninstall this package")
+
+// Uninstall removes the package installed for the given architecture, along with its shims, file
+// associations and environment variables. Files copied or extracted by just-install are removed
+// directly, MSI and MSU packages are removed with their own installer (downloading it again if
+// needed), MSIX packages by identity (see Options.MSIXIdentity), other packages need an `uninstall`
+// option with the command line to run.
+func (e *RegistryEntry) Uninstall(ctx context.Context, arch string, options *Options) error {
+	if options == nil {
+		options = &Options{}
+	}
+
+	e.RemoveShims(arch)
+	e.RemoveAssociations(arch)
+	e.RemoveEnvironment(arch)
+
+	if args, ok := e.Installer.options(arch)["uninstall"].([]interface{}); ok {
+		var command []string
+		for _, v := range args {
+			arg, err := expandStringStrict(v.(string), nil)
+			if err != nil {
+				return fmt.Errorf("cannot expand uninstall argument: %w", err)
+			}
+
+			command = append(command, arg)
+		}
+
+		return runInstaller(ctx, nil, command)
+	}
+
+	switch e.Installer.Kind {
+	case "copy", "zip":
+		if shortcuts, ok := e.Installer.options(arch)["shortcuts"]; ok {
+			for _, shortcut := range shortcuts.([]interface{}) {
+				shortcutName := expandString(shortcut.(map[string]interface{})["name"].(string), nil)
+				shortcutLocation := filepath.Join(startMenu, shortcutName+".lnk")
+
+				log.Println("removing shortcut", shortcutLocation)
+				os.Remove(shortcutLocation)
+			}
+		}
+
+		destination := e.destination(arch)
+		log.Println("removing", destination)
+
+		return os.RemoveAll(destination)
+	case "custom":
+		return ErrUninstallUnsupported
+	case "msix":
+		identity := options.MSIXIdentity
+		if identity == "" {
+			// Installed before identities were recorded, the installer has it.
+			downloadedFile, err := e.DownloadInstaller(ctx, arch, options)
+			if err != nil {
+				return err
+			}
+
+			if identity, err = installer.MSIXName(downloadedFile); err != nil {
+				return err
+			}
+		}
+
+		return runInstaller(ctx, nil, installer.MSIXUninstallCommand(identity))
+	}
+
+	downloadedFile, err := e.DownloadInstaller(ctx, arch, options)
+	if err != nil {
+		return err
+	}
+
+	command, err := installer.UninstallCommand(platform.ShortPath(downloadedFile), installer.InstallerType(e.Installer.Kind))
+	if errors.Is(err, installer.ErrNoUninstallCommand) {
+		return ErrUninstallUnsupported
+	} else if err != nil {
+		return err
+	}
+
+	return runInstaller(ctx, nil, command)
+}
+
+// CanUninstall returns whether Uninstall knows how to remove the package installed for the given
+// architecture.
+func (e *RegistryEntry) CanUninstall(arch string) bool {
+	if _, ok := e.Installer.options(arch)["uninstall"].([]interface{}); ok {
+		return true
+	}
+
+	switch e.Installer.Kind {
+	case "copy", "zip", "msi", "msix", "msu":
+		return true
+	default:
+		return false
+	}
+}
+
+// RemoveShims removes the shims created by CreateShims, if any.
+func (e *RegistryEntry) RemoveShims(arch string) {
+	shims, err := e.shims(arch)
+	if err != nil {
+		log.Println("WARNING: not removing shims:", err)
+		return
+	}
+
+	for _, s := range shims {
+		// Unlike CreateShims, don't care whether targets still exist.
+		targets := []string{s.Target}
+		if strings.ContainsAny(targets[0], "*?[") {
+			targets, _ = filepath.Glob(targets[0])
+		}
+
+		for _, shimTarget := range targets {
+			shim := filepath.Join(shimsPath, s.fileName(shimTarget))
+			if !dry.FileExists(shim) {
+				continue
+			}
+
+			log.Println("removing shim", shim)
+			if err := os.Remove(shim); err != nil {
+				log.Println("WARNING: could not remove shim:", err)
+			}
+		}
+	}
+}
@@ -17,26 +17,123 @@ package justinstall
 
 import (
 	"bytes"
+	"fmt"
 	"os"
+	"regexp"
 	"strings"
 	"text/template"
+
+	"github.com/just-install/just-install/pkg/redact"
 )
 
 // expandString expands any environment variable in the given string, with additional variables
 // coming from the given context.
 func expandString(s string, context map[string]string) string {
+	data := templateData(context)
+
+	var buf bytes.Buffer
+
+	template.Must(template.New("expand").Funcs(templateFuncs).Parse(s)).Execute(&buf, data)
+
+	return buf.String()
+}
+
+// expandStringStrict is like expandString, but returns an error if the template cannot be parsed or
+// executed, i.e. because it references an unset environment variable through `env` or, with
+// EnableStrictTemplates, an undefined variable.
+func expandStringStrict(s string, context map[string]string) (string, error) {
+	data := templateData(context)
+
+	t := template.New("expand").Funcs(templateFuncs)
+	if strictTemplates {
+		t = t.Option("missingkey=error")
+	}
+
+	t, err := t.Parse(s)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// templateData returns the variables available to templates: environment variables, then those set
+// with SetTemplateVar, then the given context.
+func templateData(context map[string]string) map[string]string {
 	data := environMap()
 
+	for k, v := range templateVars {
+		data[k] = v
+	}
+
 	// Merge the given context
 	for k, v := range context {
 		data[k] = v
 	}
 
-	var buf bytes.Buffer
+	return data
+}
 
-	template.Must(template.New("expand").Parse(s)).Execute(&buf, data)
+// templateVars are the variables set with SetTemplateVar.
+var templateVars = make(map[string]string)
 
-	return buf.String()
+// templateVarName matches the variable names that can be referenced as `{{.name}}`.
+var templateVarName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// SetTemplateVar makes a `name=value` assignment available to all templates in the registry (i.e.
+// installer URLs and arguments) as `{{.name}}`. Sensitive values are never logged, like those
+// obtained with `env`.
+func SetTemplateVar(assignment string, sensitive bool) error {
+	eq := strings.Index(assignment, "=")
+	if eq < 0 {
+		return fmt.Errorf("invalid template variable %v: expected name=value", assignment)
+	}
+
+	name, value := assignment[:eq], assignment[eq+1:]
+	if !templateVarName.MatchString(name) {
+		return fmt.Errorf("invalid template variable name %q: expected letters, digits and underscores", name)
+	}
+
+	if sensitive {
+		redact.Add(value)
+	}
+
+	templateVars[name] = value
+
+	return nil
+}
+
+// strictTemplates is set by EnableStrictTemplates.
+var strictTemplates = false
+
+// EnableStrictTemplates makes references to undefined variables in installer URLs, headers and
+// arguments an error, instead of expanding to "<no value>".
+func EnableStrictTemplates() {
+	strictTemplates = true
+}
+
+// templateFuncs are the functions available to templates in the registry.
+var templateFuncs = template.FuncMap{
+	"env": templateEnv,
+}
+
+// templateEnv implements the `env` template function, that returns the value of an environment
+// variable, failing if it is not set. Values obtained this way are considered sensitive (i.e.
+// license keys) and are never logged.
+func templateEnv(name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %v is not set", name)
+	}
+
+	redact.Add(value)
+
+	return value, nil
 }
 
 // environMap returns the current environment variables as a map.
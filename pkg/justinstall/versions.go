@@ -0,0 +1,89 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// versionEntry describes the installers of a version of a package other than the current one, that
+// can still be installed by asking for it (i.e. `foo@1.2.3`). Checksums always belong to the version
+// they are listed with, installer URLs default to the package's ones with `{{.version}}` expanded
+// to this version.
+type versionEntry struct {
+	Version   string
+	Checksums map[string]string            // Optional, architecture -> hex-encoded SHA-256 digest
+	Hashes    map[string]map[string]string // Optional, architecture -> algorithm -> hex-encoded digest
+	X86       string                       // Optional
+	X86_64    string                       // Optional
+}
+
+// AtVersion returns the entry as it would be for the given version, which must be either the
+// current one or one of its `versions`. Installers of other versions are verified against their own
+// checksums only, never against those of the current version.
+func (e *RegistryEntry) AtVersion(version string) (RegistryEntry, error) {
+	if version == e.Version {
+		return *e, nil
+	}
+
+	if e.Installer.Resolver != nil {
+		return RegistryEntry{}, errors.New("cannot pick a version of a package whose version is found by a resolver")
+	}
+
+	for _, v := range e.Versions {
+		if v.Version != version {
+			continue
+		}
+
+		ret := *e
+		ret.Version = version
+		ret.Installer.Checksums = v.Checksums
+		ret.Installer.Hashes = v.Hashes
+		ret.Installer.WindowsVersions = nil // Their installers and checksums are those of the current version
+		ret.Versions = nil
+
+		if v.X86 != "" || v.X86_64 != "" {
+			ret.Installer.X86, ret.Installer.X86_64 = v.X86, v.X86_64
+			ret.Installer.Mirrors = nil
+		}
+
+		return ret, nil
+	}
+
+	return RegistryEntry{}, fmt.Errorf("unknown version %v, available versions are: %v", version, strings.Join(e.AvailableVersions(), ", "))
+}
+
+// AvailableVersions returns the versions of the entry that can be installed, current one first.
+func (e *RegistryEntry) AvailableVersions() []string {
+	ret := []string{e.Version}
+	for _, v := range e.Versions {
+		ret = append(ret, v.Version)
+	}
+
+	return ret
+}
+
+// SplitPackageVersion splits a package requested with a version (i.e. `foo@1.2.3`) into its name
+// and version. The version is empty if none was requested.
+func SplitPackageVersion(spec string) (string, string) {
+	if i := strings.LastIndex(spec, "@"); i > 0 {
+		return spec[:i], spec[i+1:]
+	}
+
+	return spec, ""
+}
@@ -0,0 +1,89 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package justinstall
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ungerik/go-dry"
+
+	"github.com/just-install/just-install/pkg/checksum"
+)
+
+// InstallerLocation describes where the installer of a package would be downloaded from and where it
+// is cached, as returned by Which.
+type InstallerLocation struct {
+	Version   string   `json:"version"`           // Package version, as found by its resolver if it has one
+	Arch      string   `json:"arch"`              // May differ from the requested one, when falling back to the x86 installer
+	URL       string   `json:"url"`               // Tried first, according to the mirror strategy
+	Mirrors   []string `json:"mirrors,omitempty"` // Tried next, in order
+	CachePath string   `json:"cachePath"`         // Where the installer is, or would be, cached
+	Cached    bool     `json:"cached"`            // Whether the installer is in the cache
+	Valid     bool     `json:"valid"`             // Whether the cached installer would be used as is
+	Problem   string   `json:"problem,omitempty"` // Why the cached installer would not be used
+}
+
+// Which returns where the installer for the given architecture would be downloaded from with the
+// given options, and whether a valid copy of it is already cached. Nothing is downloaded, apart
+// from what resolvers and published checksum files need, and nothing is removed from the cache.
+func (e *RegistryEntry) Which(ctx context.Context, arch string, options *Options) (*InstallerLocation, error) {
+	if options == nil {
+		options = &Options{}
+	}
+
+	plan, err := e.Plan(ctx, arch, options)
+	if err != nil {
+		return nil, err
+	}
+
+	urls, err := orderURLs(ctx, append([]string{plan.URL}, plan.Mirrors...), options.MirrorStrategy)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &InstallerLocation{
+		Version:   plan.Version,
+		Arch:      plan.Arch,
+		URL:       urls[0],
+		Mirrors:   urls[1:],
+		CachePath: cachedInstallerPath(plan.URL),
+	}
+
+	ret.Cached = dry.FileExists(ret.CachePath)
+	if !ret.Cached {
+		return ret, nil
+	}
+
+	algorithm := checksum.Strongest(plan.Hashes)
+	if options.HashAlgorithm != "" {
+		algorithm = strings.ToLower(options.HashAlgorithm)
+	}
+
+	if e.Installer.Volatile {
+		ret.Problem = "the installer is volatile, it is always downloaded again"
+	} else if err := checkIntegrity(ret.CachePath, algorithm, plan.Hashes[algorithm]); err != nil {
+		ret.Problem = fmt.Sprintf("changed since it was downloaded: %v", err)
+	} else if algorithm != "" {
+		if err := checksum.VerifyWith(ret.CachePath, algorithm, plan.Hashes[algorithm]); err != nil {
+			ret.Problem = err.Error()
+		}
+	}
+	ret.Valid = ret.Problem == ""
+
+	return ret, nil
+}
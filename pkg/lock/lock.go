@@ -0,0 +1,88 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package lock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/just-install/just-install/pkg/paths"
+)
+
+// FileName is the name of the global lock file, in just-install's state directory.
+const FileName = "just-install.lock"
+
+// ErrLocked is returned when another instance of just-install holds the lock.
+var ErrLocked = errors.New("another instance of just-install is running")
+
+// fileLockRetryInterval is how often AcquireFile tries to acquire a lock held by someone else.
+const fileLockRetryInterval = 50 * time.Millisecond
+
+// Lock is the global just-install lock. The operating system releases it automatically when the
+// process exits, so that a crash never leaves a stale lock behind.
+type Lock struct {
+	file *os.File
+}
+
+// Acquire acquires the global lock, failing immediately with ErrLocked if another instance of
+// just-install is holding it.
+func Acquire() (*Lock, error) {
+	path, err := paths.StateFileCreate(FileName)
+	if err != nil {
+		return nil, fmt.Errorf("could not create lock file: %w", err)
+	}
+
+	file, err := lockFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Lock{file}, nil
+}
+
+// AcquireFile acquires a lock on the lock file at the given path, waiting up to the given timeout
+// for another holder (possibly in another process) to release it. Unlike the global lock, it is meant
+// to guard short critical sections, i.e. updating a file.
+func AcquireFile(path string, timeout time.Duration) (*Lock, error) {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		file, err := lockFile(path)
+		if err == nil {
+			return &Lock{file}, nil
+		}
+
+		if !errors.Is(err, ErrLocked) || time.Now().After(deadline) {
+			return nil, fmt.Errorf("could not lock %v: %w", path, err)
+		}
+
+		time.Sleep(fileLockRetryInterval)
+	}
+}
+
+// Release releases the lock. It is safe to call it more than once.
+func (l *Lock) Release() error {
+	if l.file == nil {
+		return nil
+	}
+
+	err := l.file.Close()
+	l.file = nil
+
+	return err
+}
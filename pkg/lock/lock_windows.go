@@ -0,0 +1,42 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package lock
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+
+	"github.com/just-install/just-install/pkg/platform"
+)
+
+// lockFile opens the given file without sharing it with other processes, which fails if another
+// process has it open.
+func lockFile(path string) (*os.File, error) {
+	pathp, err := windows.UTF16PtrFromString(platform.LongPath(path))
+	if err != nil {
+		return nil, err
+	}
+
+	handle, err := windows.CreateFile(pathp, windows.GENERIC_READ|windows.GENERIC_WRITE, 0, nil, windows.OPEN_ALWAYS, windows.FILE_ATTRIBUTE_NORMAL, 0)
+	if err == windows.ERROR_SHARING_VIOLATION {
+		return nil, ErrLocked
+	} else if err != nil {
+		return nil, err
+	}
+
+	return os.NewFile(uintptr(handle), path), nil
+}
@@ -18,12 +18,20 @@ package paths
 import (
 	"os"
 	"path/filepath"
+	"runtime"
+)
+
+// Environment variables that override the default location of just-install's directories.
+const (
+	CacheDirEnv  = "JUST_INSTALL_CACHE_DIR"
+	ConfigDirEnv = "JUST_INSTALL_CONFIG_DIR"
+	StateDirEnv  = "JUST_INSTALL_STATE_DIR"
 )
 
 // TempFileCreate is the same as TempFile() but also creates just-install's temporary directory if
 // missing.
 func TempFileCreate(file string) (string, error) {
-	if err := os.MkdirAll(tempDir(), 0700); err != nil {
+	if err := os.MkdirAll(TempDir(), 0700); err != nil {
 		return "", err
 	}
 
@@ -33,7 +41,63 @@ func TempFileCreate(file string) (string, error) {
 // TempDirCreate is the same as TempDir() but also creates just-install's temporary directory if
 // missing.
 func TempDirCreate() (string, error) {
-	ret := tempDir()
+	ret := TempDir()
+
+	if err := os.MkdirAll(ret, 0700); err != nil {
+		return "", err
+	}
+
+	return ret, nil
+}
+
+// CacheFileCreate returns the path to a file inside just-install's cache directory, creating the
+// directory if missing.
+func CacheFileCreate(file string) (string, error) {
+	dir, err := CacheDirCreate()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, file), nil
+}
+
+// CacheDirCreate returns the directory where downloaded installers and the registry are cached,
+// creating it if missing.
+func CacheDirCreate() (string, error) {
+	ret := CacheDir()
+
+	if err := os.MkdirAll(ret, 0700); err != nil {
+		return "", err
+	}
+
+	return ret, nil
+}
+
+// CacheDir returns the directory where downloaded installers and the registry are cached. On
+// Windows this is below %LOCALAPPDATA%, elsewhere it follows the XDG base directory specification.
+// It can be overridden with the JUST_INSTALL_CACHE_DIR environment variable. Unlike
+// CacheDirCreate() it doesn't create the directory.
+func CacheDir() string {
+	if dir := os.Getenv(CacheDirEnv); dir != "" {
+		return dir
+	}
+
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return filepath.Join(TempDir(), "cache")
+	}
+
+	if runtime.GOOS == "windows" {
+		return filepath.Join(base, "just-install", "cache")
+	}
+
+	return filepath.Join(base, "just-install")
+}
+
+// ConfigDirCreate returns the directory where just-install's configuration is stored, creating it
+// if missing.
+func ConfigDirCreate() (string, error) {
+	ret := configDir()
 
 	if err := os.MkdirAll(ret, 0700); err != nil {
 		return "", err
@@ -42,12 +106,81 @@ func TempDirCreate() (string, error) {
 	return ret, nil
 }
 
+// configDir returns the directory where just-install's configuration is stored. On Windows this is
+// below %APPDATA%, elsewhere it follows the XDG base directory specification. It can be overridden
+// with the JUST_INSTALL_CONFIG_DIR environment variable.
+func configDir() string {
+	if dir := os.Getenv(ConfigDirEnv); dir != "" {
+		return dir
+	}
+
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return filepath.Join(TempDir(), "config")
+	}
+
+	return filepath.Join(base, "just-install")
+}
+
+// StateFileCreate returns the path to a file inside just-install's state directory, creating the
+// directory if missing.
+func StateFileCreate(file string) (string, error) {
+	dir, err := StateDirCreate()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, file), nil
+}
+
+// StateDirCreate returns the directory where just-install keeps track of what it did on this
+// machine, creating it if missing.
+func StateDirCreate() (string, error) {
+	ret := StateDir()
+
+	if err := os.MkdirAll(ret, 0700); err != nil {
+		return "", err
+	}
+
+	return ret, nil
+}
+
+// StateDir returns the directory where just-install keeps track of what it did on this machine.
+// On Windows this is below %LOCALAPPDATA%, elsewhere it follows the XDG base directory
+// specification. It can be overridden with the JUST_INSTALL_STATE_DIR environment variable. Unlike
+// StateDirCreate() it doesn't create the directory.
+func StateDir() string {
+	if dir := os.Getenv(StateDirEnv); dir != "" {
+		return dir
+	}
+
+	if runtime.GOOS == "windows" {
+		base, err := os.UserCacheDir() // %LOCALAPPDATA%
+		if err != nil {
+			return filepath.Join(TempDir(), "state")
+		}
+
+		return filepath.Join(base, "just-install", "state")
+	}
+
+	if base := os.Getenv("XDG_STATE_HOME"); base != "" {
+		return filepath.Join(base, "just-install")
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(TempDir(), "state")
+	}
+
+	return filepath.Join(home, ".local", "state", "just-install")
+}
+
 // tempFile returns the path to a temporary file below just-install's temporary file directory.
 func tempFile(file string) string {
-	return filepath.Join(tempDir(), file)
+	return filepath.Join(TempDir(), file)
 }
 
-// tempDir returns the temporary directory that must be used to store all of just-install's files.
-func tempDir() string {
+// TempDir returns the temporary directory that must be used to store all of just-install's files.
+func TempDir() string {
 	return filepath.Join(os.TempDir(), "just-install")
 }
@@ -0,0 +1,219 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package paths
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/ungerik/go-dry"
+)
+
+// setenv sets an environment variable, returning a function that restores its previous value.
+func setenv(t *testing.T, name string, value string) func() {
+	t.Helper()
+
+	previous, set := os.LookupEnv(name)
+	if err := os.Setenv(name, value); err != nil {
+		t.Fatal(err)
+	}
+
+	return func() {
+		if set {
+			os.Setenv(name, previous)
+		} else {
+			os.Unsetenv(name)
+		}
+	}
+}
+
+// mockEnvironment points the base directories of the operating system to subdirectories of a new
+// temporary directory and clears the overrides. It returns the temporary directory and a function
+// that removes it and restores the environment.
+func mockEnvironment(t *testing.T) (string, func()) {
+	t.Helper()
+
+	base, err := ioutil.TempDir("", "just-install-paths")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env := map[string]string{
+		"HOME":            filepath.Join(base, "home"),
+		"XDG_CACHE_HOME":  filepath.Join(base, "cache"),
+		"XDG_CONFIG_HOME": filepath.Join(base, "config"),
+		"XDG_STATE_HOME":  filepath.Join(base, "state"),
+	}
+	if runtime.GOOS == "windows" {
+		env = map[string]string{
+			"LOCALAPPDATA": filepath.Join(base, "Local"),
+			"APPDATA":      filepath.Join(base, "Roaming"),
+		}
+	}
+	for _, name := range []string{CacheDirEnv, ConfigDirEnv, StateDirEnv} {
+		env[name] = ""
+	}
+
+	var restore []func()
+	for name, value := range env {
+		restore = append(restore, setenv(t, name, value))
+	}
+
+	return base, func() {
+		for _, f := range restore {
+			f()
+		}
+		os.RemoveAll(base)
+	}
+}
+
+func TestDefaultDirs(t *testing.T) {
+	base, restore := mockEnvironment(t)
+	defer restore()
+
+	expected := map[string]string{
+		"cache":  filepath.Join(base, "cache", "just-install"),
+		"config": filepath.Join(base, "config", "just-install"),
+		"state":  filepath.Join(base, "state", "just-install"),
+	}
+	if runtime.GOOS == "windows" {
+		expected = map[string]string{
+			"cache":  filepath.Join(base, "Local", "just-install", "cache"),
+			"config": filepath.Join(base, "Roaming", "just-install"),
+			"state":  filepath.Join(base, "Local", "just-install", "state"),
+		}
+	}
+
+	got := map[string]string{"cache": CacheDir(), "config": configDir(), "state": StateDir()}
+	for name, dir := range expected {
+		if got[name] != dir {
+			t.Errorf("%v directory: expected %v, got %v", name, dir, got[name])
+		}
+	}
+}
+
+func TestStateDirWithoutXDGStateHome(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("XDG directories are not used on Windows")
+	}
+
+	base, restore := mockEnvironment(t)
+	defer restore()
+	defer setenv(t, "XDG_STATE_HOME", "")()
+
+	if expected := filepath.Join(base, "home", ".local", "state", "just-install"); StateDir() != expected {
+		t.Errorf("expected %v, got %v", expected, StateDir())
+	}
+}
+
+func TestOverriddenDirs(t *testing.T) {
+	base, restore := mockEnvironment(t)
+	defer restore()
+
+	tests := []struct {
+		env      string
+		resolve  func() (string, error)
+		expected string
+	}{
+		{CacheDirEnv, CacheDirCreate, filepath.Join(base, "custom-cache")},
+		{ConfigDirEnv, ConfigDirCreate, filepath.Join(base, "custom-config")},
+		{StateDirEnv, StateDirCreate, filepath.Join(base, "custom-state")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.env, func(t *testing.T) {
+			defer setenv(t, test.env, test.expected)()
+
+			dir, err := test.resolve()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if dir != test.expected {
+				t.Errorf("expected %v, got %v", test.expected, dir)
+			}
+
+			if !dry.FileIsDir(dir) {
+				t.Errorf("%v was not created", dir)
+			}
+		})
+	}
+}
+
+func TestFileCreate(t *testing.T) {
+	base, restore := mockEnvironment(t)
+	defer restore()
+	defer setenv(t, CacheDirEnv, filepath.Join(base, "custom-cache"))()
+	defer setenv(t, StateDirEnv, filepath.Join(base, "custom-state"))()
+
+	tests := []struct {
+		create   func(string) (string, error)
+		expected string
+	}{
+		{CacheFileCreate, filepath.Join(base, "custom-cache", "file")},
+		{StateFileCreate, filepath.Join(base, "custom-state", "file")},
+	}
+
+	for _, test := range tests {
+		path, err := test.create("file")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if path != test.expected {
+			t.Errorf("expected %v, got %v", test.expected, path)
+		}
+
+		if !dry.FileIsDir(filepath.Dir(path)) {
+			t.Errorf("%v was not created", filepath.Dir(path))
+		}
+	}
+}
+
+func TestLongUnicodeDirs(t *testing.T) {
+	base, restore := mockEnvironment(t)
+	defer restore()
+
+	// A non-ASCII user profile, deep enough to go past MAX_PATH
+	profile := filepath.Join(base, "Jürgen Müller 山田")
+	deep := filepath.Join(profile, strings.Repeat("ディレクトリ-directory-", 8), strings.Repeat("sous-répertoire-", 10))
+	defer setenv(t, CacheDirEnv, filepath.Join(deep, "cache"))()
+	defer setenv(t, StateDirEnv, filepath.Join(deep, "state"))()
+
+	for _, create := range []func(string) (string, error){CacheFileCreate, StateFileCreate} {
+		path, err := create("installer-😀.exe")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(utf16.Encode([]rune(path))) <= 260 {
+			t.Fatalf("%v is not longer than MAX_PATH", path)
+		}
+
+		if err := ioutil.WriteFile(path, []byte("installer"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if data, err := ioutil.ReadFile(path); err != nil || string(data) != "installer" {
+			t.Errorf("could not read back %v: %v", path, err)
+		}
+	}
+}
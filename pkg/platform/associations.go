@@ -0,0 +1,31 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package platform
+
+import "errors"
+
+// ErrAssociationInUse is returned when registering a file association for an extension that is
+// already associated with another program.
+var ErrAssociationInUse = errors.New("the extension is already associated with another program")
+
+// FileAssociation associates files with the given extension to a program.
+type FileAssociation struct {
+	Extension   string // With the leading dot, i.e. ".txt"
+	ProgID      string // Identifies the program, i.e. "Vendor.App.txt"
+	Description string // Optional, shown by Explorer as the type of the file
+	Command     string // Command line that opens a file, "%1" is replaced with its path
+	Icon        string // Optional, i.e. "C:\Program Files\App\app.exe,0"
+}
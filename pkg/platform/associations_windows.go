@@ -0,0 +1,272 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package platform
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+)
+
+// Where file associations are registered, for all users or for the current user.
+const (
+	userClassesKey   = `HKCU\Software\Classes`
+	systemClassesKey = `HKLM\SOFTWARE\Classes`
+)
+
+// Where we keep track of what we created to register each file association, so that only that is
+// removed when unregistering it. Below it, each association has a key named after its ProgID and
+// extension, with a value set for each of the following that we created.
+const (
+	userCreatedKey   = `HKCU\Software\just-install\FileAssociations`
+	systemCreatedKey = `HKLM\SOFTWARE\just-install\FileAssociations`
+
+	createdProgID   = "ProgID"   // The key of the ProgID
+	createdOpenWith = "OpenWith" // The ProgID in the "Open with" list of the extension
+	createdDefault  = "Default"  // The ProgID as the default program of the extension
+)
+
+// SHChangeNotify event telling Explorer that file associations changed.
+const shcneAssocChanged = 0x08000000
+
+var procSHChangeNotify = windows.NewLazySystemDLL("shell32.dll").NewProc("SHChangeNotify")
+
+// RegisterFileAssociation registers the given file association for all users if systemWide is set,
+// or for the current user otherwise. If the extension is already associated with another program,
+// or the user picked a default program for it, it returns ErrAssociationInUse unless force is set.
+// The program is added to the "Open with" list anyway. A ProgID registered by someone else (i.e.
+// the installer of the program) is used as it is.
+func RegisterFileAssociation(a FileAssociation, force bool, systemWide bool) error {
+	classesKey, createdKey := associationKeys(a, systemWide)
+	progIDKey := classesKey + `\` + a.ProgID
+
+	created, err := createdByUs(createdKey)
+	if err != nil {
+		return err
+	}
+
+	if exists, err := RegistryKeyExists(progIDKey); err != nil {
+		return err
+	} else if !exists || created[createdProgID] {
+		if err := registerProgID(progIDKey, a); err != nil {
+			return err
+		}
+
+		if err := setRegistryString(createdKey, createdProgID, ""); err != nil {
+			return err
+		}
+	}
+
+	extensionKey := classesKey + `\` + a.Extension
+
+	if exists, err := registryValueExists(extensionKey+`\OpenWithProgids`, a.ProgID); err != nil {
+		return err
+	} else if !exists {
+		if err := setRegistryString(extensionKey+`\OpenWithProgids`, a.ProgID, ""); err != nil {
+			return err
+		}
+
+		if err := setRegistryString(createdKey, createdOpenWith, ""); err != nil {
+			return err
+		}
+	}
+
+	defer notifyAssociationsChanged()
+
+	current, err := getRegistryString(extensionKey, "")
+	if err != nil {
+		return err
+	} else if strings.EqualFold(current, a.ProgID) {
+		return nil
+	} else if current != "" && !force {
+		return fmt.Errorf("%w: %v", ErrAssociationInUse, current)
+	}
+
+	if userChoice, err := RegistryKeyExists(userChoiceKey(a.Extension)); err != nil {
+		return err
+	} else if userChoice && !force {
+		return fmt.Errorf("%w: chosen by the user", ErrAssociationInUse)
+	}
+
+	if err := setRegistryString(extensionKey, "", a.ProgID); err != nil {
+		return err
+	}
+
+	return setRegistryString(createdKey, createdDefault, "")
+}
+
+// registerProgID sets the description, command and icon of the given ProgID key.
+func registerProgID(progIDKey string, a FileAssociation) error {
+	if err := setRegistryString(progIDKey, "", a.Description); err != nil {
+		return err
+	}
+
+	if err := setRegistryString(progIDKey+`\shell\open\command`, "", a.Command); err != nil {
+		return err
+	}
+
+	if a.Icon != "" {
+		if err := setRegistryString(progIDKey+`\DefaultIcon`, "", a.Icon); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UnregisterFileAssociation removes what RegisterFileAssociation created to register the given
+// file association, for all users if systemWide is set or for the current user otherwise. The
+// extension is left alone if it has been associated with another program in the meantime.
+func UnregisterFileAssociation(a FileAssociation, systemWide bool) error {
+	classesKey, createdKey := associationKeys(a, systemWide)
+	extensionKey := classesKey + `\` + a.Extension
+
+	created, err := createdByUs(createdKey)
+	if err != nil {
+		return err
+	} else if len(created) == 0 {
+		return nil
+	}
+
+	defer notifyAssociationsChanged()
+
+	if created[createdDefault] {
+		if current, err := getRegistryString(extensionKey, ""); err != nil {
+			return err
+		} else if strings.EqualFold(current, a.ProgID) {
+			if err := setRegistryString(extensionKey, "", ""); err != nil {
+				return err
+			}
+		}
+	}
+
+	if created[createdOpenWith] {
+		if key, err := openRegistryKey(extensionKey+`\OpenWithProgids`, registry.SET_VALUE); err == nil {
+			err := key.DeleteValue(a.ProgID)
+			key.Close()
+			if err != nil && err != registry.ErrNotExist {
+				return err
+			}
+		} else if err != registry.ErrNotExist {
+			return err
+		}
+	}
+
+	if created[createdProgID] {
+		if err := deleteRegistryKey(classesKey + `\` + a.ProgID); err != nil {
+			return err
+		}
+	}
+
+	return deleteRegistryKey(createdKey)
+}
+
+// associationKeys returns the key below which the given file association is registered, and the
+// key where we keep track of what we created to register it.
+func associationKeys(a FileAssociation, systemWide bool) (string, string) {
+	if systemWide {
+		return systemClassesKey, systemCreatedKey + `\` + a.ProgID + `\` + a.Extension
+	}
+
+	return userClassesKey, userCreatedKey + `\` + a.ProgID + `\` + a.Extension
+}
+
+// createdByUs returns what we created to register a file association, as recorded in the given
+// key.
+func createdByUs(createdKey string) (map[string]bool, error) {
+	ret := make(map[string]bool)
+
+	key, err := openRegistryKey(createdKey, registry.QUERY_VALUE)
+	if err == registry.ErrNotExist {
+		return ret, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer key.Close()
+
+	names, err := key.ReadValueNames(-1)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range names {
+		ret[name] = true
+	}
+
+	return ret, nil
+}
+
+// userChoiceKey returns the key where Explorer stores the program picked by the user for the given
+// extension.
+func userChoiceKey(extension string) string {
+	return `HKCU\Software\Microsoft\Windows\CurrentVersion\Explorer\FileExts\` + extension + `\UserChoice`
+}
+
+// registryValueExists returns whether the given value exists.
+func registryValueExists(path string, name string) (bool, error) {
+	key, err := openRegistryKey(path, registry.QUERY_VALUE)
+	if err == registry.ErrNotExist {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	defer key.Close()
+
+	_, _, err = key.GetValue(name, nil)
+	if err == registry.ErrNotExist {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// getRegistryString returns the given string value, or an empty string if it or its key don't exist.
+func getRegistryString(path string, name string) (string, error) {
+	key, err := openRegistryKey(path, registry.QUERY_VALUE)
+	if err == registry.ErrNotExist {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	defer key.Close()
+
+	ret, _, err := key.GetStringValue(name)
+	if err == registry.ErrNotExist {
+		return "", nil
+	}
+
+	return ret, err
+}
+
+// setRegistryString sets the given string value, creating its key if missing.
+func setRegistryString(path string, name string, value string) error {
+	key, err := createRegistryKey(path, registry.SET_VALUE)
+	if err != nil {
+		return err
+	}
+	defer key.Close()
+
+	return key.SetStringValue(name, value)
+}
+
+// notifyAssociationsChanged tells Explorer to reload file associations.
+func notifyAssociationsChanged() {
+	procSHChangeNotify.Call(shcneAssocChanged, 0, 0, 0)
+}
@@ -0,0 +1,81 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package platform
+
+import (
+	"syscall"
+	"unicode/utf16"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	procCredReadW = syscall.NewLazyDLL("advapi32.dll").NewProc("CredReadW")
+	procCredFree  = syscall.NewLazyDLL("advapi32.dll").NewProc("CredFree")
+)
+
+// credTypeGeneric is CRED_TYPE_GENERIC, the type of credentials added with "cmdkey /generic".
+const credTypeGeneric = 1
+
+// credential mirrors CREDENTIALW.
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        windows.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// Credential returns the user name and password of the generic credential with the given target
+// name in the Windows Credential Manager (i.e. added with "cmdkey /generic:target /user:name
+// /pass").
+func Credential(target string) (string, string, error) {
+	targetPtr, err := windows.UTF16PtrFromString(target)
+	if err != nil {
+		return "", "", err
+	}
+
+	var cred *credential
+	if ret, _, err := procCredReadW.Call(uintptr(unsafe.Pointer(targetPtr)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred))); ret == 0 {
+		return "", "", err
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+
+	// Passwords are stored as UTF-16, without a terminator.
+	var password []uint16
+	if cred.CredentialBlobSize > 0 {
+		blob := (*[1 << 20]byte)(unsafe.Pointer(cred.CredentialBlob))[:cred.CredentialBlobSize:cred.CredentialBlobSize]
+		for i := 0; i+1 < len(blob); i += 2 {
+			password = append(password, uint16(blob[i])|uint16(blob[i+1])<<8)
+		}
+	}
+
+	var user string
+	if cred.UserName != nil {
+		// UTF16ToString stops at the terminator.
+		user = windows.UTF16ToString((*[1 << 16]uint16)(unsafe.Pointer(cred.UserName))[:])
+	}
+
+	return user, string(utf16.Decode(password)), nil
+}
@@ -0,0 +1,49 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+//go:build !windows
+// +build !windows
+
+package platform
+
+import (
+	"errors"
+	"os"
+)
+
+var errNoPersistentEnv = errors.New("persistent environment variables are only available on Windows")
+
+// IsElevated returns whether we are running with administrative privileges.
+func IsElevated() bool {
+	return os.Geteuid() == 0
+}
+
+// PersistentEnv returns the value of the given persistent environment variable.
+func PersistentEnv(name string, systemWide bool) (string, bool, error) {
+	return "", false, errNoPersistentEnv
+}
+
+// SetPersistentEnv persistently sets the given environment variable.
+func SetPersistentEnv(name string, value string, systemWide bool) error {
+	return errNoPersistentEnv
+}
+
+// UnsetPersistentEnv removes the given persistent environment variable.
+func UnsetPersistentEnv(name string, systemWide bool) error {
+	return errNoPersistentEnv
+}
+
+// BroadcastEnvironmentChange tells running programs that persistent environment variables changed.
+func BroadcastEnvironmentChange() {}
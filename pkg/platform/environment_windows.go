@@ -0,0 +1,117 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package platform
+
+import (
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+)
+
+// Where persistent environment variables are stored.
+const (
+	userEnvironmentKey   = `HKCU\Environment`
+	systemEnvironmentKey = `HKLM\SYSTEM\CurrentControlSet\Control\Session Manager\Environment`
+)
+
+// Used to tell other programs that the environment changed.
+const (
+	hwndBroadcast    = 0xffff
+	wmSettingChange  = 0x001a
+	smtoAbortIfHung  = 0x0002
+	broadcastTimeout = 5000 // Milliseconds
+)
+
+var procSendMessageTimeout = windows.NewLazySystemDLL("user32.dll").NewProc("SendMessageTimeoutW")
+
+// IsElevated returns whether we are running with administrative privileges.
+func IsElevated() bool {
+	return windows.GetCurrentProcessToken().IsElevated()
+}
+
+// PersistentEnv returns the value of the given persistent environment variable of the current user,
+// or of all users if systemWide is set, and whether it is set at all.
+func PersistentEnv(name string, systemWide bool) (string, bool, error) {
+	key, err := openRegistryKey(environmentKey(systemWide), registry.QUERY_VALUE)
+	if err != nil {
+		return "", false, err
+	}
+	defer key.Close()
+
+	value, _, err := key.GetStringValue(name)
+	if err == registry.ErrNotExist {
+		return "", false, nil
+	} else if err != nil {
+		return "", false, err
+	}
+
+	return value, true, nil
+}
+
+// SetPersistentEnv persistently sets the given environment variable for the current user, or for
+// all users if systemWide is set. Values referencing other variables (i.e. "%ProgramFiles%") are
+// expanded by Windows when read.
+func SetPersistentEnv(name string, value string, systemWide bool) error {
+	key, err := openRegistryKey(environmentKey(systemWide), registry.SET_VALUE)
+	if err != nil {
+		return err
+	}
+	defer key.Close()
+
+	if strings.Contains(value, "%") {
+		return key.SetExpandStringValue(name, value)
+	}
+
+	return key.SetStringValue(name, value)
+}
+
+// UnsetPersistentEnv removes the given persistent environment variable of the current user, or of
+// all users if systemWide is set. It is not an error if it isn't set.
+func UnsetPersistentEnv(name string, systemWide bool) error {
+	key, err := openRegistryKey(environmentKey(systemWide), registry.SET_VALUE)
+	if err != nil {
+		return err
+	}
+	defer key.Close()
+
+	if err := key.DeleteValue(name); err != registry.ErrNotExist {
+		return err
+	}
+
+	return nil
+}
+
+// BroadcastEnvironmentChange tells running programs (i.e. Explorer) that persistent environment
+// variables changed, so that programs started afterwards see them.
+func BroadcastEnvironmentChange() {
+	environment, err := windows.UTF16PtrFromString("Environment")
+	if err != nil {
+		return
+	}
+
+	var result uintptr
+	procSendMessageTimeout.Call(hwndBroadcast, wmSettingChange, 0, uintptr(unsafe.Pointer(environment)), smtoAbortIfHung, broadcastTimeout, uintptr(unsafe.Pointer(&result)))
+}
+
+func environmentKey(systemWide bool) string {
+	if systemWide {
+		return systemEnvironmentKey
+	}
+
+	return userEnvironmentKey
+}
@@ -0,0 +1,42 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package platform
+
+import (
+	"path"
+	"strings"
+)
+
+// InstalledProgram is a program listed in the "Programs and Features" section of the Control Panel,
+// whether or not it was installed by just-install.
+type InstalledProgram struct {
+	DisplayName    string
+	DisplayVersion string
+}
+
+// FindInstalledProgram returns the first of the given programs whose display name matches the given
+// pattern (i.e. "Mozilla Firefox *"), ignoring case. See path.Match for the syntax of patterns.
+func FindInstalledProgram(programs []InstalledProgram, pattern string) (InstalledProgram, bool) {
+	pattern = strings.ToLower(pattern)
+
+	for _, p := range programs {
+		if matched, _ := path.Match(pattern, strings.ToLower(p.DisplayName)); matched {
+			return p, true
+		}
+	}
+
+	return InstalledProgram{}, false
+}
@@ -0,0 +1,51 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package platform
+
+import "testing"
+
+func TestFindInstalledProgram(t *testing.T) {
+	programs := []InstalledProgram{
+		{"7-Zip 19.00 (x64)", "19.00"},
+		{"Mozilla Firefox 81.0 (x64 en-US)", "81.0"},
+		{"Mozilla Maintenance Service", "81.0"},
+		{"Git version 2.28.0", "2.28.0"},
+	}
+
+	tests := []struct {
+		name     string
+		pattern  string
+		expected string // Display name of the program found, if any
+	}{
+		{"exact name", "Mozilla Maintenance Service", "Mozilla Maintenance Service"},
+		{"wildcard", "Mozilla Firefox *", "Mozilla Firefox 81.0 (x64 en-US)"},
+		{"case insensitive", "git VERSION *", "Git version 2.28.0"},
+		{"first match", "Mozilla *", "Mozilla Firefox 81.0 (x64 en-US)"},
+		{"single character", "7-Zip ??.?? (x64)", "7-Zip 19.00 (x64)"},
+		{"prefix only", "Mozilla", ""},
+		{"not installed", "VLC media player", ""},
+		{"invalid pattern", "Mozilla [", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			program, ok := FindInstalledProgram(programs, test.pattern)
+			if ok != (test.expected != "") || program.DisplayName != test.expected {
+				t.Errorf("expected %q, got %q (%v)", test.expected, program.DisplayName, ok)
+			}
+		})
+	}
+}
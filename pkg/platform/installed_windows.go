@@ -0,0 +1,64 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package platform
+
+import "golang.org/x/sys/windows/registry"
+
+// uninstallKeys are the registry keys that list installed programs, for all users (both 64-bit and
+// 32-bit programs) and for the current user.
+var uninstallKeys = []string{
+	`HKLM\SOFTWARE\Microsoft\Windows\CurrentVersion\Uninstall`,
+	`HKLM\SOFTWARE\WOW6432Node\Microsoft\Windows\CurrentVersion\Uninstall`,
+	`HKCU\SOFTWARE\Microsoft\Windows\CurrentVersion\Uninstall`,
+}
+
+// InstalledPrograms returns the programs listed in the "Programs and Features" section of the
+// Control Panel. Entries without a display name, i.e. updates and components, are left out.
+func InstalledPrograms() ([]InstalledProgram, error) {
+	var ret []InstalledProgram
+
+	for _, path := range uninstallKeys {
+		key, err := openRegistryKey(path, registry.ENUMERATE_SUB_KEYS)
+		if err == registry.ErrNotExist {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		subkeys, err := key.ReadSubKeyNames(-1)
+		key.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, subkey := range subkeys {
+			program, err := openRegistryKey(path+`\`+subkey, registry.QUERY_VALUE)
+			if err != nil {
+				continue
+			}
+
+			name, _, _ := program.GetStringValue("DisplayName")
+			version, _, _ := program.GetStringValue("DisplayVersion")
+			program.Close()
+
+			if name != "" {
+				ret = append(ret, InstalledProgram{name, version})
+			}
+		}
+	}
+
+	return ret, nil
+}
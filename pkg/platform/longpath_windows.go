@@ -0,0 +1,90 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package platform
+
+import (
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+
+	"golang.org/x/sys/windows"
+)
+
+// maxPath is the length, in UTF-16 code units, from which Windows APIs need the `\\?\` prefix to
+// accept a path. It's 12 less than MAX_PATH, since that's the limit for directories.
+const maxPath = 260 - 12
+
+// LongPath returns the given path in a form that Windows APIs accept regardless of its length:
+// absolute and with the `\\?\` prefix, if it's too long for them otherwise. Functions of the os
+// package do this by themselves, only direct calls to Windows APIs need it.
+func LongPath(path string) string {
+	if pathLength(path) < maxPath || strings.HasPrefix(path, `\\?\`) {
+		return path
+	}
+
+	abs, err := filepath.Abs(path) // The prefix turns off normalization
+	if err != nil {
+		return path
+	}
+
+	if strings.HasPrefix(abs, `\\`) {
+		return `\\?\UNC\` + abs[2:]
+	}
+
+	return `\\?\` + abs
+}
+
+// ShortPath returns a path to the given existing file that programs without support for long paths
+// (i.e. older installers) can open: its short, 8.3, form, if it's too long for them. The path is
+// returned as-is if it's short enough, or if the volume has no short names.
+func ShortPath(path string) string {
+	if pathLength(path) < maxPath {
+		return path
+	}
+
+	longPtr, err := windows.UTF16PtrFromString(LongPath(path))
+	if err != nil {
+		return path
+	}
+
+	n, err := windows.GetShortPathName(longPtr, nil, 0)
+	if err != nil || n == 0 {
+		return path
+	}
+
+	buf := make([]uint16, n)
+	if n, err = windows.GetShortPathName(longPtr, &buf[0], n); err != nil || int(n) >= len(buf) {
+		return path
+	}
+
+	ret := windows.UTF16ToString(buf[:n])
+	if strings.HasPrefix(ret, `\\?\UNC\`) {
+		ret = `\\` + ret[len(`\\?\UNC\`):]
+	} else {
+		ret = strings.TrimPrefix(ret, `\\?\`)
+	}
+
+	if pathLength(ret) >= maxPath {
+		return path
+	}
+
+	return ret
+}
+
+// pathLength returns the length of the given path as seen by Windows APIs, in UTF-16 code units.
+func pathLength(path string) int {
+	return len(utf16.Encode([]rune(path)))
+}
@@ -0,0 +1,102 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package platform
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPathLength(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected int
+	}{
+		{`C:\Users\bob`, 12},
+		{`C:\Users\Jürgen`, 15}, // One code unit, two UTF-8 bytes
+		{`C:\Users\山田`, 11},     // One code unit, three UTF-8 bytes each
+		{`C:\Users\😀`, 11},      // Two code units (a surrogate pair), four UTF-8 bytes
+		{strings.Repeat("é", 300), 300},
+	}
+
+	for _, test := range tests {
+		if length := pathLength(test.path); length != test.expected {
+			t.Errorf("%v: expected %v, got %v", test.path, test.expected, length)
+		}
+	}
+}
+
+func TestLongPath(t *testing.T) {
+	long := `C:\` + strings.Repeat(`directory\`, 30) + "file.exe"
+	unicode := `C:\Users\山田\` + strings.Repeat(`ディレクトリ\`, 40) + "file.exe"
+
+	tests := []struct {
+		name     string
+		path     string
+		expected string
+	}{
+		{"short", `C:\Users\bob\file.exe`, `C:\Users\bob\file.exe`},
+		{"short non-ASCII", `C:\Users\山田\file.exe`, `C:\Users\山田\file.exe`},
+		{"long", long, `\\?\` + long},
+		{"long non-ASCII", unicode, `\\?\` + unicode},
+		{"long UNC", `\\server\share\` + strings.Repeat(`directory\`, 30), `\\?\UNC\server\share\` + strings.Repeat(`directory\`, 30)},
+		{"already prefixed", `\\?\` + long, `\\?\` + long},
+		{"normalized", `C:\` + strings.Repeat(`directory\..\`, 30) + "file.exe", `\\?\C:\file.exe`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := LongPath(test.path); got != test.expected {
+				t.Errorf("expected %v, got %v", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestLongUnicodePathOnDisk(t *testing.T) {
+	base, err := ioutil.TempDir("", "just-install-ユニコード")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(base)
+
+	dir := filepath.Join(base, strings.Repeat("ディレクトリ-directory-", 8), strings.Repeat("Jürgen-", 20))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "setup.exe")
+	if pathLength(path) < maxPath {
+		t.Fatalf("%v is not long enough", path)
+	}
+
+	if err := ioutil.WriteFile(path, []byte("installer"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := FreeDiskSpace(dir); err != nil {
+		t.Errorf("could not query free space of %v: %v", dir, err)
+	}
+
+	if short := ShortPath(path); short != path {
+		if data, err := ioutil.ReadFile(short); err != nil || string(data) != "installer" {
+			t.Errorf("could not read %v through its short path %v: %v", path, short, err)
+		}
+	}
+}
@@ -16,6 +16,7 @@
 package platform
 
 import (
+	"fmt"
 	"os"
 	"strings"
 
@@ -61,3 +62,39 @@ func Is64Bit() bool {
 
 	return len(sentinel) > 0 && dry.FileIsDir(sentinel)
 }
+
+// NativeArch returns the architecture of the running Windows installation, one of "x86", "x86_64"
+// or "aarch64", along with how it was determined. Since we might be a 32-bit process running under
+// WoW64, PROCESSOR_ARCHITEW6432 (only set under WoW64) takes precedence over PROCESSOR_ARCHITECTURE
+// (which reports the architecture of the current process). Without either, it falls back to
+// Is64Bit. Any other architecture (i.e. IA64) results in an error.
+func NativeArch() (string, string, error) {
+	for _, name := range []string{"PROCESSOR_ARCHITEW6432", "PROCESSOR_ARCHITECTURE"} {
+		value := os.Getenv(name)
+
+		switch strings.ToUpper(value) {
+		case "":
+			continue
+		case "AMD64":
+			return "x86_64", name + "=" + value, nil
+		case "ARM64":
+			return "aarch64", name + "=" + value, nil
+		case "X86":
+			if name == "PROCESSOR_ARCHITECTURE" && Is64Bit() {
+				// Shouldn't happen since WoW64 sets PROCESSOR_ARCHITEW6432, but the environment might
+				// have been tampered with: trust what is on disk.
+				return "x86_64", "found %ProgramFiles(x86)%, despite " + name + "=" + value, nil
+			}
+
+			return "x86", name + "=" + value, nil
+		default:
+			return "", "", fmt.Errorf("unsupported architecture: %v=%v", name, value)
+		}
+	}
+
+	if Is64Bit() {
+		return "x86_64", "found %ProgramFiles(x86)%", nil
+	}
+
+	return "x86", "no %ProgramFiles(x86)%", nil
+}
@@ -0,0 +1,88 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package platform
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestNativeArch(t *testing.T) {
+	programFilesX86, err := ioutil.TempDir("", "just-install-programfiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(programFilesX86)
+
+	tests := []struct {
+		name         string
+		architecture string // PROCESSOR_ARCHITECTURE
+		architew6432 string // PROCESSOR_ARCHITEW6432
+		is64Bit      bool   // Whether %ProgramFiles(x86)% exists
+		expected     string // Empty if unsupported
+		reason       string
+	}{
+		{"64-bit process", "AMD64", "", true, "x86_64", "PROCESSOR_ARCHITECTURE=AMD64"},
+		{"32-bit process under WoW64", "x86", "AMD64", true, "x86_64", "PROCESSOR_ARCHITEW6432=AMD64"},
+		{"32-bit process under WoW64 on ARM64", "x86", "ARM64", true, "aarch64", "PROCESSOR_ARCHITEW6432=ARM64"},
+		{"ARM64 process", "ARM64", "", true, "aarch64", "PROCESSOR_ARCHITECTURE=ARM64"},
+		{"WoW64 takes precedence", "AMD64", "x86", false, "x86", "PROCESSOR_ARCHITEW6432=x86"},
+		{"32-bit Windows", "x86", "", false, "x86", "PROCESSOR_ARCHITECTURE=x86"},
+		{"case insensitive", "amd64", "", false, "x86_64", "PROCESSOR_ARCHITECTURE=amd64"},
+		{"tampered environment", "x86", "", true, "x86_64", "found %ProgramFiles(x86)%, despite PROCESSOR_ARCHITECTURE=x86"},
+		{"no environment on 64-bit Windows", "", "", true, "x86_64", "found %ProgramFiles(x86)%"},
+		{"no environment on 32-bit Windows", "", "", false, "x86", "no %ProgramFiles(x86)%"},
+		{"Itanium", "IA64", "", true, "", ""},
+		{"unknown architecture", "MIPS", "", false, "", ""},
+	}
+
+	env := []string{"PROCESSOR_ARCHITECTURE", "PROCESSOR_ARCHITEW6432", "ProgramFiles(x86)"}
+	for _, name := range env {
+		if value, ok := os.LookupEnv(name); ok {
+			defer os.Setenv(name, value)
+		} else {
+			defer os.Unsetenv(name)
+		}
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			os.Setenv("PROCESSOR_ARCHITECTURE", test.architecture)
+			os.Setenv("PROCESSOR_ARCHITEW6432", test.architew6432)
+			if test.is64Bit {
+				os.Setenv("ProgramFiles(x86)", programFilesX86)
+			} else {
+				os.Setenv("ProgramFiles(x86)", "")
+			}
+
+			arch, reason, err := NativeArch()
+			if test.expected == "" {
+				if err == nil {
+					t.Errorf("expected an error, got %v (%v)", arch, reason)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if arch != test.expected || reason != test.reason {
+				t.Errorf("expected %v (%v), got %v (%v)", test.expected, test.reason, arch, reason)
+			}
+		})
+	}
+}
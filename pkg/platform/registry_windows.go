@@ -0,0 +1,142 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package platform
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// RegistryKeyExists returns whether the given Windows registry key exists. The key must start with
+// the name of a root key, either in its long (i.e. "HKEY_LOCAL_MACHINE") or short (i.e. "HKLM")
+// form.
+func RegistryKeyExists(path string) (bool, error) {
+	key, err := openRegistryKey(path, registry.QUERY_VALUE)
+	if err == registry.ErrNotExist {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	defer key.Close()
+
+	return true, nil
+}
+
+// DotNetFrameworkRelease returns the "Release" value of the installed .NET Framework 4.x, or zero
+// if no version of .NET Framework 4.5 or later is installed.
+func DotNetFrameworkRelease() (uint32, error) {
+	key, err := openRegistryKey(`HKLM\SOFTWARE\Microsoft\NET Framework Setup\NDP\v4\Full`, registry.QUERY_VALUE)
+	if err == registry.ErrNotExist {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	defer key.Close()
+
+	release, _, err := key.GetIntegerValue("Release")
+	if err == registry.ErrNotExist {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	return uint32(release), nil
+}
+
+// openRegistryKey opens the given registry key, looking at the native (64-bit) view of the registry
+// on 64-bit Windows even though we are a 32-bit process.
+func openRegistryKey(path string, access uint32) (registry.Key, error) {
+	root, subkey, access, err := splitRegistryKey(path, access)
+	if err != nil {
+		return 0, err
+	}
+
+	return registry.OpenKey(root, subkey, access)
+}
+
+// createRegistryKey is like openRegistryKey, but creates the key if it doesn't exist.
+func createRegistryKey(path string, access uint32) (registry.Key, error) {
+	root, subkey, access, err := splitRegistryKey(path, access)
+	if err != nil {
+		return 0, err
+	}
+
+	key, _, err := registry.CreateKey(root, subkey, access)
+	return key, err
+}
+
+// deleteRegistryKey deletes the given registry key along with all of its subkeys. It is not an
+// error if the key doesn't exist. Unlike the other functions, it always looks at the registry view
+// of the current process, which makes no difference for keys shared by both views (i.e. those
+// below HKLM\SOFTWARE\Classes).
+func deleteRegistryKey(path string) error {
+	key, err := openRegistryKey(path, registry.ENUMERATE_SUB_KEYS)
+	if err == registry.ErrNotExist {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	subkeys, err := key.ReadSubKeyNames(-1)
+	key.Close()
+	if err != nil {
+		return err
+	}
+
+	for _, subkey := range subkeys {
+		if err := deleteRegistryKey(path + `\` + subkey); err != nil {
+			return err
+		}
+	}
+
+	root, subkey, _, err := splitRegistryKey(path, 0)
+	if err != nil {
+		return err
+	}
+
+	return registry.DeleteKey(root, subkey)
+}
+
+// splitRegistryKey splits the given registry key in its root key and subkey, returning the access
+// rights needed to look at the native view of the registry.
+func splitRegistryKey(path string, access uint32) (registry.Key, string, uint32, error) {
+	split := strings.SplitN(path, `\`, 2)
+	if len(split) != 2 {
+		return 0, "", 0, fmt.Errorf("invalid registry key: %v", path)
+	}
+
+	var root registry.Key
+	switch strings.ToUpper(split[0]) {
+	case "HKCR", "HKEY_CLASSES_ROOT":
+		root = registry.CLASSES_ROOT
+	case "HKCU", "HKEY_CURRENT_USER":
+		root = registry.CURRENT_USER
+	case "HKLM", "HKEY_LOCAL_MACHINE":
+		root = registry.LOCAL_MACHINE
+	case "HKU", "HKEY_USERS":
+		root = registry.USERS
+	default:
+		return 0, "", 0, fmt.Errorf("unknown registry root key: %v", split[0])
+	}
+
+	if Is64Bit() {
+		access |= registry.WOW64_64KEY
+	}
+
+	return root, split[1], access, nil
+}
@@ -0,0 +1,119 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package platform
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a Windows version number, as in "10.0.19041".
+type Version struct {
+	Major uint32
+	Minor uint32
+	Build uint32
+}
+
+// ParseVersion parses a version in the form "major[.minor[.build]]". Missing components are zero.
+func ParseVersion(s string) (Version, error) {
+	var ret Version
+
+	parts := strings.Split(strings.TrimSpace(s), ".")
+	if len(parts) > 3 {
+		return ret, fmt.Errorf("invalid Windows version: %v", s)
+	}
+
+	fields := []*uint32{&ret.Major, &ret.Minor, &ret.Build}
+	for i, part := range parts {
+		n, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			return ret, fmt.Errorf("invalid Windows version: %v", s)
+		}
+
+		*fields[i] = uint32(n)
+	}
+
+	return ret, nil
+}
+
+// Compare returns -1, 0 or 1 depending on whether v is older, the same or newer than other.
+func (v Version) Compare(other Version) int {
+	a := []uint32{v.Major, v.Minor, v.Build}
+	b := []uint32{other.Major, other.Minor, other.Build}
+
+	for i := range a {
+		if a[i] < b[i] {
+			return -1
+		} else if a[i] > b[i] {
+			return 1
+		}
+	}
+
+	return 0
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Build)
+}
+
+// CompareVersions returns -1, 0 or 1 depending on whether version a is older, the same or newer than
+// version b. Versions are made of any number of dot-separated numbers (i.e. "1.10.2"), optionally
+// prefixed with "v", missing components being zero. Returns false if either version isn't in this
+// form (i.e. "latest").
+func CompareVersions(a string, b string) (int, bool) {
+	parse := func(s string) ([]uint64, bool) {
+		var ret []uint64
+		for _, part := range strings.Split(strings.TrimPrefix(strings.TrimSpace(s), "v"), ".") {
+			n, err := strconv.ParseUint(part, 10, 64)
+			if err != nil {
+				return nil, false
+			}
+
+			ret = append(ret, n)
+		}
+
+		return ret, true
+	}
+
+	va, ok := parse(a)
+	if !ok {
+		return 0, false
+	}
+
+	vb, ok := parse(b)
+	if !ok {
+		return 0, false
+	}
+
+	for i := 0; i < len(va) || i < len(vb); i++ {
+		var x, y uint64
+		if i < len(va) {
+			x = va[i]
+		}
+		if i < len(vb) {
+			y = vb[i]
+		}
+
+		if x < y {
+			return -1, true
+		} else if x > y {
+			return 1, true
+		}
+	}
+
+	return 0, true
+}
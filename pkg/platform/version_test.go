@@ -0,0 +1,50 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package platform
+
+import "testing"
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		name     string
+		a        string
+		b        string
+		expected int
+		ok       bool
+	}{
+		{"same", "1.2.3", "1.2.3", 0, true},
+		{"older", "1.2.3", "1.2.4", -1, true},
+		{"newer", "1.3", "1.2.9", 1, true},
+		{"numeric, not lexical", "1.10", "1.9", 1, true},
+		{"missing components are zero", "1.2", "1.2.0.0", 0, true},
+		{"longer is newer", "1.2.0.1", "1.2", 1, true},
+		{"v prefix", "v2.0", "2.0", 0, true},
+		{"surrounding spaces", " 19.00 ", "19.0", 0, true},
+		{"leading zeros", "19.00", "19.0", 0, true},
+		{"not a version", "latest", "1.0", 0, false},
+		{"suffix", "1.0-beta", "1.0", 0, false},
+		{"empty", "", "1.0", 0, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cmp, ok := CompareVersions(test.a, test.b)
+			if cmp != test.expected || ok != test.ok {
+				t.Errorf("expected %v (%v), got %v (%v)", test.expected, test.ok, cmp, ok)
+			}
+		})
+	}
+}
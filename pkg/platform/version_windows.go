@@ -0,0 +1,49 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package platform
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var procRtlGetVersion = syscall.NewLazyDLL("ntdll.dll").NewProc("RtlGetVersion")
+
+// osVersionInfo mirrors RTL_OSVERSIONINFOW.
+type osVersionInfo struct {
+	OSVersionInfoSize uint32
+	MajorVersion      uint32
+	MinorVersion      uint32
+	BuildNumber       uint32
+	PlatformID        uint32
+	CSDVersion        [128]uint16
+}
+
+// WindowsVersion returns the version of Windows we are running on.
+//
+// This uses RtlGetVersion instead of GetVersionEx since the latter lies about the version unless
+// the executable has a manifest declaring support for it.
+func WindowsVersion() (Version, error) {
+	info := osVersionInfo{}
+	info.OSVersionInfoSize = uint32(unsafe.Sizeof(info))
+
+	// RtlGetVersion returns an NTSTATUS, zero being STATUS_SUCCESS
+	if ret, _, _ := procRtlGetVersion.Call(uintptr(unsafe.Pointer(&info))); ret != 0 {
+		return Version{}, syscall.Errno(ret)
+	}
+
+	return Version{info.MajorVersion, info.MinorVersion, info.BuildNumber}, nil
+}
@@ -0,0 +1,53 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package redact
+
+import (
+	"strings"
+	"sync"
+)
+
+// Placeholder replaces sensitive values in redacted strings.
+const Placeholder = "[redacted]"
+
+var (
+	secrets      []string
+	secretsMutex sync.RWMutex
+)
+
+// Add marks the given value as sensitive. Empty values are ignored.
+func Add(secret string) {
+	if secret == "" {
+		return
+	}
+
+	secretsMutex.Lock()
+	defer secretsMutex.Unlock()
+
+	secrets = append(secrets, secret)
+}
+
+// String returns s with all sensitive values replaced by Placeholder.
+func String(s string) string {
+	secretsMutex.RLock()
+	defer secretsMutex.RUnlock()
+
+	for _, secret := range secrets {
+		s = strings.Replace(s, secret, Placeholder, -1)
+	}
+
+	return s
+}
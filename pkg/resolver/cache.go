@@ -0,0 +1,156 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package resolver
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/just-install/just-install/pkg/paths"
+)
+
+// DefaultTTL is how long resolved results are used before asking upstream again.
+const DefaultTTL = time.Hour
+
+// cacheFileName is the name of the file, in the cache directory, where resolved results are kept.
+// It is separate from the installer cache, so that results can expire on their own.
+const cacheFileName = "resolved.json"
+
+// cacheMutex serializes access to the cache file within this process.
+var cacheMutex sync.Mutex
+
+// Options that influence resolution.
+type Options struct {
+	Context context.Context // Cancels upstream requests when done. Defaults to context.Background().
+	Force   bool            // Ask upstream again, even if a cached result hasn't expired yet.
+	TTL     time.Duration   // How long cached results are used. Defaults to DefaultTTL.
+}
+
+// cacheEntry is a cached result of a resolver.
+type cacheEntry struct {
+	Value        json.RawMessage `json:"value,omitempty"`
+	ResolvedAt   time.Time       `json:"resolvedAt"`
+	LimitedUntil time.Time       `json:"limitedUntil,omitempty"` // Upstream asked us not to come back before then
+}
+
+// cached stores in v the cached result for the given key, unless it expired or Force is set, in
+// which case resolve is called to fill v in and the result is cached. Resolve returns, along with
+// any error, the time before which upstream shouldn't be asked again (zero if it doesn't care).
+// While upstream is rate limiting us the last result is used even if expired, with a warning, and
+// without one a RateLimitError is returned.
+func cached(key string, v interface{}, options *Options, resolve func() (time.Time, error)) error {
+	ttl := options.TTL
+	if ttl == 0 {
+		ttl = DefaultTTL
+	}
+
+	cacheMutex.Lock()
+	defer cacheMutex.Unlock()
+
+	entries := loadCache()
+	entry, hit := entries[key]
+
+	useStale := func(limitedUntil time.Time) error {
+		if len(entry.Value) == 0 {
+			return &RateLimitError{Reset: limitedUntil, Resource: key}
+		}
+
+		log.Printf("WARNING: rate limited by %v until %v, using the result from %v", key, limitedUntil.Format(time.RFC3339), entry.ResolvedAt.Format(time.RFC3339))
+
+		return json.Unmarshal(entry.Value, v)
+	}
+
+	if hit && !options.Force && len(entry.Value) > 0 && time.Since(entry.ResolvedAt) < ttl {
+		return json.Unmarshal(entry.Value, v)
+	}
+
+	if hit && time.Now().Before(entry.LimitedUntil) {
+		return useStale(entry.LimitedUntil)
+	}
+
+	limitedUntil, err := resolve()
+	if rateLimitErr, ok := err.(*RateLimitError); ok {
+		entry.LimitedUntil = rateLimitErr.Reset
+		entries[key] = entry
+		saveCache(entries)
+
+		return useStale(rateLimitErr.Reset)
+	} else if err != nil {
+		return err
+	}
+
+	value, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	entries[key] = cacheEntry{Value: value, ResolvedAt: time.Now(), LimitedUntil: limitedUntil}
+	saveCache(entries)
+
+	return nil
+}
+
+// loadCache returns the cached results, or an empty cache if there are none or they cannot be read.
+func loadCache() map[string]cacheEntry {
+	ret := make(map[string]cacheEntry)
+
+	path, err := paths.CacheFileCreate(cacheFileName)
+	if err != nil {
+		return ret
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ret
+	}
+
+	if err := json.Unmarshal(data, &ret); err != nil {
+		log.Println("WARNING: ignoring corrupted resolver cache:", err)
+		return make(map[string]cacheEntry)
+	}
+
+	return ret
+}
+
+// saveCache writes the given results to the cache. Failures only cost an upstream request next
+// time, so they are logged rather than returned.
+func saveCache(entries map[string]cacheEntry) {
+	path, err := paths.CacheFileCreate(cacheFileName)
+	if err != nil {
+		log.Println("WARNING: could not save the resolver cache:", err)
+		return
+	}
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		log.Println("WARNING: could not save the resolver cache:", err)
+		return
+	}
+
+	if err := ioutil.WriteFile(path+".tmp", data, 0600); err != nil {
+		log.Println("WARNING: could not save the resolver cache:", err)
+		return
+	}
+
+	if err := os.Rename(path+".tmp", path); err != nil {
+		log.Println("WARNING: could not save the resolver cache:", err)
+	}
+}
@@ -0,0 +1,163 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package resolver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/just-install/just-install/pkg/fetch"
+	"github.com/just-install/just-install/pkg/redact"
+)
+
+// gitHubAPI is the base URL of the GitHub REST API.
+var gitHubAPI = "https://api.github.com"
+
+// defaultBackoff is how long to wait after being rate limited, if upstream doesn't say.
+const defaultBackoff = time.Minute
+
+// gitHubToken authenticates requests to the GitHub API, if not empty, for a higher rate limit.
+var gitHubToken string
+
+// SetGitHubToken makes requests to the GitHub API authenticate with the given token, which is never
+// logged. Anonymous requests have a much lower rate limit, which a fleet of machines sharing the same
+// address exhausts quickly.
+func SetGitHubToken(token string) {
+	redact.Add(token)
+	gitHubToken = token
+}
+
+// RateLimitError is returned when upstream refuses to answer until the given time.
+type RateLimitError struct {
+	Reset    time.Time
+	Resource string
+}
+
+func (r *RateLimitError) Error() string {
+	if strings.HasPrefix(r.Resource, gitHubAPI) && gitHubToken == "" {
+		return fmt.Sprintf("rate limited until %v (%v), authenticate with a GitHub token for a higher limit", r.Reset.Format(time.RFC3339), r.Resource)
+	}
+
+	return fmt.Sprintf("rate limited until %v (%v)", r.Reset.Format(time.RFC3339), r.Resource)
+}
+
+// Release is the subset of a GitHub release that we care about.
+type Release struct {
+	TagName string  `json:"tag_name"`
+	Assets  []Asset `json:"assets"`
+}
+
+// Asset is a file attached to a GitHub release.
+type Asset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+// Asset returns the download URL of the asset with the given name, if any.
+func (r *Release) Asset(name string) (string, bool) {
+	for _, a := range r.Assets {
+		if strings.EqualFold(a.Name, name) {
+			return a.URL, true
+		}
+	}
+
+	return "", false
+}
+
+// GitHubLatestRelease returns the latest release of the given GitHub repository, in the
+// "owner/name" form. Results are cached as described by Options.
+func GitHubLatestRelease(repo string, options *Options) (*Release, error) {
+	if options == nil {
+		options = &Options{}
+	}
+
+	ctx := options.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	resource := fmt.Sprintf("%v/repos/%v/releases/latest", gitHubAPI, repo)
+	ret := &Release{}
+
+	// Authenticated requests have their own rate limit, a token lifts the anonymous one right away
+	key := resource
+	if gitHubToken != "" {
+		key += "#authenticated"
+	}
+
+	err := cached(key, ret, options, func() (time.Time, error) {
+		req, err := http.NewRequest("GET", resource, nil)
+		if err != nil {
+			return time.Time{}, err
+		}
+		req = req.WithContext(ctx)
+		req.Header.Set("Accept", "application/vnd.github.v3+json")
+		if gitHubToken != "" {
+			req.Header.Set("Authorization", "Bearer "+gitHubToken)
+		}
+
+		resp, err := fetch.NewClient().Do(req)
+		if err != nil {
+			return time.Time{}, err
+		}
+		defer resp.Body.Close()
+
+		if reset, limited := gitHubRateLimit(resp); limited && resp.StatusCode != http.StatusOK {
+			return time.Time{}, &RateLimitError{Reset: reset, Resource: resource}
+		} else if resp.StatusCode == http.StatusUnauthorized && gitHubToken != "" {
+			return time.Time{}, fmt.Errorf("GitHub refused the token, it is invalid or expired (%v)", resource)
+		} else if resp.StatusCode != http.StatusOK {
+			return time.Time{}, &fetch.HTTPStatusError{Expected: http.StatusOK, Received: resp.StatusCode, Resource: resource}
+		} else if err := json.NewDecoder(resp.Body).Decode(ret); err != nil {
+			return time.Time{}, err
+		} else if limited {
+			// This was the last request we were allowed to make, don't make another one too soon.
+			return reset, nil
+		}
+
+		return time.Time{}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// gitHubRateLimit returns whether the response says that we ran out of requests, and until when.
+// See https://docs.github.com/en/rest/overview/resources-in-the-rest-api#rate-limiting.
+func gitHubRateLimit(resp *http.Response) (time.Time, bool) {
+	if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
+		if seconds, err := strconv.Atoi(retryAfter); err == nil {
+			return time.Now().Add(time.Duration(seconds) * time.Second), true
+		}
+	}
+
+	if resp.Header.Get("X-RateLimit-Remaining") != "0" {
+		return time.Time{}, false
+	}
+
+	if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		return time.Unix(reset, 0), true
+	}
+
+	return time.Now().Add(defaultBackoff), true
+}
@@ -0,0 +1,196 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/just-install/just-install/pkg/fetch"
+)
+
+// Resolver finds the version and the installers of a package when it is installed, for registry
+// entries that reference it by name (i.e. to always install the latest release of a project).
+type Resolver interface {
+	// Resolve returns the current version of a package and its installers, given the parameters of
+	// its registry entry.
+	Resolve(ctx context.Context, params map[string]string) (*Resolution, error)
+}
+
+// Resolution is the outcome of resolving a package.
+type Resolution struct {
+	Version string
+	X86     string // Installer URL for x86, if any
+	X86_64  string // Installer URL for x86_64, if any
+}
+
+var resolvers = struct {
+	sync.RWMutex
+	byName map[string]Resolver
+}{byName: make(map[string]Resolver)}
+
+func init() {
+	RegisterResolver("github", gitHubResolver{})
+	RegisterResolver("latest", latestResolver{})
+}
+
+// RegisterResolver makes the given resolver available to registry entries under the given name.
+// Registering a resolver with the name of an existing one, including the built-in "github" and
+// "latest" ones, replaces it.
+func RegisterResolver(name string, r Resolver) {
+	if r == nil {
+		panic("resolver: RegisterResolver with a nil resolver")
+	}
+
+	resolvers.Lock()
+	defer resolvers.Unlock()
+
+	resolvers.byName[name] = r
+}
+
+// Lookup returns the resolver registered under the given name.
+func Lookup(name string) (Resolver, error) {
+	resolvers.RLock()
+	defer resolvers.RUnlock()
+
+	r, ok := resolvers.byName[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown resolver: %v", name)
+	}
+
+	return r, nil
+}
+
+// Names returns the names of all registered resolvers, sorted alphabetically.
+func Names() []string {
+	resolvers.RLock()
+	defer resolvers.RUnlock()
+
+	var ret []string
+	for name := range resolvers.byName {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+
+	return ret
+}
+
+// gitHubResolver resolves packages to the assets of the latest release of a GitHub repository. Its
+// parameters are the `repo` ("owner/name") and, for `x86` and `x86_64`, a pattern matching the name
+// of the asset to install (i.e. "*-win64.msi"). The version is the tag name, without the "v" prefix.
+type gitHubResolver struct{}
+
+func (gitHubResolver) Resolve(ctx context.Context, params map[string]string) (*Resolution, error) {
+	if params["repo"] == "" {
+		return nil, fmt.Errorf("the github resolver requires a repo")
+	}
+
+	release, err := GitHubLatestRelease(params["repo"], &Options{Context: ctx})
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &Resolution{Version: strings.TrimPrefix(release.TagName, "v")}
+
+	for _, arch := range []struct {
+		name string
+		url  *string
+	}{{"x86", &ret.X86}, {"x86_64", &ret.X86_64}} {
+		pattern := params[arch.name]
+		if pattern == "" {
+			continue
+		}
+
+		for _, asset := range release.Assets {
+			if matched, err := path.Match(pattern, asset.Name); err != nil {
+				return nil, fmt.Errorf("invalid %v asset pattern %q: %w", arch.name, pattern, err)
+			} else if matched {
+				*arch.url = asset.URL
+				break
+			}
+		}
+
+		if *arch.url == "" {
+			return nil, fmt.Errorf("release %v of %v has no asset matching %q", release.TagName, params["repo"], pattern)
+		}
+	}
+
+	return ret, nil
+}
+
+// latestResolver resolves packages whose `x86` and `x86_64` parameters are URLs redirecting to the
+// latest installer, to the URLs they redirect to. The version is extracted from the URL of the x86
+// installer (or of the x86_64 one if missing) with the `version` parameter, a regular expression
+// whose first group is the version.
+type latestResolver struct{}
+
+func (latestResolver) Resolve(ctx context.Context, params map[string]string) (*Resolution, error) {
+	versionRegexp, err := regexp.Compile(params["version"])
+	if err != nil || versionRegexp.NumSubexp() < 1 {
+		return nil, fmt.Errorf("the latest resolver requires a version regular expression with one group")
+	}
+
+	ret := &Resolution{}
+
+	for _, arch := range []struct {
+		name string
+		url  *string
+	}{{"x86_64", &ret.X86_64}, {"x86", &ret.X86}} {
+		if params[arch.name] == "" {
+			continue
+		}
+
+		if *arch.url, err = redirectTarget(ctx, params[arch.name]); err != nil {
+			return nil, err
+		}
+
+		if match := versionRegexp.FindStringSubmatch(*arch.url); match != nil {
+			ret.Version = match[1]
+		}
+	}
+
+	if ret.Version == "" {
+		return nil, fmt.Errorf("could not find the version in %v", params["x86"]+params["x86_64"])
+	}
+
+	return ret, nil
+}
+
+// redirectTarget returns the URL that the given one eventually redirects to.
+func redirectTarget(ctx context.Context, rawurl string) (string, error) {
+	req, err := http.NewRequest("HEAD", rawurl, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := fetch.NewClient().Do(req.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return "", &fetch.HTTPStatusError{Expected: http.StatusOK, Received: resp.StatusCode, Resource: rawurl}
+	}
+
+	return resp.Request.URL.String(), nil
+}
@@ -0,0 +1,265 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ungerik/go-dry"
+
+	"github.com/just-install/just-install/pkg/lock"
+	"github.com/just-install/just-install/pkg/paths"
+)
+
+const stateFileName = "state.json"
+
+// stateLockTimeout is how long to wait for someone else to finish updating the state file.
+const stateLockTimeout = 10 * time.Second
+
+// State is the list of packages installed by just-install. It is safe to record, forget and save
+// packages from multiple goroutines at the same time.
+type State struct {
+	Packages map[string]Package `json:"packages"`
+
+	mutex sync.Mutex
+	dirty map[string]bool // Packages recorded or forgotten since the last save
+}
+
+// Package is a package installed by just-install.
+type Package struct {
+	Version     string            `json:"version"`            // Version installed last
+	Versions    map[string]string `json:"versions,omitempty"` // Version installed for each architecture
+	Arch        string            `json:"arch"`               // Architecture installed last
+	Archs       []string          `json:"archs,omitempty"`    // All installed architectures
+	Identity    string            `json:"identity,omitempty"` // Identity of MSIX packages, to uninstall them
+	InstalledAt time.Time         `json:"installedAt"`
+}
+
+// Architectures returns all installed architectures of the package. State files written before
+// they were tracked only know about the one installed last.
+func (p Package) Architectures() []string {
+	if len(p.Archs) > 0 {
+		return p.Archs
+	}
+
+	if p.Arch != "" {
+		return []string{p.Arch}
+	}
+
+	return nil
+}
+
+// VersionOf returns the version the package is installed in for the given architecture. State files
+// written before versions were tracked for each architecture only know about the one installed last.
+func (p Package) VersionOf(arch string) string {
+	if version, ok := p.Versions[arch]; ok {
+		return version
+	}
+
+	return p.Version
+}
+
+// Has returns whether the package is installed for the given architecture.
+func (p Package) Has(arch string) bool {
+	for _, a := range p.Architectures() {
+		if a == arch {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Load reads the state from just-install's state directory. A missing state file results in an
+// empty state.
+func Load() (*State, error) {
+	path, err := paths.StateFileCreate(stateFileName)
+	if err != nil {
+		return nil, fmt.Errorf("could not create state directory: %w", err)
+	}
+
+	packages, err := readPackages(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &State{Packages: packages}, nil
+}
+
+// readPackages reads the packages from the state file at the given path.
+func readPackages(path string) (map[string]Package, error) {
+	ret := &State{Packages: make(map[string]Package)}
+	if !dry.FileExists(path) {
+		return ret.Packages, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read state file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, ret); err != nil {
+		return nil, fmt.Errorf("could not parse state file %v: %w", path, err)
+	}
+
+	if ret.Packages == nil {
+		ret.Packages = make(map[string]Package)
+	}
+
+	return ret.Packages, nil
+}
+
+// Save writes the state to just-install's state directory. The state file is read again first,
+// under a lock, and only the packages recorded or forgotten since the last save are updated, so
+// that changes saved by others in the meantime are kept (and picked up). The file is replaced
+// atomically, so that an interrupted write never leaves a corrupt state behind.
+func (s *State) Save() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	path, err := paths.StateFileCreate(stateFileName)
+	if err != nil {
+		return fmt.Errorf("could not create state directory: %w", err)
+	}
+
+	l, err := lock.AcquireFile(path+".lock", stateLockTimeout)
+	if err != nil {
+		return err
+	}
+	defer l.Release()
+
+	packages, err := readPackages(path)
+	if err != nil {
+		return err
+	}
+
+	for name := range s.dirty {
+		if p, ok := s.Packages[name]; ok {
+			packages[name] = p
+		} else {
+			delete(packages, name)
+		}
+	}
+
+	data, err := json.MarshalIndent(&State{Packages: packages}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(path+".tmp", append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("could not write state file: %w", err)
+	}
+
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return fmt.Errorf("could not write state file: %w", err)
+	}
+
+	s.Packages = packages
+	s.dirty = nil
+
+	return nil
+}
+
+// markDirty marks the given package as changed since the last save. The mutex must be held.
+func (s *State) markDirty(name string) {
+	if s.dirty == nil {
+		s.dirty = make(map[string]bool)
+	}
+
+	s.dirty[name] = true
+}
+
+// Record marks the given package as installed for the given architecture, in addition to the ones
+// it was already installed for.
+func (s *State) Record(name string, version string, arch string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	previous := s.Packages[name]
+
+	archs := []string{arch}
+	versions := map[string]string{arch: version}
+	for _, a := range previous.Architectures() {
+		if a != arch {
+			archs = append(archs, a)
+			versions[a] = previous.VersionOf(a)
+		}
+	}
+	sort.Strings(archs)
+
+	s.Packages[name] = Package{Version: version, Versions: versions, Arch: arch, Archs: archs, Identity: previous.Identity, InstalledAt: time.Now()}
+	s.markDirty(name)
+}
+
+// SetIdentity records the identity of the given installed MSIX package.
+func (s *State) SetIdentity(name string, identity string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	p, ok := s.Packages[name]
+	if !ok {
+		return
+	}
+
+	p.Identity = identity
+	s.Packages[name] = p
+	s.markDirty(name)
+}
+
+// Forget marks the given package as no longer installed.
+func (s *State) Forget(name string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.Packages, name)
+	s.markDirty(name)
+}
+
+// ForgetArch marks the given package as no longer installed for the given architecture, forgetting
+// it altogether if it isn't installed for any other.
+func (s *State) ForgetArch(name string, arch string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	p, ok := s.Packages[name]
+	if !ok {
+		return
+	}
+
+	var archs []string
+	versions := make(map[string]string)
+	for _, a := range p.Architectures() {
+		if a != arch {
+			archs = append(archs, a)
+			versions[a] = p.VersionOf(a)
+		}
+	}
+
+	if len(archs) == 0 {
+		delete(s.Packages, name)
+	} else {
+		p.Arch, p.Archs, p.Versions = archs[0], archs, versions
+		p.Version = p.VersionOf(p.Arch)
+		s.Packages[name] = p
+	}
+	s.markDirty(name)
+}
@@ -0,0 +1,224 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/just-install/just-install/pkg/paths"
+)
+
+const (
+	// helperEnv tells the test binary to act as one of the processes of TestSaveConcurrently.
+	helperEnv = "JUST_INSTALL_STATE_TEST_HELPER"
+
+	stressGoroutines = 8
+	stressProcesses  = 4
+	stressPackages   = 25 // Per goroutine or process
+)
+
+// withStateDir points the state directory to a new temporary directory. It returns the directory
+// and a function that removes it and restores the previous state directory.
+func withStateDir(t *testing.T) (string, func()) {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "just-install-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previous, set := os.LookupEnv(paths.StateDirEnv)
+	if err := os.Setenv(paths.StateDirEnv, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	return dir, func() {
+		if set {
+			os.Setenv(paths.StateDirEnv, previous)
+		} else {
+			os.Unsetenv(paths.StateDirEnv)
+		}
+		os.RemoveAll(dir)
+	}
+}
+
+// recordAll records and saves, one at a time, stressPackages packages whose names start with the
+// given prefix, in a state loaded on its own.
+func recordAll(prefix string) error {
+	s, err := Load()
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < stressPackages; i++ {
+		s.Record(prefix+strconv.Itoa(i), "1.0", "x86_64")
+		if err := s.Save(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// TestSaveHelperProcess isn't a real test: it is run by TestSaveConcurrently as another process
+// saving the state at the same time.
+func TestSaveHelperProcess(t *testing.T) {
+	prefix := os.Getenv(helperEnv)
+	if prefix == "" {
+		t.Skip("only run by TestSaveConcurrently")
+	}
+
+	if err := recordAll(prefix); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSaveConcurrently(t *testing.T) {
+	dir, restore := withStateDir(t)
+	defer restore()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, stressGoroutines+stressProcesses)
+
+	for i := 0; i < stressProcesses; i++ {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestSaveHelperProcess$")
+		cmd.Env = append(os.Environ(), paths.StateDirEnv+"="+dir, fmt.Sprintf("%v=process%v-", helperEnv, i))
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if out, err := cmd.CombinedOutput(); err != nil {
+				errs <- fmt.Errorf("helper process failed: %v\n%s", err, out)
+			}
+		}()
+	}
+
+	for i := 0; i < stressGoroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			if err := recordAll(fmt.Sprintf("goroutine%v-", i)); err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+
+	s, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := (stressGoroutines + stressProcesses) * stressPackages; len(s.Packages) != expected {
+		t.Errorf("expected %v packages, found %v", expected, len(s.Packages))
+	}
+
+	for i := 0; i < stressPackages; i++ {
+		for _, name := range []string{fmt.Sprintf("goroutine%v-%v", stressGoroutines-1, i), fmt.Sprintf("process%v-%v", stressProcesses-1, i)} {
+			if !s.Packages[name].Has("x86_64") {
+				t.Errorf("lost %v", name)
+			}
+		}
+	}
+}
+
+func TestSaveSharedState(t *testing.T) {
+	_, restore := withStateDir(t)
+	defer restore()
+
+	s, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < stressGoroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			for j := 0; j < stressPackages; j++ {
+				s.Record(fmt.Sprintf("shared%v-%v", i, j), "1.0", "x86")
+				if err := s.Save(); err != nil {
+					t.Error(err)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	reloaded, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := stressGoroutines * stressPackages; len(reloaded.Packages) != expected {
+		t.Errorf("expected %v packages, found %v", expected, len(reloaded.Packages))
+	}
+}
+
+func TestRecordVersionPerArch(t *testing.T) {
+	s := &State{Packages: make(map[string]Package)}
+	s.Record("example", "1.0", "x86")
+	s.Record("example", "1.0", "x86_64")
+	s.Record("example", "2.0", "x86")
+
+	p := s.Packages["example"]
+	if p.VersionOf("x86") != "2.0" || p.VersionOf("x86_64") != "1.0" {
+		t.Errorf("expected x86 at 2.0 and x86_64 at 1.0, got %v", p.Versions)
+	}
+
+	s.ForgetArch("example", "x86")
+	if p := s.Packages["example"]; p.Version != "1.0" || p.VersionOf("x86_64") != "1.0" {
+		t.Errorf("expected x86_64 at 1.0, got %v (%v)", p.Version, p.Versions)
+	}
+
+	// State files written before versions were tracked for each architecture
+	old := Package{Version: "1.0", Arch: "x86_64"}
+	if version := old.VersionOf("x86_64"); version != "1.0" {
+		t.Errorf("expected 1.0, got %v", version)
+	}
+}
+
+func TestRecordKeepsIdentity(t *testing.T) {
+	s := &State{Packages: make(map[string]Package)}
+	s.Record("example", "1.0", "x86_64")
+	s.SetIdentity("example", "Vendor.Example")
+	s.Record("example", "2.0", "x86_64")
+
+	if identity := s.Packages["example"].Identity; identity != "Vendor.Example" {
+		t.Errorf("expected identity Vendor.Example, got %q", identity)
+	}
+
+	s.SetIdentity("unknown", "Vendor.Unknown")
+	if _, ok := s.Packages["unknown"]; ok {
+		t.Error("recorded the identity of a package that isn't installed")
+	}
+}
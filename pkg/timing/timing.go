@@ -0,0 +1,115 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package timing
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Recorder collects the duration of named phases. Phases can run more than once, even in parallel:
+// both the total time spent in them and the wall-clock time from the first start to the last end are
+// tracked. A nil Recorder is valid and records nothing.
+type Recorder struct {
+	mutex   sync.Mutex
+	phases  []*phase
+	verbose bool
+}
+
+type phase struct {
+	name  string
+	runs  int
+	total time.Duration
+	first time.Time
+	last  time.Time
+}
+
+// New returns a new Recorder. If verbose is set, the duration of each phase is logged as soon as it
+// ends.
+func New(verbose bool) *Recorder {
+	return &Recorder{verbose: verbose}
+}
+
+// Start starts timing a run of the given phase, returning a function that ends it.
+func (r *Recorder) Start(name string) func() {
+	if r == nil {
+		return func() {}
+	}
+
+	start := time.Now()
+
+	return func() {
+		end := time.Now()
+		elapsed := end.Sub(start)
+
+		if r.verbose {
+			log.Printf("%v took %v", name, elapsed.Round(time.Millisecond))
+		}
+
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+
+		p := r.phase(name)
+		p.runs++
+		p.total += elapsed
+		if p.first.IsZero() || start.Before(p.first) {
+			p.first = start
+		}
+		if end.After(p.last) {
+			p.last = end
+		}
+	}
+}
+
+// Summary returns a line for each recorded phase, in the order they were first recorded.
+func (r *Recorder) Summary() []string {
+	if r == nil {
+		return nil
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var ret []string
+	for _, p := range r.phases {
+		wall := p.last.Sub(p.first).Round(time.Millisecond)
+
+		if p.runs == 1 {
+			ret = append(ret, fmt.Sprintf("%-15v %v", p.name, wall))
+		} else {
+			ret = append(ret, fmt.Sprintf("%-15v %v wall-clock, %v total over %v runs", p.name, wall, p.total.Round(time.Millisecond), p.runs))
+		}
+	}
+
+	return ret
+}
+
+// phase returns the phase with the given name, creating it if needed. Must be called with the mutex
+// held.
+func (r *Recorder) phase(name string) *phase {
+	for _, p := range r.phases {
+		if p.name == name {
+			return p
+		}
+	}
+
+	p := &phase{name: name}
+	r.phases = append(r.phases, p)
+
+	return p
+}
@@ -0,0 +1,20 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// Package yaml converts YAML documents to JSON, so that they can be decoded like JSON ones. Only the
+// subset of YAML that makes sense for configuration files like the registry is supported: block and
+// flow collections, plain, quoted and block scalars, and comments. Anchors, aliases, tags, explicit
+// and merge keys and multiple documents are not: they are reported as errors, never read as strings.
+package yaml
@@ -0,0 +1,897 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package yaml
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// errUnterminated is returned by unquote if the string doesn't end on the current line.
+var errUnterminated = errors.New("unterminated quoted string")
+
+var (
+	intRegexp     = regexp.MustCompile(`^[-+]?[0-9]+$`)
+	octalRegexp   = regexp.MustCompile(`^0o[0-7]+$`)
+	hexRegexp     = regexp.MustCompile(`^0x[0-9a-fA-F]+$`)
+	floatRegexp   = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+	specialRegexp = regexp.MustCompile(`^([-+]?\.(inf|Inf|INF)|\.(nan|NaN|NAN))$`)
+)
+
+// ToJSON converts the given YAML document to JSON.
+func ToJSON(data []byte) ([]byte, error) {
+	return ToJSONFor(data, nil)
+}
+
+// ToJSONFor is like ToJSON, for a document meant to be decoded into v with encoding/json: plain
+// scalars that look like numbers are kept as written, as strings, wherever v expects a string. That
+// way `version: 1.10` is read as "1.10" rather than failing to decode a number into a string.
+func ToJSONFor(data []byte, v interface{}) ([]byte, error) {
+	p := &parser{lines: strings.Split(strings.Replace(string(data), "\r\n", "\n", -1), "\n")}
+
+	value, err := p.parseDocument()
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(stringify(value, reflect.TypeOf(v)))
+}
+
+// number is a plain scalar resolved to an integer or a floating point number, along with how it was
+// written.
+type number struct {
+	text  string
+	value interface{} // int64 or float64
+}
+
+func (n number) MarshalJSON() ([]byte, error) {
+	return json.Marshal(n.value)
+}
+
+// stringify replaces the numbers in the given value with their text wherever the given type, as
+// decoded by encoding/json, expects a string. A nil type expects nothing in particular.
+func stringify(value interface{}, t reflect.Type) interface{} {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch value := value.(type) {
+	case number:
+		if t != nil && t.Kind() == reflect.String {
+			return value.text
+		}
+	case map[string]interface{}:
+		for k, v := range value {
+			value[k] = stringify(v, memberType(t, k))
+		}
+	case []interface{}:
+		var elem reflect.Type
+		if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			elem = t.Elem()
+		}
+
+		for i, v := range value {
+			value[i] = stringify(v, elem)
+		}
+	}
+
+	return value
+}
+
+// memberType returns the type of the given key of a mapping decoded into the given type, or nil if
+// unknown. Struct fields are matched by their JSON name, ignoring case, as encoding/json does.
+func memberType(t reflect.Type, key string) reflect.Type {
+	if t == nil {
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Map:
+		return t.Elem()
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue // Unexported
+			}
+
+			name := field.Name
+			if tag := strings.Split(field.Tag.Get("json"), ",")[0]; tag == "-" {
+				continue
+			} else if tag != "" {
+				name = tag
+			}
+
+			if strings.EqualFold(name, key) {
+				return field.Type
+			}
+		}
+	}
+
+	return nil
+}
+
+// parser parses a YAML document, line by line.
+type parser struct {
+	lines []string
+	pos   int // Index of the current line
+}
+
+func (p *parser) errorf(format string, a ...interface{}) error {
+	return fmt.Errorf("line %d: %v", p.pos+1, fmt.Sprintf(format, a...))
+}
+
+// parseDocument parses the whole document.
+func (p *parser) parseDocument() (interface{}, error) {
+	// Skip directives and the document start marker.
+	for i, line := range p.lines {
+		if strings.HasPrefix(line, "%") {
+			p.lines[i] = ""
+		} else if line == "---" || strings.HasPrefix(line, "--- ") {
+			p.lines[i] = strings.TrimPrefix(strings.TrimPrefix(line, "---"), " ")
+			break
+		} else if strings.TrimSpace(stripComment(line)) != "" {
+			break
+		}
+	}
+
+	value, err := p.parseNode(0)
+	if err != nil {
+		return nil, err
+	}
+
+	if indent, ok := p.next(); ok {
+		line := strings.TrimSpace(p.lines[p.pos])
+		if line == "---" || strings.HasPrefix(line, "--- ") {
+			return nil, p.errorf("multiple documents are not supported")
+		} else if line != "..." {
+			return nil, p.errorf("unexpected content at indentation %d", indent)
+		}
+	}
+
+	return value, nil
+}
+
+// next skips blank lines and comments, returning the indentation of the next line with content and
+// whether there is one.
+func (p *parser) next() (int, bool) {
+	for ; p.pos < len(p.lines); p.pos++ {
+		content := stripComment(p.lines[p.pos])
+		if strings.TrimSpace(content) == "" {
+			continue
+		}
+
+		return len(content) - len(strings.TrimLeft(content, " ")), true
+	}
+
+	return 0, false
+}
+
+// parseNode parses the node starting at the next line with content, if it is indented at least by
+// the given amount. Otherwise the node is null.
+func (p *parser) parseNode(minIndent int) (interface{}, error) {
+	indent, ok := p.next()
+	if !ok || indent < minIndent {
+		return nil, nil
+	}
+
+	if err := p.checkIndentation(); err != nil {
+		return nil, err
+	}
+
+	content := strings.TrimSpace(stripComment(p.lines[p.pos]))
+
+	if isSequenceItem(content) {
+		return p.parseSequence(indent)
+	}
+
+	if _, _, ok := splitKey(content); ok {
+		return p.parseMapping(indent)
+	}
+
+	return p.parseValue(content, indent-1)
+}
+
+// parseSequence parses a block sequence whose items are at the given indentation.
+func (p *parser) parseSequence(indent int) (interface{}, error) {
+	ret := []interface{}{}
+
+	for {
+		lineIndent, ok := p.next()
+		if !ok || lineIndent < indent {
+			return ret, nil
+		}
+
+		if err := p.checkIndentation(); err != nil {
+			return nil, err
+		}
+
+		content := strings.TrimSpace(stripComment(p.lines[p.pos]))
+		if lineIndent > indent || !isSequenceItem(content) {
+			if lineIndent == indent {
+				return ret, nil
+			}
+
+			return nil, p.errorf("unexpected indentation")
+		}
+
+		rest := strings.TrimLeft(strings.TrimPrefix(p.lines[p.pos][lineIndent:], "-"), " \t")
+
+		var item interface{}
+		var err error
+
+		if strings.TrimSpace(stripComment(rest)) == "" {
+			p.pos++
+			item, err = p.parseNode(indent + 1)
+		} else {
+			// Parse what follows the dash as if it started on its own line, so that compact nested
+			// collections (i.e. "- key: value") work.
+			column := len(p.lines[p.pos]) - len(rest)
+			p.lines[p.pos] = strings.Repeat(" ", column) + rest
+			item, err = p.parseNode(column)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		ret = append(ret, item)
+	}
+}
+
+// parseMapping parses a block mapping whose keys are at the given indentation.
+func (p *parser) parseMapping(indent int) (interface{}, error) {
+	ret := map[string]interface{}{}
+
+	for {
+		lineIndent, ok := p.next()
+		if !ok || lineIndent < indent {
+			return ret, nil
+		}
+
+		if err := p.checkIndentation(); err != nil {
+			return nil, err
+		}
+
+		content := strings.TrimSpace(stripComment(p.lines[p.pos]))
+		if lineIndent > indent {
+			return nil, p.errorf("unexpected indentation")
+		} else if isSequenceItem(content) {
+			// A sequence at the same indentation as the mapping ends it, its parent handles it.
+			return ret, nil
+		} else if content == "---" || strings.HasPrefix(content, "--- ") {
+			// So does the start of another document, which parseDocument refuses.
+			return ret, nil
+		}
+
+		if content == "?" || strings.HasPrefix(content, "? ") || strings.HasPrefix(content, "?\t") {
+			return nil, p.errorf("explicit keys are not supported")
+		}
+
+		key, rest, ok := splitKey(content)
+		if !ok {
+			return nil, p.errorf("expected a key")
+		}
+
+		if err := checkKey(key); err != nil {
+			return nil, p.errorf("%v", err)
+		}
+
+		if _, ok := ret[key]; ok {
+			return nil, p.errorf("duplicate key %v", key)
+		}
+
+		var value interface{}
+		var err error
+
+		if rest == "" {
+			p.pos++
+
+			// Sequences can be at the same indentation as their key.
+			if nextIndent, ok := p.next(); ok && nextIndent == indent && isSequenceItem(strings.TrimSpace(stripComment(p.lines[p.pos]))) {
+				value, err = p.parseSequence(indent)
+			} else {
+				value, err = p.parseNode(indent + 1)
+			}
+		} else {
+			value, err = p.parseValue(rest, indent)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		ret[key] = value
+	}
+}
+
+// parseValue parses a value that starts on the current line, after a key or a dash at the given
+// indentation, and moves past it.
+func (p *parser) parseValue(content string, parentIndent int) (interface{}, error) {
+	switch {
+	case strings.HasPrefix(content, "|") || strings.HasPrefix(content, ">"):
+		return p.parseBlockScalar(content, parentIndent)
+	case strings.HasPrefix(content, "[") || strings.HasPrefix(content, "{"):
+		// Flow collections can span multiple lines.
+		for depth, quote := flowState(content); depth > 0 && p.pos+1 < len(p.lines); depth, quote = flowState(content) {
+			p.pos++
+
+			if quote != 0 {
+				content = foldLine(content, strings.TrimSpace(p.lines[p.pos]), quote == '"')
+			} else {
+				content += " " + strings.TrimSpace(stripComment(p.lines[p.pos]))
+			}
+		}
+		p.pos++
+
+		f := &flowParser{s: content}
+		value, err := f.parseValue()
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		}
+
+		if f.skipSpaces(); f.pos < len(f.s) {
+			return nil, p.errorf("unexpected %q after flow collection", f.s[f.pos:])
+		}
+
+		return value, nil
+	case strings.HasPrefix(content, "&") || strings.HasPrefix(content, "*"):
+		return nil, p.errorf("anchors and aliases are not supported")
+	case strings.HasPrefix(content, "!"):
+		return nil, p.errorf("tags are not supported")
+	case strings.HasPrefix(content, `"`) || strings.HasPrefix(content, "'"):
+		// Quoted strings can span multiple lines, line breaks are folded.
+		value, n, err := unquote(content)
+		for err == errUnterminated && p.pos+1 < len(p.lines) {
+			p.pos++
+			content = foldLine(content, strings.TrimSpace(p.lines[p.pos]), content[0] == '"')
+			value, n, err = unquote(content)
+		}
+
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		} else if rest := strings.TrimSpace(content[n:]); rest != "" && !strings.HasPrefix(rest, "#") {
+			return nil, p.errorf("unexpected %q after quoted string", rest)
+		}
+
+		p.pos++
+		return value, nil
+	}
+
+	// Plain scalars continue on more indented lines, line breaks are folded.
+	for p.pos++; p.pos < len(p.lines); p.pos++ {
+		line := p.lines[p.pos]
+		if strings.TrimSpace(line) == "" {
+			content += "\n"
+			continue
+		}
+
+		next := stripComment(line)
+		if len(next)-len(strings.TrimLeft(next, " ")) <= parentIndent || strings.TrimSpace(next) == "" {
+			break
+		}
+
+		content = foldLine(content, strings.TrimSpace(next), false)
+	}
+
+	content = strings.TrimRight(content, "\n")
+	if err := checkPlain(content); err != nil {
+		return nil, p.errorf("%v", err)
+	}
+
+	return resolve(content)
+}
+
+// checkIndentation returns an error if the current line is indented with tabs, which YAML doesn't
+// allow.
+func (p *parser) checkIndentation() error {
+	if strings.HasPrefix(strings.TrimLeft(p.lines[p.pos], " "), "\t") {
+		return p.errorf("tabs are not allowed for indentation")
+	}
+
+	return nil
+}
+
+// checkKey returns an error if the given key of a mapping uses a construct that is not supported.
+func checkKey(key string) error {
+	switch {
+	case key == "<<":
+		return errors.New("merge keys are not supported")
+	case strings.HasPrefix(key, "&") || strings.HasPrefix(key, "*"):
+		return errors.New("anchors and aliases are not supported")
+	case strings.HasPrefix(key, "!"):
+		return errors.New("tags are not supported")
+	}
+
+	return nil
+}
+
+// checkPlain returns an error if the given plain scalar is not valid or uses a construct that is not
+// supported, instead of taking it as a string.
+func checkPlain(plain string) error {
+	if plain == "" {
+		return nil
+	}
+
+	switch {
+	case strings.HasPrefix(plain, "&") || strings.HasPrefix(plain, "*"):
+		return errors.New("anchors and aliases are not supported")
+	case strings.HasPrefix(plain, "!"):
+		return errors.New("tags are not supported")
+	case plain == "?" || strings.HasPrefix(plain, "? ") || strings.HasPrefix(plain, "?\t"):
+		return errors.New("explicit keys are not supported")
+	case plain == "-" || strings.HasPrefix(plain, "- ") || strings.HasPrefix(plain, "-\t"):
+		return errors.New("block sequences are not allowed here")
+	case plain == ":" || strings.HasPrefix(plain, ": ") || strings.HasPrefix(plain, ":\t"):
+		return errors.New("mapping values are not allowed here")
+	case strings.IndexByte("@`%|>", plain[0]) >= 0:
+		return fmt.Errorf("plain scalars cannot start with %q", plain[0])
+	}
+
+	for i := 0; i < len(plain); i++ {
+		if plain[i] == ':' && (i+1 == len(plain) || plain[i+1] == ' ' || plain[i+1] == '\t') {
+			return errors.New("mapping values are not allowed here")
+		}
+	}
+
+	return nil
+}
+
+// foldLine appends the next line of a multi-line scalar to its content so far. Line breaks become
+// spaces, unless followed by blank lines (which are kept as line breaks) or escaped.
+func foldLine(content string, next string, doubleQuoted bool) string {
+	escaped := doubleQuoted && (len(content)-len(strings.TrimRight(content, "\\")))%2 == 1
+
+	switch {
+	case next == "":
+		return content + "\n"
+	case escaped:
+		return content[:len(content)-1] + next
+	case strings.HasSuffix(content, "\n"):
+		return content + next
+	default:
+		return content + " " + next
+	}
+}
+
+// parseBlockScalar parses a literal ("|") or folded (">") block scalar, whose header is given,
+// indented more than the given amount.
+func (p *parser) parseBlockScalar(header string, parentIndent int) (interface{}, error) {
+	folded := header[0] == '>'
+	chomping := strings.TrimSpace(header[1:])
+	if chomping != "" && chomping != "-" && chomping != "+" {
+		return nil, p.errorf("unsupported block scalar header %q", header)
+	}
+
+	p.pos++
+
+	var lines []string
+	indent := -1
+
+	for ; p.pos < len(p.lines); p.pos++ {
+		line := p.lines[p.pos]
+		lineIndent := len(line) - len(strings.TrimLeft(line, " "))
+
+		if strings.TrimSpace(line) == "" {
+			lines = append(lines, "")
+			continue
+		}
+
+		if indent == -1 {
+			indent = lineIndent
+		}
+
+		if lineIndent <= parentIndent || lineIndent < indent {
+			break
+		}
+
+		lines = append(lines, line[indent:])
+	}
+
+	// Trailing blank lines are subject to chomping.
+	content := len(lines)
+	for content > 0 && lines[content-1] == "" {
+		content--
+	}
+
+	var ret string
+	if folded {
+		for i, line := range lines[:content] {
+			switch {
+			case i == 0:
+				ret = line
+			case line == "":
+				ret += "\n"
+			case lines[i-1] == "":
+				ret += line
+			default:
+				ret += " " + line
+			}
+		}
+	} else {
+		ret = strings.Join(lines[:content], "\n")
+	}
+
+	switch chomping {
+	case "-":
+		return ret, nil
+	case "+":
+		return ret + strings.Repeat("\n", len(lines)-content+1), nil
+	default:
+		if content == 0 {
+			return "", nil
+		}
+
+		return ret + "\n", nil
+	}
+}
+
+// flowParser parses a flow collection (i.e. "[a, b]" or "{a: b}") on a single line.
+type flowParser struct {
+	s   string
+	pos int
+}
+
+func (f *flowParser) skipSpaces() {
+	for f.pos < len(f.s) && (f.s[f.pos] == ' ' || f.s[f.pos] == '\t') {
+		f.pos++
+	}
+}
+
+func (f *flowParser) parseValue() (interface{}, error) {
+	f.skipSpaces()
+	if f.pos >= len(f.s) {
+		return nil, fmt.Errorf("unexpected end of flow collection")
+	}
+
+	switch f.s[f.pos] {
+	case '[':
+		f.pos++
+		ret := []interface{}{}
+
+		for {
+			if f.skipSpaces(); f.pos < len(f.s) && f.s[f.pos] == ']' {
+				f.pos++
+				return ret, nil
+			}
+
+			item, err := f.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, item)
+
+			if err := f.separator(']'); err != nil {
+				return nil, err
+			}
+		}
+	case '{':
+		f.pos++
+		ret := map[string]interface{}{}
+
+		for {
+			if f.skipSpaces(); f.pos < len(f.s) && f.s[f.pos] == '}' {
+				f.pos++
+				return ret, nil
+			}
+
+			key, err := f.parseScalar(true)
+			if err != nil {
+				return nil, err
+			}
+
+			if f.skipSpaces(); f.pos >= len(f.s) || f.s[f.pos] != ':' {
+				return nil, fmt.Errorf("expected ':' after key %v", key)
+			}
+			f.pos++
+
+			if err := checkKey(fmt.Sprint(key)); err != nil {
+				return nil, err
+			} else if _, ok := ret[fmt.Sprint(key)]; ok {
+				return nil, fmt.Errorf("duplicate key %v", key)
+			}
+
+			value, err := f.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			ret[fmt.Sprint(key)] = value
+
+			if err := f.separator('}'); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return f.parseScalar(false)
+}
+
+// separator consumes the comma between two items, or checks that the collection ends.
+func (f *flowParser) separator(end byte) error {
+	f.skipSpaces()
+
+	switch {
+	case f.pos < len(f.s) && f.s[f.pos] == ',':
+		f.pos++
+		return nil
+	case f.pos < len(f.s) && f.s[f.pos] == end:
+		return nil
+	default:
+		return fmt.Errorf("expected ',' or '%c'", end)
+	}
+}
+
+// parseScalar parses a quoted or plain scalar inside a flow collection.
+func (f *flowParser) parseScalar(key bool) (interface{}, error) {
+	f.skipSpaces()
+
+	if f.pos < len(f.s) && (f.s[f.pos] == '"' || f.s[f.pos] == '\'') {
+		value, n, err := unquote(f.s[f.pos:])
+		f.pos += n
+		return value, err
+	}
+
+	start := f.pos
+	for f.pos < len(f.s) {
+		c := f.s[f.pos]
+		if c == ',' || c == '[' || c == ']' || c == '{' || c == '}' {
+			break
+		}
+		if c == ':' && (key || f.pos+1 == len(f.s) || f.s[f.pos+1] == ' ' || f.s[f.pos+1] == '\t') {
+			break
+		}
+		f.pos++
+	}
+
+	plain := strings.TrimSpace(f.s[start:f.pos])
+	if err := checkPlain(plain); err != nil {
+		return nil, err
+	}
+
+	if key {
+		return plain, nil
+	}
+
+	return resolve(plain)
+}
+
+// isSequenceItem returns whether the given line content is an item of a block sequence.
+func isSequenceItem(content string) bool {
+	return content == "-" || strings.HasPrefix(content, "- ") || strings.HasPrefix(content, "-\t")
+}
+
+// splitKey splits the given line content of a block mapping in its key and the rest of the line.
+func splitKey(content string) (string, string, bool) {
+	if strings.HasPrefix(content, `"`) || strings.HasPrefix(content, "'") {
+		key, n, err := unquote(content)
+		if err != nil {
+			return "", "", false
+		}
+
+		rest := strings.TrimLeft(content[n:], " \t")
+		if !strings.HasPrefix(rest, ":") || (len(rest) > 1 && rest[1] != ' ' && rest[1] != '\t') {
+			return "", "", false
+		}
+
+		return key, strings.TrimSpace(rest[1:]), true
+	}
+
+	if strings.HasPrefix(content, "[") || strings.HasPrefix(content, "{") {
+		return "", "", false
+	}
+
+	for i := 0; i < len(content); i++ {
+		if content[i] == ':' && (i+1 == len(content) || content[i+1] == ' ' || content[i+1] == '\t') {
+			return strings.TrimSpace(content[:i]), strings.TrimSpace(content[i+1:]), true
+		}
+	}
+
+	return "", "", false
+}
+
+// stripComment removes a trailing comment from the given line, if any.
+func stripComment(line string) string {
+	var quote byte
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote == '\'' && c == '\'' && i+1 < len(line) && line[i+1] == '\'':
+			i++
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == 0 && (c == '"' || c == '\'') && (i == 0 || strings.IndexByte(" \t[{,:-", line[i-1]) >= 0):
+			quote = c
+		case quote == 0 && c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return strings.TrimRight(line[:i], " \t")
+		}
+	}
+
+	return strings.TrimRight(line, " \t")
+}
+
+// flowState returns how many brackets of the given flow collection are still open and, if it ends
+// inside a quoted string, its quote character.
+func flowState(s string) (int, byte) {
+	depth := 0
+	var quote byte
+	start := true // Whether a scalar can start here, quotes within plain scalars are literal
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote == '\'' && c == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			i++
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+		case c == ' ' || c == '\t':
+			continue
+		case start && (c == '"' || c == '\''):
+			quote = c
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		}
+
+		start = quote == 0 && (c == '[' || c == '{' || c == ',' || c == ':')
+	}
+
+	return depth, quote
+}
+
+// unquote parses the single- or double-quoted string at the start of s, returning its value and
+// its length in s.
+func unquote(s string) (string, int, error) {
+	quote := s[0]
+	var b strings.Builder
+
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+
+		if quote == '\'' {
+			if c == '\'' {
+				if i+1 < len(s) && s[i+1] == '\'' {
+					b.WriteByte('\'')
+					i++
+					continue
+				}
+
+				return b.String(), i + 1, nil
+			}
+
+			b.WriteByte(c)
+			continue
+		}
+
+		switch c {
+		case '"':
+			return b.String(), i + 1, nil
+		case '\\':
+			if i+1 >= len(s) {
+				return "", 0, errUnterminated // Escaped line break
+			}
+			i++
+
+			switch e := s[i]; e {
+			case '0':
+				b.WriteByte(0)
+			case 'a':
+				b.WriteByte('\a')
+			case 'b':
+				b.WriteByte('\b')
+			case 't', '\t':
+				b.WriteByte('\t')
+			case 'n':
+				b.WriteByte('\n')
+			case 'v':
+				b.WriteByte('\v')
+			case 'f':
+				b.WriteByte('\f')
+			case 'r':
+				b.WriteByte('\r')
+			case 'e':
+				b.WriteByte(0x1b)
+			case ' ', '"', '/', '\\':
+				b.WriteByte(e)
+			case 'N':
+				b.WriteRune('\u0085')
+			case '_':
+				b.WriteRune('\u00a0')
+			case 'L':
+				b.WriteRune('\u2028')
+			case 'P':
+				b.WriteRune('\u2029')
+			case 'x', 'u', 'U':
+				length := map[byte]int{'x': 2, 'u': 4, 'U': 8}[e]
+				if i+length >= len(s) {
+					return "", 0, fmt.Errorf("invalid escape sequence \\%c", e)
+				}
+
+				code, err := strconv.ParseUint(s[i+1:i+1+length], 16, 32)
+				if err != nil {
+					return "", 0, fmt.Errorf("invalid escape sequence \\%v", s[i:i+1+length])
+				}
+
+				b.WriteRune(rune(code))
+				i += length
+			default:
+				return "", 0, fmt.Errorf("invalid escape sequence \\%c", e)
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return "", 0, errUnterminated
+}
+
+// resolve returns the value of the given plain scalar, following the YAML 1.2 core schema.
+func resolve(plain string) (interface{}, error) {
+	switch plain {
+	case "", "~", "null", "Null", "NULL":
+		return nil, nil
+	case "true", "True", "TRUE":
+		return true, nil
+	case "false", "False", "FALSE":
+		return false, nil
+	}
+
+	if specialRegexp.MatchString(plain) {
+		return nil, fmt.Errorf("%v cannot be represented in JSON", plain)
+	}
+
+	if intRegexp.MatchString(plain) {
+		if value, err := strconv.ParseInt(plain, 10, 64); err == nil {
+			return number{plain, value}, nil
+		}
+	}
+
+	if octalRegexp.MatchString(plain) || hexRegexp.MatchString(plain) {
+		base := 8
+		if plain[1] == 'x' {
+			base = 16
+		}
+
+		value, err := strconv.ParseInt(plain[2:], base, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer %v: %w", plain, err)
+		}
+
+		return number{plain, value}, nil
+	}
+
+	if floatRegexp.MatchString(plain) {
+		if value, err := strconv.ParseFloat(plain, 64); err == nil && !math.IsInf(value, 0) {
+			return number{plain, value}, nil
+		}
+	}
+
+	return plain, nil
+}
@@ -0,0 +1,224 @@
+// just-install - The simple package installer for Windows
+// Copyright (C) 2020 just-install authors.
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, version 3 of the License.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package yaml
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// assertJSON checks that the given JSON document is equivalent to the expected one.
+func assertJSON(t *testing.T, got []byte, expected string) {
+	t.Helper()
+
+	var gotValue, expectedValue interface{}
+	if err := json.Unmarshal(got, &gotValue); err != nil {
+		t.Fatalf("invalid JSON %s: %v", got, err)
+	}
+	if err := json.Unmarshal([]byte(expected), &expectedValue); err != nil {
+		t.Fatalf("invalid expected JSON %s: %v", expected, err)
+	}
+
+	if !reflect.DeepEqual(gotValue, expectedValue) {
+		t.Errorf("got %s, expected %s", got, expected)
+	}
+}
+
+func TestToJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		yaml     string
+		expected string
+	}{
+		{"scalars", `
+string: hello world
+int: 42
+negative: -7
+float: 1.5
+exponent: 1e3
+bool: true
+False: False
+null: ~
+empty:
+`, `{"string": "hello world", "int": 42, "negative": -7, "float": 1.5, "exponent": 1000, "bool": true, "False": false, "null": null, "empty": null}`},
+		{"quoted scalars", `
+double: "a \"quoted\"\tstring é"
+single: 'it''s'
+number: "1.10"
+colon: "a: b"
+`, `{"double": "a \"quoted\"\tstring é", "single": "it's", "number": "1.10", "colon": "a: b"}`},
+		{"nesting", `
+packages:
+  7zip:
+    version: "19.00"
+    installer:
+      kind: msi
+      x86:
+        - one
+        - two: 2
+          three: 3
+list:
+- a
+- [b, c]
+flow: {x: 1, y: [true, null], z: "w"}
+`, `{"packages": {"7zip": {"version": "19.00", "installer": {"kind": "msi", "x86": ["one", {"two": 2, "three": 3}]}}}, "list": ["a", ["b", "c"]], "flow": {"x": 1, "y": [true, null], "z": "w"}}`},
+		{"multi-line flow collection", `
+list: [
+  one,
+  two,
+]
+`, `{"list": ["one", "two"]}`},
+		{"block strings", `
+literal: |
+  line one
+    indented
+  line two
+folded: >
+  folded
+  line
+
+  paragraph
+strip: |-
+  no newline
+keep: |+
+  newlines
+
+plain: a plain
+  multi-line scalar
+`, `{"literal": "line one\n  indented\nline two\n", "folded": "folded line\nparagraph\n", "strip": "no newline", "keep": "newlines\n\n", "plain": "a plain multi-line scalar"}`},
+		{"comments", `
+# Leading comment
+%YAML 1.2
+---
+key: value # trailing comment
+url: http://example.com/#fragment
+quoted: "# not a comment"
+list:
+  # Inside a collection
+  - a # after an item
+`, `{"key": "value", "url": "http://example.com/#fragment", "quoted": "# not a comment", "list": ["a"]}`},
+		{"windows line endings", "a: 1\r\nb: two\r\n", `{"a": 1, "b": "two"}`},
+		{"tab separators", "a:\tb\nlist:\n-\tc\nflow: {d:\t1}\n", `{"a": "b", "list": ["c"], "flow": {"d": 1}}`},
+		{"integers", "octal: 0o17\nhex: 0x1F\nplus: +3\n", `{"octal": 15, "hex": 31, "plus": 3}`},
+		{"not numbers", "a: 0x\nb: 1.2.3\nc: 0b101\nd: ?x\ne: a:b\n", `{"a": "0x", "b": "1.2.3", "c": "0b101", "d": "?x", "e": "a:b"}`},
+		{"escapes", `a: "\N\_\L\P\x41\u00e9"`, `{"a": "\u0085\u00a0\u2028\u2029A\u00e9"}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ToJSON([]byte(strings.TrimPrefix(test.yaml, "\n")))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assertJSON(t, got, test.expected)
+		})
+	}
+}
+
+func TestToJSONErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		yaml  string
+		error string
+	}{
+		{"duplicate key", "a: 1\na: 2\n", "line 2: duplicate key a"},
+		{"unexpected indentation", "a:\n    b: 1\n  c: 2\n", "line 3: unexpected indentation"},
+		{"anchor", "a: &anchor 1\n", "anchors and aliases are not supported"},
+		{"alias", "a: *anchor\n", "anchors and aliases are not supported"},
+		{"tag", "a: !!str 1\n", "tags are not supported"},
+		{"unterminated string", "a: \"open\n", "unterminated quoted string"},
+		{"trailing content", "a: \"b\" c\n", "unexpected"},
+		{"unterminated flow collection", "a: [b, c\n", "expected ',' or ']'"},
+		{"invalid escape", `a: "\q"`, "invalid escape sequence"},
+		{"block scalar header", "a: |2\n  b\n", "unsupported block scalar header"},
+		{"multiple documents", "a: 1\n---\nb: 2\n", "multiple documents are not supported"},
+		{"anchor on a key", "&anchor a: 1\n", "anchors and aliases are not supported"},
+		{"alias in a flow collection", "a: [*anchor]\n", "anchors and aliases are not supported"},
+		{"tag in a flow collection", "a: {b: !!str 1}\n", "tags are not supported"},
+		{"explicit key", "? a\n: 1\n", "explicit keys are not supported"},
+		{"explicit key in a mapping", "a: 1\n? b\n: 2\n", "explicit keys are not supported"},
+		{"merge key", "a: {b: 1}\nc:\n  <<: {d: 2}\n", "merge keys are not supported"},
+		{"nested mapping on one line", "a: b: c\n", "mapping values are not allowed here"},
+		{"sequence after a key", "a: - b\n", "block sequences are not allowed here"},
+		{"reserved indicator", "a: @b\n", "plain scalars cannot start with '@'"},
+		{"directive indicator", "a: %b%\n", "plain scalars cannot start with '%'"},
+		{"infinity", "a: .inf\n", ".inf cannot be represented in JSON"},
+		{"not a number", "a: .NaN\n", ".NaN cannot be represented in JSON"},
+		{"duplicate key in a flow mapping", "a: {b: 1, b: 2}\n", "duplicate key b"},
+		{"tab indentation", "a:\n  b: 1\n\tc: 2\n", "tabs are not allowed for indentation"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ToJSON([]byte(test.yaml))
+			if err == nil {
+				t.Fatalf("expected an error, got %s", got)
+			}
+
+			if !strings.Contains(err.Error(), test.error) {
+				t.Errorf("expected an error containing %q, got %q", test.error, err)
+			}
+		})
+	}
+}
+
+func TestToJSONFor(t *testing.T) {
+	type entry struct {
+		Version  string
+		Count    int
+		Ratio    float64
+		Tags     []string
+		Labels   map[string]string
+		Options  map[string]interface{}
+		Renamed  string `json:"other"`
+		Nested   *entry
+		internal string
+	}
+
+	yaml := `
+version: 1.10
+count: 3
+ratio: 0.50
+tags: [1.0, 2]
+labels:
+  a: 007
+options:
+  n: 1.10
+other: 2
+nested:
+  version: 10
+unknown: 1.10
+`
+
+	got, err := ToJSONFor([]byte(yaml), &entry{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertJSON(t, got, `{"version": "1.10", "count": 3, "ratio": 0.5, "tags": ["1.0", "2"], "labels": {"a": "007"}, "options": {"n": 1.1}, "other": "2", "nested": {"version": "10"}, "unknown": 1.1}`)
+
+	var decoded entry
+	if err := json.Unmarshal(got, &decoded); err != nil {
+		t.Fatalf("could not decode %s: %v", got, err)
+	}
+
+	if decoded.Version != "1.10" || decoded.Nested.Version != "10" {
+		t.Errorf("versions not kept as written: %+v", decoded)
+	}
+}